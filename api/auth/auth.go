@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	ScopeReadEvents = "read:events"
+	ScopeReadStats  = "read:stats"
+	// ScopeAdmin grants every scope
+	ScopeAdmin = "admin"
+
+	KindKey      = "key"
+	KindJwt      = "jwt"
+	KindAdminKey = "admin-key"
+
+	apiKeyHeader = "X-API-Key"
+	bearerPrefix = "Bearer "
+)
+
+var (
+	Scopes = []string{ScopeReadEvents, ScopeReadStats, ScopeAdmin}
+
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type principalKey struct{}
+
+type Options struct {
+	Enabled bool `config:"AUTH_ENABLED" default:"true"`
+	// AdminKey is an api key with the admin scope that isn't stored, used to create the first keys
+	AdminKey       string `config:"AUTH_ADMIN_KEY" secret:"true"`
+	JwksFile       string `config:"AUTH_JWKS_FILE"`
+	JwtIssuer      string `config:"AUTH_JWT_ISSUER"`
+	JwtAudience    string `config:"AUTH_JWT_AUDIENCE"`
+	KeysDb         string `config:"API_KEYS_DB" default:"github" required:"true"`
+	KeysCollection string `config:"API_KEYS_COLLECTION" default:"api_keys" required:"true"`
+}
+
+// Validate reports the options that leave no way to authenticate
+func (receiver Options) Validate() error {
+	if receiver.Enabled && len(receiver.AdminKey) == 0 && len(receiver.JwksFile) == 0 {
+		return errors.New("AUTH_ADMIN_KEY or AUTH_JWKS_FILE is required unless AUTH_ENABLED is false")
+	}
+	return nil
+}
+
+// Principal is the authenticated client of a request
+type Principal struct {
+	// ID is the id of the api key, or the subject of the JWT
+	ID     string
+	Kind   string
+	Scopes []string
+	// Tier is the rate limit tier of the principal, empty for the default tier
+	Tier string
+}
+
+// HasScope reports whether the principal was granted the scope, admins are granted every scope
+func (receiver Principal) HasScope(scope string) bool {
+	for _, granted := range receiver.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func (receiver Principal) String() string {
+	return receiver.Kind + ":" + receiver.ID
+}
+
+// WithPrincipal returns a context holding the authenticated principal
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the principal authenticated for the request context, if any
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// Authenticator authenticates requests with api keys, sent as X-API-Key or bearer token, or with JWT bearer tokens
+// signed by a key of the JWKS file
+type Authenticator struct {
+	keys         *KeyStore
+	jwks         *Jwks
+	adminKeyHash []byte
+	issuer       string
+	audience     string
+}
+
+// Authenticate returns the principal of the request credentials
+func (receiver *Authenticator) Authenticate(request *http.Request) (Principal, error) {
+	token := request.Header.Get(apiKeyHeader)
+	if len(token) == 0 {
+		authorization := request.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, bearerPrefix) {
+			return Principal{}, ErrMissingCredentials
+		}
+		token = strings.TrimSpace(strings.TrimPrefix(authorization, bearerPrefix))
+	}
+	if len(receiver.adminKeyHash) > 0 && subtle.ConstantTimeCompare(hashKey(token), receiver.adminKeyHash) == 1 {
+		return Principal{ID: "admin", Kind: KindAdminKey, Scopes: []string{ScopeAdmin}}, nil
+	}
+	if IsApiKey(token) {
+		return receiver.keys.VerifyWithContext(request.Context(), token)
+	}
+	if receiver.jwks == nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return receiver.jwks.Verify(token, receiver.issuer, receiver.audience)
+}
+
+func hashKey(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
+
+// NewAuthenticator loads the JWKS file, if any
+func NewAuthenticator(options Options, keys *KeyStore) (*Authenticator, error) {
+	authenticator := &Authenticator{keys: keys, issuer: options.JwtIssuer, audience: options.JwtAudience}
+	if len(options.AdminKey) > 0 {
+		authenticator.adminKeyHash = hashKey(options.AdminKey)
+	}
+	if len(options.JwksFile) > 0 {
+		jwks, err := LoadJwks(options.JwksFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWKS file: %w", err)
+		}
+		authenticator.jwks = jwks
+	}
+	return authenticator, nil
+}
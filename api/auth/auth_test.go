@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github-events-microservices/stores"
+	mockstores "github-events-microservices/stores/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const storedKey = "gea_0123456789abcdef_secret"
+
+func writeJwks(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+	content, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": kid, "use": "sig", "n": encode(key.N), "e": encode(big.NewInt(int64(key.E)))},
+		{"kty": "oct", "kid": "symmetric", "use": "enc"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	err = os.WriteFile(path, content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func signToken(t *testing.T, kid string, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksFile := writeJwks(t, "k1", signingKey)
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	validClaims := jwt.MapClaims{"sub": "dashboard", "iss": "https://issuer", "aud": "events-api", "exp": expiresAt, "scope": "read:events read:stats"}
+	findStoredKey := func(_ context.Context, id interface{}, result interface{}) error {
+		*result.(*ApiKey) = ApiKey{ID: "0123456789abcdef", Hash: hex.EncodeToString(hashKey(storedKey)), Scopes: []string{ScopeReadEvents}}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		expect  func(store *mockstores.MockDocumentStore)
+		want    Principal
+		wantErr error
+	}{
+		{
+			name:    "missing credentials",
+			wantErr: ErrMissingCredentials,
+		},
+		{
+			name:    "admin key",
+			headers: map[string]string{"X-API-Key": "bootstrap"},
+			want:    Principal{ID: "admin", Kind: KindAdminKey, Scopes: []string{ScopeAdmin}},
+		},
+		{
+			name:    "stored key as bearer token",
+			headers: map[string]string{"Authorization": "Bearer " + storedKey},
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().FindByIdWithContext(gomock.Any(), "0123456789abcdef", gomock.Any()).DoAndReturn(findStoredKey)
+			},
+			want: Principal{ID: "0123456789abcdef", Kind: KindKey, Scopes: []string{ScopeReadEvents}},
+		},
+		{
+			name:    "stored key with a wrong secret",
+			headers: map[string]string{"X-API-Key": "gea_0123456789abcdef_guess"},
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().FindByIdWithContext(gomock.Any(), "0123456789abcdef", gomock.Any()).DoAndReturn(findStoredKey)
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "unknown key",
+			headers: map[string]string{"X-API-Key": "gea_missing_secret"},
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().FindByIdWithContext(gomock.Any(), "missing", gomock.Any()).Return(stores.ErrNotFound)
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "store failure",
+			headers: map[string]string{"X-API-Key": storedKey},
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().FindByIdWithContext(gomock.Any(), "0123456789abcdef", gomock.Any()).Return(errors.New("connection refused"))
+			},
+			wantErr: errors.New("failed to find api key 0123456789abcdef: connection refused"),
+		},
+		{
+			name:    "valid JWT",
+			headers: map[string]string{"Authorization": "Bearer " + signToken(t, "k1", signingKey, validClaims)},
+			want:    Principal{ID: "dashboard", Kind: KindJwt, Scopes: []string{ScopeReadEvents, ScopeReadStats}},
+		},
+		{
+			name: "JWT with an scp list",
+			headers: map[string]string{"Authorization": "Bearer " + signToken(t, "k1", signingKey,
+				jwt.MapClaims{"sub": "ops", "iss": "https://issuer", "aud": []string{"events-api"}, "exp": expiresAt, "scp": []string{"admin"}, "tier": "internal"})},
+			want: Principal{ID: "ops", Kind: KindJwt, Scopes: []string{ScopeAdmin}, Tier: "internal"},
+		},
+		{
+			name: "expired JWT",
+			headers: map[string]string{"Authorization": "Bearer " + signToken(t, "k1", signingKey,
+				jwt.MapClaims{"sub": "dashboard", "iss": "https://issuer", "aud": "events-api", "exp": time.Now().Add(-time.Minute).Unix()})},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "JWT of another issuer",
+			headers: map[string]string{"Authorization": "Bearer " + signToken(t, "k1", signingKey,
+				jwt.MapClaims{"sub": "dashboard", "iss": "https://other", "aud": "events-api", "exp": expiresAt})},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "JWT signed by an unknown key",
+			headers: map[string]string{"Authorization": "Bearer " + signToken(t, "k1", otherKey, validClaims)},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "JWT without expiry",
+			headers: map[string]string{"Authorization": "Bearer " + signToken(t, "k1", signingKey, jwt.MapClaims{"sub": "dashboard", "iss": "https://issuer", "aud": "events-api"})},
+			wantErr: ErrInvalidCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := mockstores.NewMockDocumentStore(mockCtrl)
+			if tt.expect != nil {
+				tt.expect(store)
+			}
+			options := Options{Enabled: true, AdminKey: "bootstrap", JwksFile: jwksFile, JwtIssuer: "https://issuer", JwtAudience: "events-api"}
+			authenticator, err := NewAuthenticator(options, NewKeyStore(store))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := httptest.NewRequest("GET", "/list", nil)
+			for header, value := range tt.headers {
+				request.Header.Set(header, value)
+			}
+			got, err := authenticator.Authenticate(request)
+			if tt.wantErr != nil {
+				if err == nil || (!errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error()) {
+					t.Errorf("expected error: %v, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected: %+v, got: %+v (%v)", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestKeyStore_Verify_cachesKeys(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	store := mockstores.NewMockDocumentStore(mockCtrl)
+	store.EXPECT().FindByIdWithContext(gomock.Any(), "missing", gomock.Any()).Return(stores.ErrNotFound).Times(2)
+	keys := NewKeyStore(store)
+	for _, after := range []time.Duration{0, keyCacheTTL / 2, keyCacheTTL} {
+		keys.now = func() time.Time { return now.Add(after) }
+		_, err := keys.Verify("gea_missing_secret")
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected invalid credentials, got: %v", err)
+		}
+	}
+}
+
+func TestKeyStore_Create(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	store := mockstores.NewMockDocumentStore(mockCtrl)
+	var saved ApiKey
+	store.EXPECT().Save(gomock.Any()).DoAndReturn(func(element interface{}) error {
+		saved = element.(ApiKey)
+		return nil
+	})
+	store.EXPECT().FindByIdWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id interface{}, result interface{}) error {
+		*result.(*ApiKey) = saved
+		return nil
+	})
+	keys := NewKeyStore(store)
+
+	_, _, err := keys.Create("dashboard", []string{"write:events"}, "")
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected an invalid key error, got: %v", err)
+	}
+	key, value, err := keys.Create("dashboard", []string{ScopeReadStats}, "partner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Hash == value || !IsApiKey(value) {
+		t.Errorf("expected a hashed api key, got: %+v, %s", key, value)
+	}
+	principal, err := keys.Verify(value)
+	if err != nil || principal.ID != key.ID || principal.Tier != "partner" || !principal.HasScope(ScopeReadStats) || principal.HasScope(ScopeReadEvents) {
+		t.Errorf("expected the created key to be verified, got: %+v (%v)", principal, err)
+	}
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksCheckInterval is how often the JWKS file is checked for changes, so rotated keys are picked up without a restart
+const jwksCheckInterval = 30 * time.Second
+
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// Jwks holds the public keys of a JSON Web Key Set file, by key id
+type Jwks struct {
+	path      string
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	modTime   time.Time
+	checkedAt time.Time
+	now       func() time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// tokenClaims are the registered claims, the scopes, granted either as a space separated scope or as an scp list,
+// and the rate limit tier
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope string          `json:"scope"`
+	Scp   json.RawMessage `json:"scp"`
+	Tier  string          `json:"tier"`
+}
+
+// Verify checks the signature, expiry, issuer and audience of a JWT and returns its subject, scopes and tier
+func (receiver *Jwks) Verify(token string, issuer string, audience string) (Principal, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods(signingMethods), jwt.WithExpirationRequired()}
+	if len(issuer) > 0 {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if len(audience) > 0 {
+		options = append(options, jwt.WithAudience(audience))
+	}
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, receiver.keyFunc, options...)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("rejected JWT: %s", err.Error()))
+		return Principal{}, ErrInvalidCredentials
+	}
+	if len(claims.Subject) == 0 {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{ID: claims.Subject, Kind: KindJwt, Scopes: claims.scopes(), Tier: claims.Tier}, nil
+}
+
+func (receiver *Jwks) keyFunc(token *jwt.Token) (interface{}, error) {
+	keys := receiver.current()
+	kid, _ := token.Header["kid"].(string)
+	if len(kid) == 0 && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id '%s'", kid)
+	}
+	return key, nil
+}
+
+// current returns the keys, reloaded when the file changed. The previous keys are kept when the file can't be read.
+func (receiver *Jwks) current() map[string]crypto.PublicKey {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	now := receiver.now()
+	if now.Sub(receiver.checkedAt) < jwksCheckInterval {
+		return receiver.keys
+	}
+	receiver.checkedAt = now
+	info, err := os.Stat(receiver.path)
+	if err != nil || info.ModTime().Equal(receiver.modTime) {
+		return receiver.keys
+	}
+	keys, err := readJwks(receiver.path)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to reload JWKS file, keeping the previous keys: %s", err.Error()))
+		return receiver.keys
+	}
+	slog.Info(fmt.Sprintf("reloaded %d keys of JWKS file %s", len(keys), receiver.path))
+	receiver.keys = keys
+	receiver.modTime = info.ModTime()
+	return receiver.keys
+}
+
+func (receiver tokenClaims) scopes() []string {
+	scopes := strings.Fields(receiver.Scope)
+	if len(receiver.Scp) > 0 {
+		var list []string
+		if json.Unmarshal(receiver.Scp, &list) == nil {
+			scopes = append(scopes, list...)
+		} else {
+			var text string
+			if json.Unmarshal(receiver.Scp, &text) == nil {
+				scopes = append(scopes, strings.Fields(text)...)
+			}
+		}
+	}
+	return scopes
+}
+
+func readJwks(path string) (map[string]crypto.PublicKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = json.Unmarshal(content, &set)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	var errs []error
+	for _, webKey := range set.Keys {
+		if webKey.Use != "" && webKey.Use != "sig" {
+			continue
+		}
+		key, err := webKey.publicKey()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key '%s': %w", webKey.Kid, err))
+			continue
+		}
+		keys[webKey.Kid] = key
+	}
+	if len(keys) == 0 {
+		errs = append(errs, errors.New("no signing key"))
+	}
+	return keys, errors.Join(errs...)
+}
+
+func (receiver jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch receiver.Kty {
+	case "RSA":
+		n, err := decodeBigInt(receiver.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(receiver.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[receiver.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve '%s'", receiver.Crv)
+		}
+		x, err := decodeBigInt(receiver.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(receiver.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s'", receiver.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(bytes) == 0 {
+		return nil, fmt.Errorf("invalid base64url value '%s'", value)
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+// LoadJwks reads the RSA and EC signing keys of a JWKS file
+func LoadJwks(path string) (*Jwks, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := readJwks(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Jwks{path: path, keys: keys, modTime: info.ModTime(), checkedAt: time.Now(), now: time.Now}, nil
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github-events-microservices/stores"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// api keys look like gea_<id>_<secret>, only their hash is stored
+	keyPrefix = "gea_"
+	// keyCacheTTL is how long a looked up key is trusted, so a deleted key is rejected within that time by every replica
+	keyCacheTTL = 30 * time.Second
+	// maxCachedKeys bounds the cache, unknown ids are cached too
+	maxCachedKeys = 1000
+)
+
+// ErrInvalidKey is returned when creating a key without a name or with unknown scopes
+var ErrInvalidKey = errors.New("invalid key")
+
+// ApiKey is a stored api key
+type ApiKey struct {
+	ID        string    `bson:"_id" json:"id"`
+	Name      string    `bson:"name" json:"name"`
+	Hash      string    `bson:"hash" json:"-"`
+	Scopes    []string  `bson:"scopes" json:"scopes"`
+	Tier      string    `bson:"tier,omitempty" json:"tier,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// KeyStore creates, verifies and deletes the api keys of a collection. Looked up keys are cached for keyCacheTTL.
+type KeyStore struct {
+	store stores.DocumentStore
+	mu    sync.Mutex
+	cache map[string]cachedKey
+	now   func() time.Time
+}
+
+type cachedKey struct {
+	// key is nil when there is no such key
+	key      *ApiKey
+	cachedAt time.Time
+}
+
+// Create stores a new key of a rate limit tier, empty for the default one, and returns it along with its secret
+// value, which can't be retrieved later
+func (receiver *KeyStore) Create(name string, scopes []string, tier string) (ApiKey, string, error) {
+	if len(strings.TrimSpace(name)) == 0 {
+		return ApiKey{}, "", fmt.Errorf("%w: name is required", ErrInvalidKey)
+	}
+	err := validateScopes(scopes)
+	if err != nil {
+		return ApiKey{}, "", fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	id := randomHex(8)
+	value := keyPrefix + id + "_" + randomHex(24)
+	key := ApiKey{ID: id, Name: name, Hash: hex.EncodeToString(hashKey(value)), Scopes: scopes, Tier: tier, CreatedAt: receiver.now().UTC()}
+	err = receiver.store.Save(key)
+	if err != nil {
+		return ApiKey{}, "", err
+	}
+	receiver.forget(id)
+	return key, value, nil
+}
+
+func (receiver *KeyStore) List() ([]ApiKey, error) {
+	keys := make([]ApiKey, 0)
+	err := receiver.store.All(&keys)
+	return keys, err
+}
+
+// Delete revokes a key and reports whether it existed
+func (receiver *KeyStore) Delete(id string) (bool, error) {
+	deleted, err := receiver.store.DeleteById(id)
+	receiver.forget(id)
+	return deleted, err
+}
+
+// Verify returns the principal of an api key
+func (receiver *KeyStore) Verify(value string) (Principal, error) {
+	return receiver.VerifyWithContext(context.Background(), value)
+}
+
+// VerifyWithContext returns the principal of an api key, looked up within the context of the request
+func (receiver *KeyStore) VerifyWithContext(ctx context.Context, value string) (Principal, error) {
+	id, ok := keyId(value)
+	if !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+	key, err := receiver.find(ctx, id)
+	if err != nil {
+		return Principal{}, fmt.Errorf("failed to find api key %s: %w", id, err)
+	}
+	if key == nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	expected, err := hex.DecodeString(key.Hash)
+	if err != nil || subtle.ConstantTimeCompare(hashKey(value), expected) != 1 {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{ID: key.ID, Kind: KindKey, Scopes: key.Scopes, Tier: key.Tier}, nil
+}
+
+func (receiver *KeyStore) find(ctx context.Context, id string) (*ApiKey, error) {
+	receiver.mu.Lock()
+	cached, ok := receiver.cache[id]
+	receiver.mu.Unlock()
+	if ok && receiver.now().Sub(cached.cachedAt) < keyCacheTTL {
+		return cached.key, nil
+	}
+
+	var key ApiKey
+	err := receiver.store.FindByIdWithContext(ctx, id, &key)
+	if err != nil && !errors.Is(err, stores.ErrNotFound) {
+		return nil, err
+	}
+	cached = cachedKey{cachedAt: receiver.now()}
+	if err == nil {
+		cached.key = &key
+	}
+	receiver.mu.Lock()
+	if len(receiver.cache) >= maxCachedKeys {
+		receiver.cache = make(map[string]cachedKey)
+	}
+	receiver.cache[id] = cached
+	receiver.mu.Unlock()
+	return cached.key, nil
+}
+
+func (receiver *KeyStore) forget(id string) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	delete(receiver.cache, id)
+}
+
+// IsApiKey reports whether a token has the format of an api key rather than of a JWT
+func IsApiKey(token string) bool {
+	return strings.HasPrefix(token, keyPrefix)
+}
+
+func keyId(value string) (string, bool) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(value, keyPrefix), "_")
+	return id, ok && IsApiKey(value) && len(id) > 0 && len(secret) > 0
+}
+
+// validateScopes checks that at least one scope is granted and that all the scopes are known
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required: %s", strings.Join(Scopes, ", "))
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, knownScope := range Scopes {
+			known = known || scope == knownScope
+		}
+		if !known {
+			return fmt.Errorf("unknown scope '%s'. Supported scopes are: %s", scope, strings.Join(Scopes, ", "))
+		}
+	}
+	return nil
+}
+
+func randomHex(size int) string {
+	bytes := make([]byte, size)
+	_, _ = rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+func NewKeyStore(store stores.DocumentStore) *KeyStore {
+	return &KeyStore{store: store, cache: make(map[string]cachedKey), now: time.Now}
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github-events-microservices/model"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	Enabled bool `config:"CACHE_ENABLED" default:"true"`
+	// TTL bounds the age of a response when the write version of the collector can't be read
+	TTL time.Duration `config:"CACHE_TTL_SECONDS" default:"30" unit:"seconds" min:"1"`
+	// VersionCheckInterval is how often the write version is read, responses may be stale for that long
+	VersionCheckInterval time.Duration `config:"CACHE_VERSION_CHECK_MS" default:"1000" unit:"ms" min:"0"`
+	MaxEntries           int           `config:"CACHE_MAX_ENTRIES" default:"1000" min:"1"`
+	// MaxEntrySize is the size of the largest cached response, larger ones are served from the store every time
+	MaxEntrySize int64 `config:"CACHE_MAX_ENTRY_MB" default:"1" unit:"mb" min:"0"`
+}
+
+// VersionSource reads the write version of the collector
+type VersionSource func() (model.WriteVersion, error)
+
+// Entry is a cached response body along with its validators
+type Entry struct {
+	Body         []byte
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+	version      int64
+	storedAt     time.Time
+}
+
+// Cache keeps the responses of the api reads until the collector writes again, or until they expire when the write
+// version can't be read. The whole cache is dropped when full, like the api keys cache.
+type Cache struct {
+	options   Options
+	source    VersionSource
+	mu        sync.Mutex
+	entries   map[string]Entry
+	versionMu sync.Mutex
+	version   model.WriteVersion
+	checkedAt time.Time
+	now       func() time.Time
+}
+
+// Get returns the response cached under the key, unless it was cached at another version or it expired
+func (receiver *Cache) Get(key string, version model.WriteVersion) (Entry, bool) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	entry, ok := receiver.entries[key]
+	if !ok {
+		return Entry{}, false
+	}
+	if entry.version != version.Version || receiver.now().Sub(entry.storedAt) >= receiver.options.TTL {
+		delete(receiver.entries, key)
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Put caches a response body read at a version under the key and returns its entry. Bodies over the max entry size
+// are not cached, but their entry is returned all the same.
+func (receiver *Cache) Put(key string, version model.WriteVersion, body []byte, contentType string) Entry {
+	now := receiver.now()
+	hash := sha256.Sum256(body)
+	entry := Entry{
+		Body:         body,
+		ContentType:  contentType,
+		ETag:         `"` + hex.EncodeToString(hash[:16]) + `"`,
+		LastModified: version.UpdatedAt,
+		version:      version.Version,
+		storedAt:     now,
+	}
+	if entry.LastModified.IsZero() {
+		entry.LastModified = now.UTC()
+	}
+	if int64(len(body)) > receiver.options.MaxEntrySize {
+		return entry
+	}
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if len(receiver.entries) >= receiver.options.MaxEntries {
+		receiver.entries = make(map[string]Entry)
+	}
+	receiver.entries[key] = entry
+	return entry
+}
+
+// Version returns the write version of the collector, read at most every version check interval. It must be read
+// before the store, so a response is never cached at a later version than its data. The last read version is kept
+// on failures, so the cached responses only expire with their TTL.
+func (receiver *Cache) Version() model.WriteVersion {
+	receiver.versionMu.Lock()
+	defer receiver.versionMu.Unlock()
+	now := receiver.now()
+	if !receiver.checkedAt.IsZero() && now.Sub(receiver.checkedAt) < receiver.options.VersionCheckInterval {
+		return receiver.version
+	}
+	receiver.checkedAt = now
+	version, err := receiver.source()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to read the write version, cached responses expire after %s: %s", receiver.options.TTL, err.Error()))
+		return receiver.version
+	}
+	receiver.version = version
+	return receiver.version
+}
+
+// Key normalizes the query of a route into a cache key: only the given params are kept, missing ones take their
+// default value, and they are sorted by name
+func Key(route string, query url.Values, defaults map[string]string) string {
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := make([]string, 0, len(names))
+	for _, name := range names {
+		value := query.Get(name)
+		if len(value) == 0 {
+			value = defaults[name]
+		}
+		params = append(params, url.QueryEscape(name)+"="+url.QueryEscape(value))
+	}
+	return route + "?" + strings.Join(params, "&")
+}
+
+func New(options Options, source VersionSource) *Cache {
+	return &Cache{options: options, source: source, entries: make(map[string]Entry), now: time.Now}
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"errors"
+	"github-events-microservices/model"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCache_Get(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	written := model.NewWriteVersion(now.Add(-time.Minute))
+	var sourceErr error
+	reads := 0
+	responses := New(Options{TTL: 30 * time.Second, VersionCheckInterval: time.Second, MaxEntries: 2, MaxEntrySize: 16},
+		func() (model.WriteVersion, error) {
+			reads++
+			return written, sourceErr
+		})
+	responses.now = func() time.Time { return now }
+
+	version := responses.Version()
+	entry := responses.Put("/count?dataType=events", version, []byte(`{"count":3}`), "application/json")
+	if len(entry.ETag) != 34 || entry.ETag[0] != '"' || entry.ETag[33] != '"' {
+		t.Errorf("expected a quoted hash ETag, got: %s", entry.ETag)
+	}
+	if !entry.LastModified.Equal(written.UpdatedAt) {
+		t.Errorf("expected the last write to be the last modification, got: %s", entry.LastModified)
+	}
+	if got, ok := responses.Get("/count?dataType=events", responses.Version()); !ok || string(got.Body) != `{"count":3}` {
+		t.Errorf("expected a hit, got: %+v", got)
+	}
+	if reads != 1 {
+		t.Errorf("expected the version to be read once per check interval, got %d reads", reads)
+	}
+
+	responses.Put("/list?limit=0", version, []byte(`[{"id":"1"},{"id":"2"}]`), "application/json")
+	if _, ok := responses.Get("/list?limit=0", version); ok {
+		t.Errorf("expected the responses over the max entry size not to be cached")
+	}
+
+	// the collector wrote since the response was cached
+	now = now.Add(2 * time.Second)
+	written = model.NewWriteVersion(now)
+	if _, ok := responses.Get("/count?dataType=events", responses.Version()); ok {
+		t.Errorf("expected a miss after a write")
+	}
+
+	// the version can't be read, responses expire with their TTL
+	version = responses.Version()
+	responses.Put("/count?dataType=events", version, []byte(`{"count":4}`), "application/json")
+	sourceErr = errors.New("connection refused")
+	now = now.Add(10 * time.Second)
+	if _, ok := responses.Get("/count?dataType=events", responses.Version()); !ok {
+		t.Errorf("expected a hit while the version can't be read")
+	}
+	now = now.Add(30 * time.Second)
+	if _, ok := responses.Get("/count?dataType=events", responses.Version()); ok {
+		t.Errorf("expected a miss once expired")
+	}
+}
+
+func TestKey(t *testing.T) {
+	defaults := map[string]string{"dataType": "events", "limit": "20"}
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "defaults", query: "", want: "/list?dataType=events&limit=20"},
+		{name: "sorted params", query: "limit=5&dataType=repos", want: "/list?dataType=repos&limit=5"},
+		{name: "explicit defaults and unknown params", query: "limit=20&cacheBuster=1&dataType=", want: "/list?dataType=events&limit=20"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Key("/list", query, defaults); got != tt.want {
+				t.Errorf("expected: %s, got: %s", tt.want, got)
+			}
+		})
+	}
+}
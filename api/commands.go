@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"github-events-microservices/settings"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	configPrintCommand = "config print"
+)
+
+// splitCommand splits the command line into the command words, e.g. `config print`, and the flags that follow them
+func splitCommand(args []string) (string, []string) {
+	i := 0
+	for i < len(args) && !strings.HasPrefix(args[i], "-") {
+		i++
+	}
+	return strings.Join(args[:i], " "), args[i:]
+}
+
+// printConfig prints the effective configuration, with secrets redacted, followed by its validation errors
+func printConfig(loaded *settings.Loaded, err error) {
+	if loaded != nil {
+		printErr := loaded.Print(os.Stdout)
+		if printErr != nil {
+			slog.Error(fmt.Sprintf("failed to print configuration: %s", printErr.Error()))
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+}
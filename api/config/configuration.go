@@ -1,39 +1,70 @@
 package config
 
 import (
-	"os"
+	"errors"
+	"github-events-microservices/api/auth"
+	"github-events-microservices/api/cache"
+	"github-events-microservices/api/graph"
+	"github-events-microservices/api/ratelimit"
+	"github-events-microservices/logging"
+	"github-events-microservices/settings"
+	"github-events-microservices/stores"
+	"time"
 )
 
-var ApiConfiguration *Configuration
+// ApiConfiguration holds the default configuration until Load is called
+var ApiConfiguration = defaultConfiguration()
 
 type Configuration struct {
-	MongoDbUrl       string
-	MongoDbPort      string
-	EventsDb         string
-	EventsCollection string
-	ReposDb          string
-	ReposCollection  string
-	UsersDb          string
-	UsersCollection  string
+	Mongo            stores.MongoOptions
+	EventsDb         string        `config:"EVENTS_DB" default:"github" required:"true"`
+	EventsCollection string        `config:"EVENTS_COLLECTION" default:"events" required:"true"`
+	ReposDb          string        `config:"REPOS_DB" default:"github" required:"true"`
+	ReposCollection  string        `config:"REPOS_COLLECTION" default:"repos" required:"true"`
+	UsersDb          string        `config:"USERS_DB" default:"github" required:"true"`
+	UsersCollection  string        `config:"USERS_COLLECTION" default:"users" required:"true"`
+	RulesDb          string        `config:"RULES_DB" default:"github" required:"true"`
+	RulesCollection  string        `config:"RULES_COLLECTION" default:"rules" required:"true"`
+	MetaDb           string        `config:"META_DB" default:"github" required:"true"`
+	MetaCollection   string        `config:"META_COLLECTION" default:"meta" required:"true"`
+	EnsureIndexes    bool          `config:"ENSURE_INDEXES" default:"true"`
+	ShutdownTimeout  time.Duration `config:"SHUTDOWN_TIMEOUT_SECONDS" default:"10" unit:"seconds" min:"0"`
+	TracesExporter   string        `config:"TRACES_EXPORTER" default:"none" oneof:"otlp|stdout|none"`
+	// MaxPageSize bounds the limit of a list request, 0 leaves it unbounded and allows limit=0
+	MaxPageSize int `config:"MAX_PAGE_SIZE" default:"0" min:"0"`
+	// MaxQueryCost rejects the requests costing more tokens, 0 leaves it unbounded
+	MaxQueryCost   int   `config:"MAX_QUERY_COST" default:"0" min:"0"`
+	MaxRequestBody int64 `config:"MAX_REQUEST_BODY_MB" default:"1" unit:"mb" min:"0"`
+	// SearchPatternTimeout bounds the prefix and fuzzy searches, which scan the searched fields
+	SearchPatternTimeout time.Duration `config:"SEARCH_PATTERN_TIMEOUT_MS" default:"2000" unit:"ms" min:"1"`
+	Logging              logging.Options
+	Auth                 auth.Options
+	RateLimit            ratelimit.Options
+	Cache                cache.Options
+	Graph                graph.Options
 }
 
-func init() {
-	ApiConfiguration = &Configuration{
-		MongoDbUrl:       getOrDefault(mongoDbUrlKey, defaultMongodbUrl),
-		MongoDbPort:      getOrDefault(mongoDbPortKey, defaultMongoDbPort),
-		EventsDb:         getOrDefault(eventsDbKey, defaultDb),
-		EventsCollection: getOrDefault(eventsCollectionKey, defaultEventsCollection),
-		ReposDb:          getOrDefault(reposDbKey, defaultDb),
-		ReposCollection:  getOrDefault(reposCollectionKey, defaultReposCollection),
-		UsersDb:          getOrDefault(usersDbKey, defaultDb),
-		UsersCollection:  getOrDefault(usersCollectionKey, defaultUsersCollection),
+// Load loads the configuration from the configuration file, the env variables and the flags in args.
+// The configuration is only applied when it is valid.
+func Load(args []string) (*settings.Loaded, error) {
+	configuration := &Configuration{}
+	loaded, err := settings.Load(configuration, args)
+	if err != nil {
+		return loaded, err
 	}
+	ApiConfiguration = configuration
+	return loaded, nil
 }
 
-func getOrDefault(key string, defaultValue string) string {
-	value := os.Getenv(key)
-	if len(value) == 0 {
-		return defaultValue
+func (receiver *Configuration) Validate() error {
+	return errors.Join(receiver.Logging.Validate(), receiver.Mongo.Validate(), receiver.Auth.Validate(), receiver.RateLimit.Validate())
+}
+
+func defaultConfiguration() *Configuration {
+	configuration := &Configuration{}
+	err := settings.Defaults(configuration)
+	if err != nil {
+		panic(err)
 	}
-	return value
+	return configuration
 }
@@ -0,0 +1,205 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"github-events-microservices/stores"
+	"sort"
+	"time"
+)
+
+const (
+	NodeUser = "user"
+	NodeRepo = "repo"
+
+	// ActorField and RepoField are the event fields linking the actors to the repos
+	ActorField = "actor_login"
+	RepoField  = "repo_full_name"
+)
+
+var ErrNoPath = errors.New("no path found")
+
+type Options struct {
+	// MaxDepth bounds the depth of a request, in hops from a user to another or from a repo to another
+	MaxDepth int `config:"GRAPH_MAX_DEPTH" default:"3" min:"1"`
+	// MaxWindow bounds the days of events a graph is computed from
+	MaxWindow time.Duration `config:"GRAPH_MAX_WINDOW_DAYS" default:"90" unit:"days" min:"1"`
+	// MaxNodes bounds the nodes of a graph, the walk stops expanding it once reached
+	MaxNodes int `config:"GRAPH_MAX_NODES" default:"500" min:"2"`
+	// MaxLinks bounds the links read at every step of a walk, the most active ones are read first
+	MaxLinks int64 `config:"GRAPH_MAX_LINKS" default:"5000" min:"1"`
+}
+
+// Source returns the actor to repo links of the events whose field, ActorField or RepoField, is one of values
+type Source func(field string, values []string) ([]stores.Link, error)
+
+// Node is a user or a repo, at a distance in edges from the start of the walk
+type Node struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Label    string `json:"label"`
+	Distance int    `json:"distance"`
+}
+
+// Edge links a user to a repo it had events on
+type Edge struct {
+	Source      string    `json:"source"`
+	Target      string    `json:"target"`
+	Events      int64     `json:"events"`
+	LastEventAt time.Time `json:"last_event_at"`
+}
+
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+	// Truncated is set when MaxNodes stopped the walk, some links were left out
+	Truncated bool `json:"truncated"`
+}
+
+// Collaborators returns the graph of the users who had events on the same repos as a user, within depth hops
+func Collaborators(source Source, login string, depth int, maxNodes int) (Graph, error) {
+	walk := newWalk(userNode(login), maxNodes)
+	err := walk.run(source, 2*depth, "")
+	return walk.graph(), err
+}
+
+// RelatedRepos returns the graph of the repos sharing actors with a repo, within depth hops
+func RelatedRepos(source Source, repo string, depth int, maxNodes int) (Graph, error) {
+	walk := newWalk(repoNode(repo), maxNodes)
+	err := walk.run(source, 2*depth, "")
+	return walk.graph(), err
+}
+
+// ShortestPath returns the graph of a shortest path between two users, through the repos they had events on, or
+// ErrNoPath when none is found within depth hops
+func ShortestPath(source Source, from string, to string, depth int, maxNodes int) (Graph, error) {
+	start, end := userNode(from), userNode(to)
+	if start.ID == end.ID {
+		return Graph{Nodes: []Node{start}, Edges: []Edge{}}, nil
+	}
+	walk := newWalk(start, maxNodes)
+	err := walk.run(source, 2*depth, end.ID)
+	if err != nil {
+		return Graph{}, err
+	}
+	if _, ok := walk.nodes[end.ID]; !ok {
+		return Graph{}, fmt.Errorf("%w between %s and %s within %d hops", ErrNoPath, from, to, depth)
+	}
+	path := Graph{Nodes: []Node{}, Edges: []Edge{}}
+	for id := end.ID; ; id = walk.parents[id] {
+		path.Nodes = append([]Node{walk.nodes[id]}, path.Nodes...)
+		if id == start.ID {
+			break
+		}
+		path.Edges = append([]Edge{walk.edges[edgeKey(id, walk.parents[id])]}, path.Edges...)
+	}
+	return path, nil
+}
+
+// walk is a breadth first walk alternating between users and repos
+type walk struct {
+	start     Node
+	maxNodes  int
+	nodes     map[string]Node
+	parents   map[string]string
+	edges     map[[2]string]Edge
+	truncated bool
+}
+
+func newWalk(start Node, maxNodes int) *walk {
+	return &walk{
+		start:    start,
+		maxNodes: maxNodes,
+		nodes:    map[string]Node{start.ID: start},
+		parents:  make(map[string]string),
+		edges:    make(map[[2]string]Edge),
+	}
+}
+
+// run walks steps edges away from the start, or until the target node is reached
+func (receiver *walk) run(source Source, steps int, target string) error {
+	frontier := []Node{receiver.start}
+	for distance := 1; distance <= steps && len(frontier) > 0; distance++ {
+		field := ActorField
+		if frontier[0].Type == NodeRepo {
+			field = RepoField
+		}
+		values := make([]string, 0, len(frontier))
+		for _, node := range frontier {
+			values = append(values, node.Label)
+		}
+		links, err := source(field, values)
+		if err != nil {
+			return err
+		}
+
+		var next []Node
+		for _, link := range links {
+			from, to := userNode(link.From), repoNode(link.To)
+			if field == RepoField {
+				from, to = to, from
+			}
+			if _, ok := receiver.nodes[to.ID]; !ok {
+				if len(receiver.nodes) >= receiver.maxNodes {
+					receiver.truncated = true
+					continue
+				}
+				to.Distance = distance
+				receiver.nodes[to.ID] = to
+				receiver.parents[to.ID] = from.ID
+				next = append(next, to)
+			}
+			receiver.edges[edgeKey(from.ID, to.ID)] = Edge{
+				Source:      userNode(link.From).ID,
+				Target:      repoNode(link.To).ID,
+				Events:      link.Count,
+				LastEventAt: link.Last,
+			}
+			if to.ID == target {
+				return nil
+			}
+		}
+		frontier = next
+	}
+	return nil
+}
+
+// graph returns the walked nodes, nearest first, and their edges
+func (receiver *walk) graph() Graph {
+	graph := Graph{Nodes: make([]Node, 0, len(receiver.nodes)), Edges: make([]Edge, 0, len(receiver.edges)), Truncated: receiver.truncated}
+	for _, node := range receiver.nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		if graph.Nodes[i].Distance == graph.Nodes[j].Distance {
+			return graph.Nodes[i].ID < graph.Nodes[j].ID
+		}
+		return graph.Nodes[i].Distance < graph.Nodes[j].Distance
+	})
+	for _, edge := range receiver.edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Source == graph.Edges[j].Source {
+			return graph.Edges[i].Target < graph.Edges[j].Target
+		}
+		return graph.Edges[i].Source < graph.Edges[j].Source
+	})
+	return graph
+}
+
+// edgeKey identifies the edge of two nodes whatever their order, edges aren't directed
+func edgeKey(a string, b string) [2]string {
+	if a > b {
+		return [2]string{b, a}
+	}
+	return [2]string{a, b}
+}
+
+func userNode(login string) Node {
+	return Node{ID: NodeUser + ":" + login, Type: NodeUser, Label: login}
+}
+
+func repoNode(fullName string) Node {
+	return Node{ID: NodeRepo + ":" + fullName, Type: NodeRepo, Label: fullName}
+}
@@ -0,0 +1,167 @@
+package graph
+
+import (
+	"bytes"
+	"errors"
+	"github-events-microservices/stores"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var lastEventAt = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+// linksSource links the actors to the repos of events, given as actor/repo pairs, and counts its queries
+func linksSource(queries *int, events ...[2]string) Source {
+	return func(field string, values []string) ([]stores.Link, error) {
+		*queries++
+		counts := make(map[[2]string]int64)
+		var links []stores.Link
+		for _, event := range events {
+			value := event[0]
+			if field == RepoField {
+				value = event[1]
+			}
+			if !slices.Contains(values, value) {
+				continue
+			}
+			if counts[event] == 0 {
+				links = append(links, stores.Link{From: event[0], To: event[1], Last: lastEventAt})
+			}
+			counts[event]++
+		}
+		for i := range links {
+			links[i].Count = counts[[2]string{links[i].From, links[i].To}]
+		}
+		return links, nil
+	}
+}
+
+var events = [][2]string{
+	{"alice", "acme/api"},
+	{"alice", "acme/api"},
+	{"bob", "acme/api"},
+	{"bob", "acme/web"},
+	{"carol", "acme/web"},
+	{"dave", "other/tool"},
+}
+
+func nodeIds(graph Graph) []string {
+	ids := make([]string, 0, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		ids = append(ids, node.ID)
+	}
+	return ids
+}
+
+func TestCollaborators(t *testing.T) {
+	tests := []struct {
+		name        string
+		depth       int
+		maxNodes    int
+		wantNodes   []string
+		wantEdges   int
+		truncated   bool
+		wantQueries int
+	}{
+		{name: "direct collaborators", depth: 1, maxNodes: 10, wantNodes: []string{"user:alice", "repo:acme/api", "user:bob"}, wantEdges: 2, wantQueries: 2},
+		{name: "collaborators of collaborators", depth: 2, maxNodes: 10, wantNodes: []string{"user:alice", "repo:acme/api", "user:bob", "repo:acme/web", "user:carol"}, wantEdges: 4, wantQueries: 4},
+		{name: "truncated", depth: 2, maxNodes: 2, wantNodes: []string{"user:alice", "repo:acme/api"}, wantEdges: 1, truncated: true, wantQueries: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := 0
+			got, err := Collaborators(linksSource(&queries, events...), "alice", tt.depth, tt.maxNodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(nodeIds(got), tt.wantNodes) || len(got.Edges) != tt.wantEdges || got.Truncated != tt.truncated {
+				t.Errorf("expected nodes %v, %d edges and truncated %t, got: %+v", tt.wantNodes, tt.wantEdges, tt.truncated, got)
+			}
+			if queries != tt.wantQueries {
+				t.Errorf("expected %d queries, got: %d", tt.wantQueries, queries)
+			}
+		})
+	}
+
+	queries := 0
+	got, err := Collaborators(linksSource(&queries, events...), "alice", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Edge{Source: "user:alice", Target: "repo:acme/api", Events: 2, LastEventAt: lastEventAt}
+	if got.Edges[0] != want || got.Nodes[2].Distance != 2 {
+		t.Errorf("expected %+v and a collaborator 2 edges away, got: %+v", want, got)
+	}
+}
+
+func TestRelatedRepos(t *testing.T) {
+	queries := 0
+	got, err := RelatedRepos(linksSource(&queries, events...), "acme/web", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"repo:acme/web", "user:bob", "user:carol", "repo:acme/api"}
+	if !reflect.DeepEqual(nodeIds(got), want) || len(got.Edges) != 3 {
+		t.Errorf("expected nodes %v and 3 edges, got: %+v", want, got)
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      string
+		to        string
+		depth     int
+		wantNodes []string
+		wantErr   error
+	}{
+		{name: "path", from: "alice", to: "carol", depth: 3, wantNodes: []string{"user:alice", "repo:acme/api", "user:bob", "repo:acme/web", "user:carol"}},
+		{name: "same user", from: "alice", to: "alice", depth: 1, wantNodes: []string{"user:alice"}},
+		{name: "too deep", from: "alice", to: "carol", depth: 1, wantErr: ErrNoPath},
+		{name: "unconnected", from: "alice", to: "dave", depth: 3, wantErr: ErrNoPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := 0
+			got, err := ShortestPath(linksSource(&queries, events...), tt.from, tt.to, tt.depth, 10)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !reflect.DeepEqual(nodeIds(got), tt.wantNodes) || len(got.Edges) != len(tt.wantNodes)-1 {
+				t.Errorf("expected nodes %v linked by edges, got: %+v", tt.wantNodes, got)
+			}
+		})
+	}
+}
+
+func TestGraph_WriteGraphML(t *testing.T) {
+	graph := Graph{
+		Nodes: []Node{{ID: "user:alice", Type: NodeUser, Label: "alice"}, {ID: "repo:acme/<api>", Type: NodeRepo, Label: "acme/<api>", Distance: 1}},
+		Edges: []Edge{{Source: "user:alice", Target: "repo:acme/<api>", Events: 2, LastEventAt: lastEventAt}},
+	}
+	var buffer bytes.Buffer
+	err := graph.WriteGraphML(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`,
+		`<key id="events" for="edge" attr.name="events" attr.type="long"></key>`,
+		`<graph id="G" edgedefault="undirected">`,
+		`<node id="repo:acme/&lt;api&gt;">`,
+		`<data key="distance">1</data>`,
+		`<edge source="user:alice" target="repo:acme/&lt;api&gt;">`,
+		`<data key="last_event_at">2024-05-10T12:00:00Z</data>`,
+	} {
+		if !strings.Contains(buffer.String(), want) {
+			t.Errorf("expected the GraphML to contain %s, got: %s", want, buffer.String())
+		}
+	}
+}
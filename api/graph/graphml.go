@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+const GraphMLContentType = "application/graphml+xml"
+
+type graphML struct {
+	XMLName xml.Name       `xml:"graphml"`
+	Xmlns   string         `xml:"xmlns,attr"`
+	Keys    []graphMLKey   `xml:"key"`
+	Graph   graphMLContent `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLContent struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph as an undirected GraphML graph, for visualization tools like Gephi or yEd
+func (receiver Graph) WriteGraphML(writer io.Writer) error {
+	document := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "type", For: "node", Name: "type", Type: "string"},
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "distance", For: "node", Name: "distance", Type: "int"},
+			{ID: "events", For: "edge", Name: "events", Type: "long"},
+			{ID: "last_event_at", For: "edge", Name: "last_event_at", Type: "string"},
+		},
+		Graph: graphMLContent{ID: "G", EdgeDefault: "undirected"},
+	}
+	for _, node := range receiver.Nodes {
+		document.Graph.Nodes = append(document.Graph.Nodes, graphMLNode{ID: node.ID, Data: []graphMLData{
+			{Key: "type", Value: node.Type},
+			{Key: "label", Value: node.Label},
+			{Key: "distance", Value: strconv.Itoa(node.Distance)},
+		}})
+	}
+	for _, edge := range receiver.Edges {
+		document.Graph.Edges = append(document.Graph.Edges, graphMLEdge{Source: edge.Source, Target: edge.Target, Data: []graphMLData{
+			{Key: "events", Value: strconv.FormatInt(edge.Events, 10)},
+			{Key: "last_event_at", Value: edge.LastEventAt.UTC().Format(time.RFC3339)},
+		}})
+	}
+
+	_, err := io.WriteString(writer, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	return encoder.Encode(document)
+}
@@ -1,27 +1,166 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github-events-microservices/api/auth"
+	"github-events-microservices/api/cache"
 	"github-events-microservices/api/config"
+	"github-events-microservices/api/metrics"
 	"github-events-microservices/api/net"
+	"github-events-microservices/api/ratelimit"
 	"github-events-microservices/logging"
+	"github-events-microservices/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	slog.SetDefault(logging.Create())
+	command, args := splitCommand(os.Args[1:])
+	loaded, err := config.Load(args)
+	switch command {
+	case "":
+	case configPrintCommand:
+		printConfig(loaded, err)
+		return
+	default:
+		slog.Error(fmt.Sprintf("unknown command: '%s'. Supported commands are: %s", command, configPrintCommand))
+		os.Exit(2)
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(2)
+	}
+
+	logger, err := logging.Create(config.ApiConfiguration.Logging)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to create logger: %s", err.Error()))
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
 	slog.Info("Github Events API started")
 
-	handler := net.NewRequestsHandler(config.ApiConfiguration.MongoDbUrl, config.ApiConfiguration.MongoDbPort)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, "events-api", config.ApiConfiguration.TracesExporter)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to setup tracing: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	handler := net.NewRequestsHandler(config.ApiConfiguration.Mongo)
+	if config.ApiConfiguration.EnsureIndexes {
+		err = handler.EnsureIndexes()
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to ensure indexes: %s", err.Error()))
+		}
+	}
 
-	http.HandleFunc("/list", handler.List)
-	http.HandleFunc("/count", handler.Count)
+	authenticator, err := newAuthenticator(handler)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
 
-	err := http.ListenAndServe(":8080", nil)
+	limiter, err := ratelimit.New(config.ApiConfiguration.RateLimit)
 	if err != nil {
-		slog.Error(fmt.Sprintf("github events api failed. reason: %s", err.Error()))
+		slog.Error(fmt.Sprintf("failed to create rate limiter: %s", err.Error()))
 		os.Exit(1)
 	}
+	if !limiter.Enforced() {
+		slog.Warn("rate limiting is disabled, usage is only tracked. Set RATE_LIMIT_ENABLED to enforce the tiers")
+	}
+	responses := newResponseCache(handler)
+	// route wraps a handler with the metrics, the compression, the authorization of the scope and the rate limit of the route
+	route := func(path string, scope string, cost net.RequestCost, handler http.HandlerFunc) http.HandlerFunc {
+		return metrics.Instrument(path, net.Compress(net.Authorize(authenticator, limiter, path, scope, net.Limit(limiter, path, cost, handler))))
+	}
+
+	// health checks and metrics stay open and unlimited for probes and scrapers
+	mux := http.NewServeMux()
+	mux.HandleFunc("/list", route("/list", auth.ScopeReadEvents, net.ListCost, net.Cached(responses, "/list", net.ListCacheParams, handler.List)))
+	mux.HandleFunc(net.SearchPath, route(net.SearchPath, auth.ScopeReadEvents, net.SearchCost, net.Cached(responses, net.SearchPath, net.SearchCacheParams, handler.Search)))
+	mux.HandleFunc(net.CollaboratorsPath, route(net.CollaboratorsPath, auth.ScopeReadEvents, net.GraphCost, net.Cached(responses, net.CollaboratorsPath, net.GraphCacheParams, handler.Collaborators)))
+	mux.HandleFunc(net.RelatedReposPath, route(net.RelatedReposPath, auth.ScopeReadEvents, net.GraphCost, net.Cached(responses, net.RelatedReposPath, net.GraphCacheParams, handler.RelatedRepos)))
+	mux.HandleFunc(net.ShortestPathPath, route(net.ShortestPathPath, auth.ScopeReadEvents, net.GraphCost, net.Cached(responses, net.ShortestPathPath, net.GraphCacheParams, handler.ShortestPath)))
+	mux.HandleFunc("/count", route("/count", auth.ScopeReadStats, net.UnitCost, net.Cached(responses, "/count", net.CountCacheParams, handler.Count)))
+	// the admin routes manage keys, rules and log levels, so they are only served to authenticated admins
+	if authenticator != nil {
+		rulesHandler := route(net.RulesPath, auth.ScopeAdmin, net.UnitCost, handler.Rules().ServeHTTP)
+		mux.HandleFunc(net.RulesPath, rulesHandler)
+		mux.HandleFunc(net.RulesPath+"/", rulesHandler)
+		keysHandler := route(net.KeysPath, auth.ScopeAdmin, net.UnitCost, net.NewKeysHandler(handler.KeyStore(), limiter).ServeHTTP)
+		mux.HandleFunc(net.KeysPath, keysHandler)
+		mux.HandleFunc(net.KeysPath+"/", keysHandler)
+		botsHandler := route(net.BotsPath, auth.ScopeAdmin, net.UnitCost, handler.Bots().ServeHTTP)
+		mux.HandleFunc(net.BotsPath, botsHandler)
+		mux.HandleFunc(net.BotsPath+"/", botsHandler)
+		mux.HandleFunc(net.UsagePath, route(net.UsagePath, auth.ScopeAdmin, net.UnitCost, net.UsageHandler(limiter)))
+		mux.HandleFunc("/admin/loglevel", net.Authorize(authenticator, limiter, "/admin/loglevel", auth.ScopeAdmin, logging.LevelHandler))
+	}
+	mux.HandleFunc("/healthz", handler.Healthz)
+	mux.HandleFunc("/readyz", handler.Readyz)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{Addr: ":8080", Handler: otelhttp.NewHandler(net.WithRequestId(mux), "events-api")}
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErrors:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(fmt.Sprintf("github events api failed. reason: %s", err.Error()))
+			os.Exit(1)
+		}
+	case <-ctx.Done():
+		slog.Info("shutdown signal received, draining connections")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ApiConfiguration.ShutdownTimeout)
+		defer cancel()
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to drain connections: %s", err.Error()))
+		}
+		err = shutdownTracing(shutdownCtx)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to flush traces: %s", err.Error()))
+		}
+	}
+
+	err = handler.Close()
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to close stores: %s", err.Error()))
+		os.Exit(1)
+	}
+	slog.Info("Github Events API stopped")
+}
+
+// newAuthenticator returns nil when AUTH_ENABLED is unset, which leaves the read routes open and the admin routes
+// unregistered
+func newAuthenticator(handler *net.RequestsHandler) (*auth.Authenticator, error) {
+	if !config.ApiConfiguration.Auth.Enabled {
+		slog.Warn("authentication is disabled, the read routes are open and the admin routes are not served. Set AUTH_ENABLED to require api keys or JWTs")
+		return nil, nil
+	}
+	authenticator, err := auth.NewAuthenticator(config.ApiConfiguration.Auth, handler.KeyStore())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create authenticator: %w", err)
+	}
+	return authenticator, nil
+}
+
+// newResponseCache returns nil when CACHE_ENABLED is not set, which leaves the reads uncached
+func newResponseCache(handler *net.RequestsHandler) *cache.Cache {
+	if !config.ApiConfiguration.Cache.Enabled {
+		return nil
+	}
+	return cache.New(config.ApiConfiguration.Cache, handler.WriteVersion)
 }
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "events_api"
+
+var (
+	Requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Number of handled requests by route and status code.",
+	}, []string{"route", "code"})
+	RequestLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Time it takes to handle a request.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+	StoreQueryLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_query_duration_seconds",
+		Help:      "Time it takes to query a store.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"data_type", "operation"})
+	AuthRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_rejected_total",
+		Help:      "Number of rejected requests by route and reason (missing, invalid or forbidden).",
+	}, []string{"route", "reason"})
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Number of requests rejected by route, tier and reason (rate, quota or cost).",
+	}, []string{"route", "tier", "reason"})
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Number of cached reads by route and result (hit or miss).",
+	}, []string{"route", "result"})
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (receiver *statusRecorder) WriteHeader(status int) {
+	receiver.status = status
+	receiver.ResponseWriter.WriteHeader(status)
+}
+
+// Instrument counts the requests of a route by status code and records their latency
+func Instrument(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		handler(recorder, request)
+		RequestLatency.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		Requests.WithLabelValues(route, strconv.Itoa(recorder.status)).Inc()
+	}
+}
+
+// ObserveStoreQuery records the duration of a store query started at start
+func ObserveStoreQuery(dataType string, operation string, start time.Time) {
+	StoreQueryLatency.WithLabelValues(dataType, operation).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   string
+		handler http.HandlerFunc
+		code    string
+	}{
+		{
+			name:    "implicit ok",
+			route:   "/ok",
+			handler: func(writer http.ResponseWriter, request *http.Request) { _, _ = writer.Write([]byte("[]")) },
+			code:    "200",
+		},
+		{
+			name:    "bad request",
+			route:   "/bad",
+			handler: func(writer http.ResponseWriter, request *http.Request) { writer.WriteHeader(http.StatusBadRequest) },
+			code:    "400",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Instrument(tt.route, tt.handler)
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.route, nil))
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.route, nil))
+
+			got := testutil.ToFloat64(Requests.WithLabelValues(tt.route, tt.code))
+			if got != 2 {
+				t.Errorf("requests_total{route=%s, code=%s} = %v, want 2", tt.route, tt.code, got)
+			}
+			if testutil.CollectAndCount(RequestLatency) == 0 {
+				t.Errorf("expected request latency to be recorded")
+			}
+		})
+	}
+}
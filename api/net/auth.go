@@ -0,0 +1,66 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"github-events-microservices/api/auth"
+	"github-events-microservices/api/metrics"
+	"github-events-microservices/api/ratelimit"
+	"log/slog"
+	"net/http"
+)
+
+type auditRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (receiver *auditRecorder) WriteHeader(status int) {
+	receiver.status = status
+	receiver.ResponseWriter.WriteHeader(status)
+}
+
+// Authorize authenticates the requests of a route and checks that their principal was granted the scope.
+// Authorized requests are audit logged along with their principal. A nil authenticator leaves the route open.
+// Rejected credentials take a token from the bucket of the client ip in the anonymous tier, and once it is empty they
+// get a 429 rather than a 401. Valid credentials are never limited by the ip, only by the tier of their principal.
+func Authorize(authenticator *auth.Authenticator, limiter *ratelimit.Limiter, route string, scope string, handler http.HandlerFunc) http.HandlerFunc {
+	if authenticator == nil {
+		return handler
+	}
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, err := authenticator.Authenticate(request)
+		if errors.Is(err, auth.ErrMissingCredentials) || errors.Is(err, auth.ErrInvalidCredentials) {
+			reason := "invalid"
+			if errors.Is(err, auth.ErrMissingCredentials) {
+				reason = "missing"
+			}
+			metrics.AuthRejected.WithLabelValues(route, reason).Inc()
+			slog.WarnContext(request.Context(), fmt.Sprintf("audit: rejected %s %s: %s", request.Method, request.URL.RequestURI(), err.Error()))
+			client := "ip:" + clientIp(request)
+			if decision := limiter.Allow(client, ratelimit.TierAnonymous, 1); !decision.Allowed {
+				writeRateLimited(writer, request, route, client, ratelimit.TierAnonymous, decision)
+				return
+			}
+			writer.Header().Set("WWW-Authenticate", `Bearer realm="events-api"`)
+			writeError(writer, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), fmt.Sprintf("failed to authenticate request: %s", err.Error()))
+			writeError(writer, http.StatusInternalServerError, "failed to authenticate request")
+			return
+		}
+		if !principal.HasScope(scope) {
+			metrics.AuthRejected.WithLabelValues(route, "forbidden").Inc()
+			slog.WarnContext(request.Context(), fmt.Sprintf("audit: forbidden %s %s by %s: missing scope %s", request.Method, request.URL.RequestURI(), principal, scope))
+			writeError(writer, http.StatusForbidden, fmt.Sprintf("missing scope '%s'", scope))
+			return
+		}
+
+		ctx := auth.WithPrincipal(request.Context(), principal)
+		recorder := &auditRecorder{ResponseWriter: writer, status: http.StatusOK}
+		handler(recorder, request.WithContext(ctx))
+		slog.InfoContext(ctx, fmt.Sprintf("audit: %s %s by %s: %d", request.Method, request.URL.RequestURI(), principal, recorder.status))
+	}
+}
@@ -0,0 +1,255 @@
+package net
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github-events-microservices/api/auth"
+	"github-events-microservices/api/config"
+	"github-events-microservices/model"
+	"github-events-microservices/stores"
+	"go.mongodb.org/mongo-driver/bson"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const BotsPath = "/admin/bots"
+
+// botsStore reads the users and writes their bot reviews
+type botsStore interface {
+	stores.FilteredStore
+	UpdateAllByIdWithContext(context.Context, map[interface{}]interface{}) error
+}
+
+// BotsHandler reviews the bot detection of the collector: GET /admin/bots lists the suspected and reviewed users,
+// most suspicious first, GET /admin/bots/{login} returns one, PUT /admin/bots/{login} overrides its detection with a
+// review and DELETE /admin/bots/{login} removes the review.
+type BotsHandler struct {
+	store botsStore
+	meta  stores.ReadWriteStore
+	now   func() time.Time
+}
+
+// BotStatus is the bot detection of a user along with its review. Bot is the review, or else the suspicion.
+type BotStatus struct {
+	ID         int64     `json:"id"`
+	Login      string    `json:"login"`
+	Bot        bool      `json:"bot"`
+	Score      int       `json:"score"`
+	Suspected  bool      `json:"suspected"`
+	Reasons    []string  `json:"reasons,omitempty"`
+	ScoredAt   time.Time `json:"scored_at"`
+	Review     string    `json:"review,omitempty"`
+	ReviewedBy string    `json:"reviewed_by,omitempty"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+}
+
+type botReview struct {
+	Review string `json:"review"`
+}
+
+func (receiver BotsHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	login := strings.Trim(strings.TrimPrefix(request.URL.Path, BotsPath), "/")
+	switch {
+	case len(login) == 0 && request.Method == http.MethodGet:
+		receiver.list(writer, request)
+	case len(login) > 0 && request.Method == http.MethodGet:
+		receiver.get(writer, request, login)
+	case len(login) > 0 && request.Method == http.MethodPut:
+		receiver.review(writer, request, login)
+	case len(login) > 0 && request.Method == http.MethodDelete:
+		receiver.clear(writer, request, login)
+	default:
+		writeError(writer, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed on %s", request.Method, request.URL.Path))
+	}
+}
+
+func (receiver BotsHandler) list(writer http.ResponseWriter, request *http.Request) {
+	limit, err := getLimit(request)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	users := make([]model.User, 0)
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "bot_suspected", Value: true}},
+		bson.D{{Key: "bot_review", Value: bson.D{{Key: "$in", Value: bson.A{model.BotReviewBot, model.BotReviewHuman}}}}},
+	}}}
+	err = receiver.store.GetFilteredWithContext(request.Context(), filter, *limit, stores.OrderBy{Column: "bot_score", Order: -1}, &users)
+	if err != nil {
+		slog.ErrorContext(request.Context(), fmt.Sprintf("failed to list bots: %s", err.Error()))
+		writeError(writer, http.StatusInternalServerError, "failed to list bots")
+		return
+	}
+	statuses := make([]BotStatus, 0, len(users))
+	for _, user := range users {
+		statuses = append(statuses, newBotStatus(user))
+	}
+	writeJsonResponse(writer, statuses, "bots")
+}
+
+func (receiver BotsHandler) get(writer http.ResponseWriter, request *http.Request, login string) {
+	user, err := receiver.find(request.Context(), login)
+	if err != nil {
+		receiver.storeError(writer, request, fmt.Sprintf("failed to get user '%s'", login), err)
+		return
+	}
+	writeJsonResponse(writer, newBotStatus(user), "bot")
+}
+
+func (receiver BotsHandler) review(writer http.ResponseWriter, request *http.Request, login string) {
+	var review botReview
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxRuleBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&review)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, fmt.Sprintf("invalid review: %s", err.Error()))
+		return
+	}
+	if review.Review != model.BotReviewBot && review.Review != model.BotReviewHuman {
+		writeError(writer, http.StatusBadRequest, fmt.Sprintf("invalid review: '%s'. Supported reviews are: %s, %s", review.Review, model.BotReviewBot, model.BotReviewHuman))
+		return
+	}
+	user, err := receiver.find(request.Context(), login)
+	if err != nil {
+		receiver.storeError(writer, request, fmt.Sprintf("failed to review user '%s'", login), err)
+		return
+	}
+
+	user.BotReview = review.Review
+	user.BotReviewedAt = receiver.now().UTC()
+	user.BotReviewedBy = ""
+	if principal, ok := auth.PrincipalFrom(request.Context()); ok {
+		user.BotReviewedBy = principal.String()
+	}
+	err = receiver.update(request.Context(), user)
+	if err != nil {
+		receiver.storeError(writer, request, fmt.Sprintf("failed to review user '%s'", login), err)
+		return
+	}
+	slog.InfoContext(request.Context(), fmt.Sprintf("reviewed user %s as %s", login, review.Review))
+	writeJsonResponse(writer, newBotStatus(user), "bot")
+}
+
+func (receiver BotsHandler) clear(writer http.ResponseWriter, request *http.Request, login string) {
+	user, err := receiver.find(request.Context(), login)
+	if err == nil {
+		user.BotReview, user.BotReviewedBy, user.BotReviewedAt = "", "", time.Time{}
+		err = receiver.update(request.Context(), user)
+	}
+	if err != nil {
+		receiver.storeError(writer, request, fmt.Sprintf("failed to remove the review of user '%s'", login), err)
+		return
+	}
+	slog.InfoContext(request.Context(), fmt.Sprintf("removed the review of user %s", login))
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (receiver BotsHandler) find(ctx context.Context, login string) (model.User, error) {
+	users := make([]model.User, 0)
+	err := receiver.store.GetFilteredWithContext(ctx, bson.D{{Key: "login", Value: login}}, 1, stores.OrderBy{}, &users)
+	if err != nil {
+		return model.User{}, err
+	}
+	if len(users) == 0 {
+		return model.User{}, stores.ErrNotFound
+	}
+	return users[0], nil
+}
+
+// update writes the review of a user, and bumps the write version so the cached responses excluding bots are dropped
+func (receiver BotsHandler) update(ctx context.Context, user model.User) error {
+	review := bson.D{
+		{Key: "bot_review", Value: user.BotReview},
+		{Key: "bot_reviewed_by", Value: user.BotReviewedBy},
+		{Key: "bot_reviewed_at", Value: user.BotReviewedAt},
+	}
+	err := receiver.store.UpdateAllByIdWithContext(ctx, map[interface{}]interface{}{user.ID: review})
+	if err != nil {
+		return err
+	}
+	version := model.NewWriteVersion(receiver.now())
+	err = receiver.meta.UpdateAllByIdWithContext(ctx, map[interface{}]interface{}{version.ID: version})
+	if err != nil {
+		slog.WarnContext(ctx, fmt.Sprintf("failed to record write version: %s", err.Error()))
+	}
+	return nil
+}
+
+func (receiver BotsHandler) storeError(writer http.ResponseWriter, request *http.Request, message string, err error) {
+	if errors.Is(err, stores.ErrNotFound) {
+		writeError(writer, http.StatusNotFound, fmt.Sprintf("%s: user not found", message))
+		return
+	}
+	slog.ErrorContext(request.Context(), fmt.Sprintf("%s: %s", message, err.Error()))
+	writeError(writer, http.StatusInternalServerError, message)
+}
+
+func newBotStatus(user model.User) BotStatus {
+	return BotStatus{
+		ID:         user.ID,
+		Login:      user.Login,
+		Bot:        user.IsBot(),
+		Score:      user.BotScore,
+		Suspected:  user.BotSuspected,
+		Reasons:    user.BotReasons,
+		ScoredAt:   user.BotScoredAt,
+		Review:     user.BotReview,
+		ReviewedBy: user.BotReviewedBy,
+		ReviewedAt: user.BotReviewedAt,
+	}
+}
+
+func NewBotsHandler(store botsStore, meta stores.ReadWriteStore) *BotsHandler {
+	return &BotsHandler{store: store, meta: meta, now: time.Now}
+}
+
+// excludeBots reads the excludeBots param of a list or a count, which only applies to events and users
+func excludeBots(request *http.Request, dataType string) (bool, error) {
+	value := request.URL.Query().Get(config.ExcludeBotsParam)
+	if len(value) == 0 {
+		return false, nil
+	}
+	exclude, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid '%s': %s", config.ExcludeBotsParam, value)
+	}
+	if exclude && dataType != config.ApiConfiguration.EventsCollection && dataType != config.ApiConfiguration.UsersCollection {
+		return false, fmt.Errorf("'%s' is only supported for %s and %s", config.ExcludeBotsParam, config.ApiConfiguration.EventsCollection, config.ApiConfiguration.UsersCollection)
+	}
+	return exclude, nil
+}
+
+// humansFilter returns the filter excluding the bots among the users
+func humansFilter() bson.D {
+	return bson.D{{Key: "$nor", Value: botsConditions()}}
+}
+
+// botActors excludes the events whose actor is a bot among the users. The users are joined by the database, which
+// requires them to be in the same one as the events.
+func botActors() (stores.Exclusion, error) {
+	if config.ApiConfiguration.EventsDb != config.ApiConfiguration.UsersDb {
+		return stores.Exclusion{}, errors.New("the events of bots can only be excluded when EVENTS_DB and USERS_DB are the same")
+	}
+	return stores.Exclusion{
+		LocalField:   "actor_login",
+		Collection:   config.ApiConfiguration.UsersCollection,
+		ForeignField: "login",
+		Filter:       bson.D{{Key: "$or", Value: botsConditions()}},
+	}, nil
+}
+
+// botsConditions match the bots like model.User IsBot: reviewed as bots, or suspected and not reviewed
+func botsConditions() bson.A {
+	return bson.A{
+		bson.D{{Key: "bot_review", Value: model.BotReviewBot}},
+		bson.D{
+			{Key: "bot_suspected", Value: true},
+			{Key: "bot_review", Value: bson.D{{Key: "$nin", Value: bson.A{model.BotReviewBot, model.BotReviewHuman}}}},
+		},
+	}
+}
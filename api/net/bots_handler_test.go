@@ -0,0 +1,213 @@
+package net
+
+import (
+	"context"
+	"encoding/json"
+	"github-events-microservices/api/config"
+	"github-events-microservices/model"
+	"github-events-microservices/stores"
+	mockstores "github-events-microservices/stores/mocks"
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// filteredStoreMock is a read write store that can be filtered, as the mongo stores are
+type filteredStoreMock struct {
+	*mockstores.MockReadWriteStore
+	*mockstores.MockFilteredStore
+}
+
+// excludingStoreMock is a read store excluding the documents joined with another collection, as the mongo stores are
+type excludingStoreMock struct {
+	*mockstores.MockReadWriteStore
+	*mockstores.MockExcludingStore
+}
+
+func TestBotsHandler_ServeHTTP(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	spammer := model.User{ID: 1, Login: "spammer", BotScore: 80, BotSuspected: true, BotReasons: []string{"event_rate"}, BotScoredAt: now.Add(-time.Hour)}
+	findSpammer := func(_ context.Context, filter bson.D, limit int64, orderBy stores.OrderBy, results interface{}) error {
+		if !reflect.DeepEqual(filter, bson.D{{Key: "login", Value: "spammer"}}) || limit != 1 {
+			t.Errorf("unexpected find: %v %d", filter, limit)
+		}
+		*results.(*[]model.User) = []model.User{spammer}
+		return nil
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		expect     func(users *mockstores.MockFilteredStore, writes *mockstores.MockReadWriteStore, meta *mockstores.MockReadWriteStore)
+		wantCode   int
+		wantStatus *BotStatus
+	}{
+		{
+			name:   "lists the suspected and reviewed users",
+			method: http.MethodGet,
+			path:   "/admin/bots?limit=5",
+			expect: func(users *mockstores.MockFilteredStore, writes *mockstores.MockReadWriteStore, meta *mockstores.MockReadWriteStore) {
+				users.EXPECT().GetFilteredWithContext(gomock.Any(), gomock.Any(), int64(5), stores.OrderBy{Column: "bot_score", Order: -1}, gomock.Any()).
+					DoAndReturn(func(_ context.Context, filter bson.D, limit int64, orderBy stores.OrderBy, results interface{}) error {
+						*results.(*[]model.User) = []model.User{spammer}
+						return nil
+					})
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:   "gets a user",
+			method: http.MethodGet,
+			path:   "/admin/bots/spammer",
+			expect: func(users *mockstores.MockFilteredStore, writes *mockstores.MockReadWriteStore, meta *mockstores.MockReadWriteStore) {
+				users.EXPECT().GetFilteredWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(findSpammer)
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: &BotStatus{ID: 1, Login: "spammer", Bot: true, Score: 80, Suspected: true, Reasons: []string{"event_rate"}, ScoredAt: now.Add(-time.Hour)},
+		},
+		{
+			name:   "unknown user",
+			method: http.MethodGet,
+			path:   "/admin/bots/nobody",
+			expect: func(users *mockstores.MockFilteredStore, writes *mockstores.MockReadWriteStore, meta *mockstores.MockReadWriteStore) {
+				users.EXPECT().GetFilteredWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:   "reviews a suspected user as a human",
+			method: http.MethodPut,
+			path:   "/admin/bots/spammer",
+			body:   `{"review":"human"}`,
+			expect: func(users *mockstores.MockFilteredStore, writes *mockstores.MockReadWriteStore, meta *mockstores.MockReadWriteStore) {
+				users.EXPECT().GetFilteredWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(findSpammer)
+				writes.EXPECT().UpdateAllByIdWithContext(gomock.Any(), map[interface{}]interface{}{int64(1): bson.D{
+					{Key: "bot_review", Value: model.BotReviewHuman},
+					{Key: "bot_reviewed_by", Value: ""},
+					{Key: "bot_reviewed_at", Value: now},
+				}}).Return(nil)
+				meta.EXPECT().UpdateAllByIdWithContext(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: &BotStatus{ID: 1, Login: "spammer", Score: 80, Suspected: true, Reasons: []string{"event_rate"}, ScoredAt: now.Add(-time.Hour), Review: model.BotReviewHuman, ReviewedAt: now},
+		},
+		{
+			name:   "rejects unknown reviews",
+			method: http.MethodPut,
+			path:   "/admin/bots/spammer",
+			body:   `{"review":"maybe"}`,
+			expect: func(users *mockstores.MockFilteredStore, writes *mockstores.MockReadWriteStore, meta *mockstores.MockReadWriteStore) {
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:   "removes a review",
+			method: http.MethodDelete,
+			path:   "/admin/bots/spammer",
+			expect: func(users *mockstores.MockFilteredStore, writes *mockstores.MockReadWriteStore, meta *mockstores.MockReadWriteStore) {
+				users.EXPECT().GetFilteredWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(findSpammer)
+				writes.EXPECT().UpdateAllByIdWithContext(gomock.Any(), map[interface{}]interface{}{int64(1): bson.D{
+					{Key: "bot_review", Value: ""},
+					{Key: "bot_reviewed_by", Value: ""},
+					{Key: "bot_reviewed_at", Value: time.Time{}},
+				}}).Return(nil)
+				meta.EXPECT().UpdateAllByIdWithContext(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:   "method not allowed",
+			method: http.MethodPost,
+			path:   "/admin/bots",
+			expect: func(users *mockstores.MockFilteredStore, writes *mockstores.MockReadWriteStore, meta *mockstores.MockReadWriteStore) {
+			},
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := mockstores.NewMockFilteredStore(mockCtrl)
+			writes := mockstores.NewMockReadWriteStore(mockCtrl)
+			meta := mockstores.NewMockReadWriteStore(mockCtrl)
+			tt.expect(users, writes, meta)
+			handler := NewBotsHandler(filteredStoreMock{writes, users}, meta)
+			handler.now = func() time.Time { return now }
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("expected: %d, got: %d %s", tt.wantCode, recorder.Code, recorder.Body.String())
+			}
+			if tt.wantStatus != nil {
+				var status BotStatus
+				err := json.Unmarshal(recorder.Body.Bytes(), &status)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(status, *tt.wantStatus) {
+					t.Errorf("expected: %+v, got: %+v", *tt.wantStatus, status)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestsHandler_excludeBots(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	users := mockstores.NewMockFilteredStore(mockCtrl)
+	events := mockstores.NewMockExcludingStore(mockCtrl)
+	receiver := RequestsHandler{
+		storesMap: map[string]stores.ReadStore{
+			config.ApiConfiguration.UsersCollection:  filteredStoreMock{mockstores.NewMockReadWriteStore(mockCtrl), users},
+			config.ApiConfiguration.EventsCollection: excludingStoreMock{mockstores.NewMockReadWriteStore(mockCtrl), events},
+			config.ApiConfiguration.ReposCollection:  mockstores.NewMockReadStore(mockCtrl),
+		},
+		supportedDataTypes: []string{config.ApiConfiguration.EventsCollection, config.ApiConfiguration.ReposCollection, config.ApiConfiguration.UsersCollection},
+	}
+
+	// the bots are joined by the store rather than loaded
+	botEvents := stores.Exclusion{LocalField: "actor_login", Collection: config.ApiConfiguration.UsersCollection, ForeignField: "login", Filter: bson.D{{Key: "$or", Value: botsConditions()}}}
+	events.EXPECT().GetExcludingWithContext(gomock.Any(), botEvents, int64(20), gomock.Any(), gomock.Any()).Return(nil)
+	events.EXPECT().CountExcludingWithContext(gomock.Any(), botEvents).Return(int64(42), nil)
+	users.EXPECT().CountFilteredWithContext(gomock.Any(), bson.D{{Key: "$nor", Value: botsConditions()}}).Return(int64(7), nil)
+
+	recorder := httptest.NewRecorder()
+	receiver.List(recorder, httptest.NewRequest(http.MethodGet, "/list?dataType=events&excludeBots=true", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected: 200, got: %d %s", recorder.Code, recorder.Body.String())
+	}
+	for target, want := range map[string]string{
+		"/count?dataType=events&excludeBots=true": `{"count":42}`,
+		"/count?dataType=users&excludeBots=1":     `{"count":7}`,
+	} {
+		recorder := httptest.NewRecorder()
+		receiver.Count(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), want) {
+			t.Errorf("expected %s for '%s', got: %d %s", want, target, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	for _, target := range []string{"/list?dataType=repos&excludeBots=true", "/count?dataType=events&excludeBots=maybe"} {
+		recorder := httptest.NewRecorder()
+		if strings.HasPrefix(target, "/list") {
+			receiver.List(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		} else {
+			receiver.Count(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		}
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for '%s', got: %d", target, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package net
+
+import (
+	"bytes"
+	"github-events-microservices/api/cache"
+	"github-events-microservices/api/config"
+	"github-events-microservices/api/metrics"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ListCacheParams, CountCacheParams, SearchCacheParams and GraphCacheParams are the params of the cached reads along with their default value
+	ListCacheParams = map[string]string{
+		config.DataType:                config.DefaultDataType,
+		config.LimitParamKey:           strconv.Itoa(config.DefaultLimit),
+		config.OrderByColumnQueryParam: config.DefaultOrderByColumn,
+		config.OrderTypeQueryParam:     config.Ascending,
+		config.ExcludeBotsParam:        "false",
+	}
+	CountCacheParams  = map[string]string{config.DataType: config.DefaultDataType, config.ExcludeBotsParam: "false"}
+	SearchCacheParams = map[string]string{
+		searchQueryParam:     "",
+		searchTypesParam:     "",
+		searchOffsetParam:    "0",
+		config.LimitParamKey: strconv.Itoa(defaultSearchLimit),
+	}
+	// the default depth and days depend on the route and the configuration, their defaults aren't normalized
+	GraphCacheParams = map[string]string{
+		graphUserParam:   "",
+		graphRepoParam:   "",
+		graphFromParam:   "",
+		graphToParam:     "",
+		graphDepthParam:  "",
+		graphDaysParam:   "",
+		graphFormatParam: graphFormatJson,
+	}
+)
+
+// bodyRecorder buffers a response, so it can be cached before being sent
+type bodyRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (receiver *bodyRecorder) Header() http.Header {
+	return receiver.header
+}
+
+func (receiver *bodyRecorder) WriteHeader(status int) {
+	receiver.status = status
+}
+
+func (receiver *bodyRecorder) Write(data []byte) (int, error) {
+	return receiver.body.Write(data)
+}
+
+// Cached serves the GET requests of a route from the cache, keyed by their params, and answers the conditional
+// requests whose If-None-Match or If-Modified-Since validators still hold with a 304. Only successful responses are
+// cached. A nil cache leaves the route uncached.
+func Cached(responses *cache.Cache, route string, params map[string]string, handler http.HandlerFunc) http.HandlerFunc {
+	if responses == nil {
+		return handler
+	}
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet {
+			handler(writer, request)
+			return
+		}
+		key := cache.Key(route, request.URL.Query(), params)
+		version := responses.Version()
+		entry, ok := responses.Get(key, version)
+		if ok {
+			metrics.CacheRequests.WithLabelValues(route, "hit").Inc()
+			writeEntry(writer, request, entry)
+			return
+		}
+
+		metrics.CacheRequests.WithLabelValues(route, "miss").Inc()
+		recorder := &bodyRecorder{header: http.Header{}, status: http.StatusOK}
+		handler(recorder, request)
+		if recorder.status != http.StatusOK {
+			for name, values := range recorder.header {
+				writer.Header()[name] = values
+			}
+			writer.WriteHeader(recorder.status)
+			_, _ = writer.Write(recorder.body.Bytes())
+			return
+		}
+		writeEntry(writer, request, responses.Put(key, version, recorder.body.Bytes(), recorder.header.Get("Content-Type")))
+	}
+}
+
+// writeEntry sends a cached response with its validators, or a 304 when the client has it already.
+// Clients must revalidate before reusing it.
+func writeEntry(writer http.ResponseWriter, request *http.Request, entry cache.Entry) {
+	writer.Header().Set("ETag", entry.ETag)
+	writer.Header().Set("Last-Modified", entry.LastModified.UTC().Format(http.TimeFormat))
+	writer.Header().Set("Cache-Control", "no-cache")
+	if notModified(request, entry) {
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writer.Header().Set("Content-Type", entry.ContentType)
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write(entry.Body)
+}
+
+// notModified evaluates If-None-Match, or else If-Modified-Since, with the weak comparison of RFC 9110
+func notModified(request *http.Request, entry cache.Entry) bool {
+	if ifNoneMatch := request.Header.Get("If-None-Match"); len(ifNoneMatch) > 0 {
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == entry.ETag {
+				return true
+			}
+		}
+		return false
+	}
+	modifiedSince, err := http.ParseTime(request.Header.Get("If-Modified-Since"))
+	return err == nil && !entry.LastModified.Truncate(time.Second).After(modifiedSince)
+}
@@ -0,0 +1,134 @@
+package net
+
+import (
+	"compress/gzip"
+	"github-events-microservices/api/cache"
+	"github-events-microservices/model"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCached(t *testing.T) {
+	written := model.NewWriteVersion(time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC))
+	responses := cache.New(cache.Options{TTL: time.Minute, MaxEntries: 10, MaxEntrySize: 1024}, func() (model.WriteVersion, error) {
+		return written, nil
+	})
+	calls := 0
+	handler := Cached(responses, "/count", CountCacheParams, func(writer http.ResponseWriter, request *http.Request) {
+		calls++
+		if request.URL.Query().Get("dataType") == "unknown" {
+			writeError(writer, http.StatusBadRequest, "unknown data type")
+			return
+		}
+		writeJsonResponse(writer, DataCount{Count: int64(calls)}, "count")
+	})
+	serve := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		return recorder
+	}
+
+	first := serve("/count", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || first.Body.String() != `{"count":1}` || len(etag) == 0 {
+		t.Fatalf("expected a response with an ETag, got: %d %s %v", first.Code, first.Body.String(), first.Header())
+	}
+	if first.Header().Get("Last-Modified") != "Fri, 10 May 2024 12:00:00 GMT" || first.Header().Get("Content-Type") != jsonContentType {
+		t.Errorf("expected the last write as last modification, got: %v", first.Header())
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		headers  map[string]string
+		wantCode int
+		wantBody string
+	}{
+		{name: "hit of a normalized query", target: "/count?dataType=events&other=1", wantCode: http.StatusOK, wantBody: `{"count":1}`},
+		{name: "matching If-None-Match", target: "/count", headers: map[string]string{"If-None-Match": `"other", W/` + etag}, wantCode: http.StatusNotModified},
+		{name: "stale If-None-Match", target: "/count", headers: map[string]string{"If-None-Match": `"other"`}, wantCode: http.StatusOK, wantBody: `{"count":1}`},
+		{name: "If-Modified-Since the last write", target: "/count", headers: map[string]string{"If-Modified-Since": "Fri, 10 May 2024 12:00:00 GMT"}, wantCode: http.StatusNotModified},
+		{name: "If-Modified-Since before the last write", target: "/count", headers: map[string]string{"If-Modified-Since": "Fri, 10 May 2024 11:59:59 GMT"}, wantCode: http.StatusOK, wantBody: `{"count":1}`},
+		{name: "errors are not cached", target: "/count?dataType=unknown", wantCode: http.StatusBadRequest, wantBody: `{"error":"unknown data type"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := serve(tt.target, tt.headers)
+			if got.Code != tt.wantCode || got.Body.String() != tt.wantBody {
+				t.Errorf("expected: %d %s, got: %d %s", tt.wantCode, tt.wantBody, got.Code, got.Body.String())
+			}
+		})
+	}
+	if serve("/count?dataType=unknown", nil).Code != http.StatusBadRequest || calls != 3 {
+		t.Errorf("expected the store to be read on misses only, got %d calls", calls)
+	}
+
+	written = model.NewWriteVersion(written.UpdatedAt.Add(time.Minute))
+	if got := serve("/count", map[string]string{"If-None-Match": etag}); got.Code != http.StatusOK || got.Body.String() != `{"count":4}` {
+		t.Errorf("expected a fresh response after a write, got: %d %s", got.Code, got.Body.String())
+	}
+}
+
+func TestCompress(t *testing.T) {
+	handler := Compress(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/empty" {
+			writer.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writeJsonResponse(writer, DataCount{Count: 42}, "count")
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantEncoding   string
+		decode         func(io.Reader) (io.Reader, error)
+	}{
+		{name: "identity", path: "/count"},
+		{name: "gzip", path: "/count", acceptEncoding: "gzip, deflate", wantEncoding: "gzip", decode: func(body io.Reader) (io.Reader, error) { return gzip.NewReader(body) }},
+		{name: "brotli", path: "/count", acceptEncoding: "gzip;q=0.5, br", wantEncoding: "br", decode: func(body io.Reader) (io.Reader, error) { return brotli.NewReader(body), nil }},
+		{name: "refused brotli", path: "/count", acceptEncoding: "br;q=0, *", wantEncoding: "gzip", decode: func(body io.Reader) (io.Reader, error) { return gzip.NewReader(body) }},
+		{name: "no body", path: "/empty", acceptEncoding: "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			request.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			recorder := httptest.NewRecorder()
+			handler(recorder, request)
+			if encoding := recorder.Header().Get("Content-Encoding"); encoding != tt.wantEncoding {
+				t.Fatalf("expected encoding '%s', got: '%s'", tt.wantEncoding, encoding)
+			}
+			if recorder.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("expected a Vary header, got: %v", recorder.Header())
+			}
+			if tt.path == "/empty" {
+				if recorder.Body.Len() != 0 {
+					t.Errorf("expected no body, got: %v", recorder.Body.Bytes())
+				}
+				return
+			}
+			var body io.Reader = recorder.Body
+			if tt.decode != nil {
+				var err error
+				body, err = tt.decode(body)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			content, err := io.ReadAll(body)
+			if err != nil || string(content) != `{"count":42}` {
+				t.Errorf("expected the json response, got: %s (%v)", content, err)
+			}
+		})
+	}
+}
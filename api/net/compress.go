@@ -0,0 +1,100 @@
+package net
+
+import (
+	"compress/gzip"
+	"github-events-microservices/api/graph"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const jsonContentType = "application/json"
+
+// compressWriter compresses the json and GraphML responses with the negotiated encoding
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	encoder     io.WriteCloser
+	wroteHeader bool
+}
+
+func (receiver *compressWriter) WriteHeader(status int) {
+	if receiver.wroteHeader {
+		return
+	}
+	receiver.wroteHeader = true
+	header := receiver.Header()
+	hasBody := status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+	if hasBody && len(receiver.encoding) > 0 && len(header.Get("Content-Encoding")) == 0 &&
+		compressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", receiver.encoding)
+		header.Del("Content-Length")
+		if receiver.encoding == "br" {
+			receiver.encoder = brotli.NewWriterLevel(receiver.ResponseWriter, brotli.DefaultCompression)
+		} else {
+			receiver.encoder = gzip.NewWriter(receiver.ResponseWriter)
+		}
+	}
+	receiver.ResponseWriter.WriteHeader(status)
+}
+
+func (receiver *compressWriter) Write(data []byte) (int, error) {
+	if !receiver.wroteHeader {
+		receiver.WriteHeader(http.StatusOK)
+	}
+	if receiver.encoder != nil {
+		return receiver.encoder.Write(data)
+	}
+	return receiver.ResponseWriter.Write(data)
+}
+
+func (receiver *compressWriter) close() error {
+	if receiver.encoder == nil {
+		return nil
+	}
+	return receiver.encoder.Close()
+}
+
+// Compress compresses the json responses of a route with brotli or gzip, as accepted by the client
+func Compress(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Add("Vary", "Accept-Encoding")
+		compressor := &compressWriter{ResponseWriter: writer, encoding: acceptedEncoding(request.Header.Get("Accept-Encoding"))}
+		handler(compressor, request)
+		_ = compressor.close()
+	}
+}
+
+// acceptedEncoding returns br or gzip, whichever the client prefers, br on a tie, or an empty encoding
+func acceptedEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = quality
+	}
+	encoding, best := "", 0.0
+	for _, name := range []string{"br", "gzip"} {
+		quality, ok := qualities[name]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > best {
+			encoding, best = name, quality
+		}
+	}
+	return encoding
+}
+
+func compressible(contentType string) bool {
+	return strings.HasPrefix(contentType, jsonContentType) || strings.HasPrefix(contentType, graph.GraphMLContentType)
+}
@@ -0,0 +1,172 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"github-events-microservices/api/config"
+	"github-events-microservices/api/graph"
+	"github-events-microservices/api/metrics"
+	"github-events-microservices/stores"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	CollaboratorsPath = "/graph/collaborators"
+	RelatedReposPath  = "/graph/related"
+	ShortestPathPath  = "/graph/path"
+
+	graphUserParam   = "user"
+	graphRepoParam   = "repo"
+	graphFromParam   = "from"
+	graphToParam     = "to"
+	graphDepthParam  = "depth"
+	graphDaysParam   = "days"
+	graphFormatParam = "format"
+
+	graphFormatJson    = "json"
+	graphFormatGraphML = "graphml"
+	defaultGraphDays   = 30
+	eventTimeField     = "created_at"
+)
+
+type graphParams struct {
+	depth  int
+	since  time.Time
+	until  time.Time
+	format string
+}
+
+// graphWalk computes a graph from the links of a source, within depth hops
+type graphWalk func(source graph.Source, depth int) (graph.Graph, error)
+
+// Collaborators returns the graph of the users who had events on the same repos as a user
+func (receiver RequestsHandler) Collaborators(writer http.ResponseWriter, request *http.Request) {
+	user := request.URL.Query().Get(graphUserParam)
+	receiver.serveGraph(writer, request, []string{graphUserParam}, func(source graph.Source, depth int) (graph.Graph, error) {
+		return graph.Collaborators(source, user, depth, config.ApiConfiguration.Graph.MaxNodes)
+	})
+}
+
+// RelatedRepos returns the graph of the repos sharing actors with a repo
+func (receiver RequestsHandler) RelatedRepos(writer http.ResponseWriter, request *http.Request) {
+	repo := request.URL.Query().Get(graphRepoParam)
+	receiver.serveGraph(writer, request, []string{graphRepoParam}, func(source graph.Source, depth int) (graph.Graph, error) {
+		return graph.RelatedRepos(source, repo, depth, config.ApiConfiguration.Graph.MaxNodes)
+	})
+}
+
+// ShortestPath returns a shortest path between two users, through the repos they had events on
+func (receiver RequestsHandler) ShortestPath(writer http.ResponseWriter, request *http.Request) {
+	from, to := request.URL.Query().Get(graphFromParam), request.URL.Query().Get(graphToParam)
+	receiver.serveGraph(writer, request, []string{graphFromParam, graphToParam}, func(source graph.Source, depth int) (graph.Graph, error) {
+		return graph.ShortestPath(source, from, to, depth, config.ApiConfiguration.Graph.MaxNodes)
+	})
+}
+
+func (receiver RequestsHandler) serveGraph(writer http.ResponseWriter, request *http.Request, required []string, walk graphWalk) {
+	for _, param := range required {
+		if len(request.URL.Query().Get(param)) == 0 {
+			writeError(writer, http.StatusBadRequest, fmt.Sprintf("missing '%s'", param))
+			return
+		}
+	}
+	params, err := parseGraphParams(request)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	store, ok := receiver.storesMap[config.ApiConfiguration.EventsCollection].(stores.LinkStore)
+	if !ok {
+		writeError(writer, http.StatusInternalServerError, "events can't be linked")
+		return
+	}
+
+	source := func(field string, values []string) ([]stores.Link, error) {
+		ctx, span := tracer.Start(request.Context(), "store.Links", trace.WithAttributes(
+			attribute.String("field", field),
+			attribute.Int("values", len(values))))
+		start := time.Now()
+		links, err := store.LinksWithContext(ctx, stores.LinkQuery{
+			From:      graph.ActorField,
+			To:        graph.RepoField,
+			Field:     field,
+			Values:    values,
+			TimeField: eventTimeField,
+			Since:     params.since,
+			Until:     params.until,
+			Limit:     config.ApiConfiguration.Graph.MaxLinks,
+		})
+		metrics.ObserveStoreQuery(config.ApiConfiguration.EventsCollection, "links", start)
+		endSpan(span, err)
+		return links, err
+	}
+	result, err := walk(source, params.depth)
+	if errors.Is(err, graph.ErrNoPath) {
+		writeError(writer, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		errorMessage := "failed to compute the graph"
+		slog.ErrorContext(request.Context(), fmt.Sprintf("%s: %s", errorMessage, err.Error()))
+		writeError(writer, http.StatusInternalServerError, errorMessage)
+		return
+	}
+
+	if params.format == graphFormatJson {
+		writeJsonResponse(writer, result, "graph")
+		return
+	}
+	writer.Header().Set("Content-Type", graph.GraphMLContentType)
+	writer.WriteHeader(http.StatusOK)
+	err = result.WriteGraphML(writer)
+	if err != nil {
+		slog.ErrorContext(request.Context(), fmt.Sprintf("failed to write the graph as GraphML: %s", err.Error()))
+	}
+}
+
+func parseGraphParams(request *http.Request) (*graphParams, error) {
+	options := config.ApiConfiguration.Graph
+	depth, err := parseIntParam(graphDepthParam, request.URL.Query().Get(graphDepthParam), defaultGraphDepth(request))
+	if err != nil {
+		return nil, err
+	}
+	if *depth < 1 || *depth > options.MaxDepth {
+		return nil, fmt.Errorf("invalid depth. Depth must be between 1 and %d", options.MaxDepth)
+	}
+	maxDays := int(options.MaxWindow / (24 * time.Hour))
+	days, err := parseIntParam(graphDaysParam, request.URL.Query().Get(graphDaysParam), min(defaultGraphDays, maxDays))
+	if err != nil {
+		return nil, err
+	}
+	if *days < 1 || *days > maxDays {
+		return nil, fmt.Errorf("invalid days. Days must be between 1 and %d", maxDays)
+	}
+	format := strings.ToLower(getParam(request.URL.Query(), graphFormatParam, graphFormatJson))
+	if format != graphFormatJson && format != graphFormatGraphML {
+		return nil, fmt.Errorf("unknown format: '%s'. Supported formats are: %s, %s", format, graphFormatJson, graphFormatGraphML)
+	}
+	until := time.Now().UTC()
+	return &graphParams{depth: *depth, since: until.AddDate(0, 0, -*days), until: until, format: format}, nil
+}
+
+// defaultGraphDepth is the max depth for a path, so that it is found when it can be, and 1 hop otherwise
+func defaultGraphDepth(request *http.Request) int {
+	if request.URL.Path == ShortestPathPath {
+		return config.ApiConfiguration.Graph.MaxDepth
+	}
+	return 1
+}
+
+// GraphCost is the number of queries of a graph request, one per step from a user to a repo or back
+func GraphCost(request *http.Request) int {
+	depth, err := parseIntParam(graphDepthParam, request.URL.Query().Get(graphDepthParam), defaultGraphDepth(request))
+	if err != nil || *depth < 1 {
+		return 1
+	}
+	return 2 * *depth
+}
@@ -0,0 +1,84 @@
+package net
+
+import (
+	"context"
+	"github-events-microservices/api/config"
+	"github-events-microservices/api/graph"
+	"github-events-microservices/stores"
+	mockstores "github-events-microservices/stores/mocks"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// linkableStoreMock is a read store of events that can be linked, as the mongo stores are
+type linkableStoreMock struct {
+	*mockstores.MockReadStore
+	*mockstores.MockLinkStore
+}
+
+func TestRequestsHandler_graph(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	linkStore := mockstores.NewMockLinkStore(mockCtrl)
+	linkStore.EXPECT().
+		LinksWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, query stores.LinkQuery) ([]stores.Link, error) {
+			if query.From != graph.ActorField || query.To != graph.RepoField || query.TimeField != "created_at" || query.Until.Sub(query.Since) != 7*24*time.Hour {
+				t.Errorf("unexpected links query: %+v", query)
+			}
+			if query.Field == graph.ActorField {
+				return []stores.Link{{From: "alice", To: "acme/api", Count: 2}}, nil
+			}
+			return []stores.Link{{From: "alice", To: "acme/api", Count: 2}, {From: "bob", To: "acme/api", Count: 1}}, nil
+		}).
+		AnyTimes()
+	receiver := RequestsHandler{storesMap: map[string]stores.ReadStore{
+		config.ApiConfiguration.EventsCollection: linkableStoreMock{mockstores.NewMockReadStore(mockCtrl), linkStore},
+	}}
+
+	tests := []struct {
+		name            string
+		handler         http.HandlerFunc
+		target          string
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{name: "collaborators", handler: receiver.Collaborators, target: "/graph/collaborators?user=alice&days=7", wantCode: http.StatusOK, wantContentType: jsonContentType, wantBody: `{"id":"user:bob","type":"user","label":"bob","distance":2}`},
+		{name: "graphml", handler: receiver.RelatedRepos, target: "/graph/related?repo=acme/api&days=7&format=graphml", wantCode: http.StatusOK, wantContentType: graph.GraphMLContentType, wantBody: `<node id="user:bob">`},
+		{name: "path", handler: receiver.ShortestPath, target: "/graph/path?from=alice&to=bob&days=7", wantCode: http.StatusOK, wantContentType: jsonContentType, wantBody: `"edges":[{"source":"user:alice","target":"repo:acme/api"`},
+		{name: "no path", handler: receiver.ShortestPath, target: "/graph/path?from=alice&to=carol&days=7&depth=1", wantCode: http.StatusNotFound, wantBody: "no path found between alice and carol within 1 hops"},
+		{name: "missing user", handler: receiver.Collaborators, target: "/graph/collaborators", wantCode: http.StatusBadRequest, wantBody: "missing 'user'"},
+		{name: "too deep", handler: receiver.Collaborators, target: "/graph/collaborators?user=alice&depth=4", wantCode: http.StatusBadRequest, wantBody: "Depth must be between 1 and 3"},
+		{name: "too long window", handler: receiver.Collaborators, target: "/graph/collaborators?user=alice&days=91", wantCode: http.StatusBadRequest, wantBody: "Days must be between 1 and 90"},
+		{name: "unknown format", handler: receiver.Collaborators, target: "/graph/collaborators?user=alice&format=dot", wantCode: http.StatusBadRequest, wantBody: "unknown format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			tt.handler(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if recorder.Code != tt.wantCode || !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Fatalf("expected: %d %s, got: %d %s", tt.wantCode, tt.wantBody, recorder.Code, recorder.Body.String())
+			}
+			if len(tt.wantContentType) > 0 && recorder.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("expected content type %s, got: %s", tt.wantContentType, recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestGraphCost(t *testing.T) {
+	for target, want := range map[string]int{
+		"/graph/collaborators?user=alice":      2,
+		"/graph/related?repo=acme/api&depth=2": 4,
+		"/graph/path?from=alice&to=bob":        6,
+		"/graph/collaborators?depth=invalid":   1,
+	} {
+		if got := GraphCost(httptest.NewRequest(http.MethodGet, target, nil)); got != want {
+			t.Errorf("expected a cost of %d for '%s', got: %d", want, target, got)
+		}
+	}
+}
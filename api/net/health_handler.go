@@ -0,0 +1,62 @@
+package net
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	healthStatusOk          = "ok"
+	healthStatusUnavailable = "unavailable"
+)
+
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Healthz reports that the process is up
+func (receiver RequestsHandler) Healthz(writer http.ResponseWriter, _ *http.Request) {
+	writeHealthReport(writer, http.StatusOK, HealthReport{Status: healthStatusOk})
+}
+
+// Readyz pings the stores and fails with 503 when any of them is unavailable
+func (receiver RequestsHandler) Readyz(writer http.ResponseWriter, request *http.Request) {
+	report := HealthReport{Status: healthStatusOk, Checks: make(map[string]HealthCheck)}
+	for dataType, store := range receiver.storesMap {
+		err := store.Ping()
+		if err != nil {
+			slog.WarnContext(request.Context(), fmt.Sprintf("store '%s' is unavailable: %s", dataType, err.Error()))
+			report.Checks["mongo:"+dataType] = HealthCheck{Status: healthStatusUnavailable, Error: err.Error()}
+			report.Status = healthStatusUnavailable
+		} else {
+			report.Checks["mongo:"+dataType] = HealthCheck{Status: healthStatusOk}
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != healthStatusOk {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealthReport(writer, status, report)
+}
+
+func writeHealthReport(writer http.ResponseWriter, status int, report HealthReport) {
+	writer.WriteHeader(status)
+	bytes, err := json.Marshal(report)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to serialize health report: %s", err.Error()))
+		return
+	}
+	_, err = writer.Write(bytes)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to write health report: %s", err.Error()))
+	}
+}
@@ -0,0 +1,46 @@
+package net
+
+import (
+	"errors"
+	"github-events-microservices/stores"
+	mockstores "github-events-microservices/stores/mocks"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestsHandler_Readyz(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	tests := []struct {
+		name     string
+		pingErr  error
+		wantCode int
+	}{
+		{
+			name:     "mongo is up",
+			pingErr:  nil,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "mongo is down",
+			pingErr:  errors.New("server selection timeout"),
+			wantCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storeMock := mockstores.NewMockReadStore(mockCtrl)
+			storeMock.EXPECT().Ping().Return(tt.pingErr)
+			receiver := RequestsHandler{storesMap: map[string]stores.ReadStore{"events": storeMock}}
+
+			recorder := httptest.NewRecorder()
+			receiver.Readyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if recorder.Code != tt.wantCode {
+				t.Errorf("expected: %d, got: %d", tt.wantCode, recorder.Code)
+			}
+		})
+	}
+}
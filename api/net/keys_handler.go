@@ -0,0 +1,102 @@
+package net
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github-events-microservices/api/auth"
+	"github-events-microservices/api/ratelimit"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const KeysPath = "/admin/keys"
+
+// KeysHandler manages the api keys: GET and POST /admin/keys, DELETE /admin/keys/{id}.
+// The value of a key is only returned when it is created, along with its rate limit tier.
+type KeysHandler struct {
+	keys    *auth.KeyStore
+	limiter *ratelimit.Limiter
+}
+
+type keyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Tier   string   `json:"tier"`
+}
+
+type createdKey struct {
+	auth.ApiKey
+	Key string `json:"key"`
+}
+
+func (receiver KeysHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(request.URL.Path, KeysPath), "/")
+	switch {
+	case len(id) == 0 && request.Method == http.MethodGet:
+		receiver.list(writer, request)
+	case len(id) == 0 && request.Method == http.MethodPost:
+		receiver.create(writer, request)
+	case len(id) > 0 && request.Method == http.MethodDelete:
+		receiver.delete(writer, request, id)
+	default:
+		writeError(writer, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed on %s", request.Method, request.URL.Path))
+	}
+}
+
+func (receiver KeysHandler) list(writer http.ResponseWriter, request *http.Request) {
+	keys, err := receiver.keys.List()
+	if err != nil {
+		slog.ErrorContext(request.Context(), fmt.Sprintf("failed to list api keys: %s", err.Error()))
+		writeError(writer, http.StatusInternalServerError, "failed to list api keys")
+		return
+	}
+	writeJsonResponse(writer, keys, "keys")
+}
+
+func (receiver KeysHandler) create(writer http.ResponseWriter, request *http.Request) {
+	var keyRequest keyRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxRuleBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&keyRequest)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, fmt.Sprintf("invalid key: %s", err.Error()))
+		return
+	}
+	if len(keyRequest.Tier) > 0 && !receiver.limiter.HasTier(keyRequest.Tier) {
+		writeError(writer, http.StatusBadRequest, fmt.Sprintf("unknown rate limit tier '%s'", keyRequest.Tier))
+		return
+	}
+	key, value, err := receiver.keys.Create(keyRequest.Name, keyRequest.Scopes, keyRequest.Tier)
+	if errors.Is(err, auth.ErrInvalidKey) {
+		writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(request.Context(), fmt.Sprintf("failed to create api key: %s", err.Error()))
+		writeError(writer, http.StatusInternalServerError, "failed to create api key")
+		return
+	}
+	slog.InfoContext(request.Context(), fmt.Sprintf("created api key %s (%s) with scopes %s", key.ID, key.Name, strings.Join(key.Scopes, " ")))
+	writeJsonStatus(writer, http.StatusCreated, createdKey{ApiKey: key, Key: value}, "key")
+}
+
+func (receiver KeysHandler) delete(writer http.ResponseWriter, request *http.Request, id string) {
+	deleted, err := receiver.keys.Delete(id)
+	if err != nil {
+		slog.ErrorContext(request.Context(), fmt.Sprintf("failed to delete api key %s: %s", id, err.Error()))
+		writeError(writer, http.StatusInternalServerError, fmt.Sprintf("failed to delete api key '%s'", id))
+		return
+	}
+	if !deleted {
+		writeError(writer, http.StatusNotFound, fmt.Sprintf("api key '%s' not found", id))
+		return
+	}
+	slog.InfoContext(request.Context(), fmt.Sprintf("deleted api key %s", id))
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func NewKeysHandler(keys *auth.KeyStore, limiter *ratelimit.Limiter) *KeysHandler {
+	return &KeysHandler{keys: keys, limiter: limiter}
+}
@@ -0,0 +1,168 @@
+package net
+
+import (
+	"encoding/json"
+	"github-events-microservices/api/auth"
+	"github-events-microservices/api/ratelimit"
+	"github-events-microservices/stores"
+	mockstores "github-events-microservices/stores/mocks"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	store := mockstores.NewMockDocumentStore(mockCtrl)
+	store.EXPECT().FindByIdWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(stores.ErrNotFound).Times(3)
+	authenticator, err := auth.NewAuthenticator(auth.Options{Enabled: true, AdminKey: "bootstrap"}, auth.NewKeyStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter, err := ratelimit.New(ratelimit.Options{Enabled: true, Tiers: "anonymous=0.001:4:0,default=1:10:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		apiKey    string
+		scope     string
+		wantCode  int
+		wantCalls int
+	}{
+		{name: "missing credentials", scope: auth.ScopeReadEvents, wantCode: http.StatusUnauthorized},
+		{name: "invalid credentials", apiKey: "guess", scope: auth.ScopeReadEvents, wantCode: http.StatusUnauthorized},
+		{name: "unknown key", apiKey: "gea_unknown_secret", scope: auth.ScopeReadEvents, wantCode: http.StatusUnauthorized},
+		{name: "authorized", apiKey: "bootstrap", scope: auth.ScopeAdmin, wantCode: http.StatusTeapot, wantCalls: 1},
+		{name: "unknown key emptying the bucket of the ip", apiKey: "gea_other_secret", scope: auth.ScopeReadEvents, wantCode: http.StatusUnauthorized},
+		{name: "rate limited ip", apiKey: "gea_another_secret", scope: auth.ScopeReadEvents, wantCode: http.StatusTooManyRequests},
+		{name: "authorized from the rate limited ip", apiKey: "bootstrap", scope: auth.ScopeAdmin, wantCode: http.StatusTeapot, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			handler := Authorize(authenticator, limiter, "/list", tt.scope, func(writer http.ResponseWriter, request *http.Request) {
+				principal, ok := auth.PrincipalFrom(request.Context())
+				if !ok || principal.Kind != auth.KindAdminKey {
+					t.Errorf("expected the admin key principal, got: %+v", principal)
+				}
+				calls++
+				writer.WriteHeader(http.StatusTeapot)
+			})
+			request := httptest.NewRequest(http.MethodGet, "/list", nil)
+			if len(tt.apiKey) > 0 {
+				request.Header.Set("X-API-Key", tt.apiKey)
+			}
+			recorder := httptest.NewRecorder()
+			handler(recorder, request)
+			if recorder.Code != tt.wantCode || calls != tt.wantCalls {
+				t.Errorf("expected: %d and %d calls, got: %d and %d calls", tt.wantCode, tt.wantCalls, recorder.Code, calls)
+			}
+			if recorder.Code == http.StatusUnauthorized && len(recorder.Header().Get("WWW-Authenticate")) == 0 {
+				t.Errorf("expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestKeysHandler_ServeHTTP(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	limiter, err := ratelimit.New(ratelimit.Options{Tiers: "anonymous=1:10:0,default=10:100:0,partner=50:500:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expect   func(store *mockstores.MockDocumentStore)
+		wantCode int
+	}{
+		{
+			name:   "creates a key",
+			method: http.MethodPost,
+			path:   "/admin/keys",
+			body:   `{"name":"dashboard","scopes":["read:stats"],"tier":"partner"}`,
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().Save(gomock.Any()).Return(nil)
+			},
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "rejects unknown tiers",
+			method:   http.MethodPost,
+			path:     "/admin/keys",
+			body:     `{"name":"dashboard","scopes":["read:stats"],"tier":"gold"}`,
+			expect:   func(store *mockstores.MockDocumentStore) {},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "rejects unknown scopes",
+			method:   http.MethodPost,
+			path:     "/admin/keys",
+			body:     `{"name":"dashboard","scopes":["write:events"]}`,
+			expect:   func(store *mockstores.MockDocumentStore) {},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:   "lists the keys without their hash",
+			method: http.MethodGet,
+			path:   "/admin/keys",
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().All(gomock.Any()).DoAndReturn(func(results interface{}) error {
+					*results.(*[]auth.ApiKey) = []auth.ApiKey{{ID: "k1", Name: "dashboard", Hash: "0badc0de", Scopes: []string{"read:stats"}}}
+					return nil
+				})
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:   "deletes a key",
+			method: http.MethodDelete,
+			path:   "/admin/keys/k1",
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().DeleteById("k1").Return(true, nil)
+			},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:   "deletes an unknown key",
+			method: http.MethodDelete,
+			path:   "/admin/keys/k2",
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().DeleteById("k2").Return(false, nil)
+			},
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := mockstores.NewMockDocumentStore(mockCtrl)
+			tt.expect(store)
+			recorder := httptest.NewRecorder()
+			NewKeysHandler(auth.NewKeyStore(store), limiter).ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("expected: %d, got: %d %s", tt.wantCode, recorder.Code, recorder.Body.String())
+			}
+			if strings.Contains(recorder.Body.String(), "0badc0de") {
+				t.Errorf("expected no hash in the response, got: %s", recorder.Body.String())
+			}
+			if recorder.Code == http.StatusCreated {
+				var created createdKey
+				err := json.Unmarshal(recorder.Body.Bytes(), &created)
+				if err != nil || !auth.IsApiKey(created.Key) || len(created.ID) == 0 {
+					t.Errorf("expected the created key, got: %s", recorder.Body.String())
+				}
+			}
+		})
+	}
+}
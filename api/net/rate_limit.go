@@ -0,0 +1,131 @@
+package net
+
+import (
+	"fmt"
+	"github-events-microservices/api/auth"
+	"github-events-microservices/api/config"
+	"github-events-microservices/api/metrics"
+	"github-events-microservices/api/ratelimit"
+	"log/slog"
+	"math"
+	stdnet "net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	UsagePath = "/admin/usage"
+	// listCostPageSize is the number of listed items costing one more token
+	listCostPageSize = 100
+	// unboundedListCost is the cost of listing with limit=0
+	unboundedListCost = 100
+)
+
+// RequestCost returns the number of tokens a request takes from the bucket of its client
+type RequestCost func(request *http.Request) int
+
+// UnitCost is the cost of the requests running a bounded query
+func UnitCost(*http.Request) int {
+	return 1
+}
+
+// ListCost grows with the limit of a list request, so large pages are limited like many small ones
+func ListCost(request *http.Request) int {
+	limit, err := parseIntParam(config.LimitParamKey, request.URL.Query().Get(config.LimitParamKey), config.DefaultLimit)
+	if err != nil || *limit < 0 {
+		return 1
+	}
+	if *limit == 0 {
+		return unboundedListCost
+	}
+	return 1 + *limit/listCostPageSize
+}
+
+// Limit bounds the request body, rejects the requests costing more than MAX_QUERY_COST and takes the cost of the
+// others from the token bucket of their client: the authenticated principal in its tier, or else the client ip in
+// the anonymous tier. It must run inside Authorize to see the principal, which limits the rejected credentials itself.
+func Limit(limiter *ratelimit.Limiter, route string, cost RequestCost, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if config.ApiConfiguration.MaxRequestBody > 0 {
+			request.Body = http.MaxBytesReader(writer, request.Body, config.ApiConfiguration.MaxRequestBody)
+		}
+		client, tier := clientOf(limiter, request)
+		requestCost := cost(request)
+		if maxCost := config.ApiConfiguration.MaxQueryCost; maxCost > 0 && requestCost > maxCost {
+			metrics.RateLimited.WithLabelValues(route, tier, "cost").Inc()
+			writeError(writer, http.StatusBadRequest, fmt.Sprintf("query cost %d exceeds the maximum of %d, lower the limit", requestCost, maxCost))
+			return
+		}
+
+		decision := limiter.Allow(client, tier, requestCost)
+		if !limiter.Enforced() {
+			handler(writer, request)
+			return
+		}
+		if !decision.Allowed {
+			writeRateLimited(writer, request, route, client, tier, decision)
+			return
+		}
+		writeRateLimitHeaders(writer, decision)
+		handler(writer, request)
+	}
+}
+
+func writeRateLimitHeaders(writer http.ResponseWriter, decision ratelimit.Decision) {
+	writer.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+	writer.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	writer.Header().Set("X-RateLimit-Reset", strconv.FormatInt(decision.Reset.Unix(), 10))
+}
+
+// writeRateLimited rejects a request rejected by the rate limiter, with the time to wait before the next one
+func writeRateLimited(writer http.ResponseWriter, request *http.Request, route string, client string, tier string, decision ratelimit.Decision) {
+	reason := "rate"
+	if decision.QuotaExceeded {
+		reason = "quota"
+	}
+	metrics.RateLimited.WithLabelValues(route, tier, reason).Inc()
+	slog.WarnContext(request.Context(), fmt.Sprintf("rate limited %s %s by %s in tier %s: %s", request.Method, request.URL.RequestURI(), client, tier, reason))
+	writeRateLimitHeaders(writer, decision)
+	writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+	writeError(writer, http.StatusTooManyRequests, fmt.Sprintf("%s limit exceeded, retry in %s", reason, decision.RetryAfter.Round(time.Second)))
+}
+
+// UsageHandler reports the usage of the clients of the day, tracked even when rate limiting is not enforced
+func UsageHandler(limiter *ratelimit.Limiter) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet {
+			writeError(writer, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed on %s", request.Method, request.URL.Path))
+			return
+		}
+		writeJsonResponse(writer, limiter.Usage(), "usage")
+	}
+}
+
+// clientOf returns the client of a request and its tier, the default one when the principal has no known tier
+func clientOf(limiter *ratelimit.Limiter, request *http.Request) (string, string) {
+	principal, ok := auth.PrincipalFrom(request.Context())
+	if ok {
+		tier := principal.Tier
+		if !limiter.HasTier(tier) {
+			tier = ratelimit.TierDefault
+		}
+		return principal.String(), tier
+	}
+	return "ip:" + clientIp(request), ratelimit.TierAnonymous
+}
+
+func clientIp(request *http.Request) string {
+	if config.ApiConfiguration.RateLimit.TrustForwardedFor {
+		forwardedFor, _, _ := strings.Cut(request.Header.Get("X-Forwarded-For"), ",")
+		if forwardedFor = strings.TrimSpace(forwardedFor); len(forwardedFor) > 0 {
+			return forwardedFor
+		}
+	}
+	host, _, err := stdnet.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,68 @@
+package net
+
+import (
+	"github-events-microservices/api/auth"
+	"github-events-microservices/api/config"
+	"github-events-microservices/api/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLimit(t *testing.T) {
+	t.Setenv("AUTH_ENABLED", "false")
+	t.Setenv("MAX_QUERY_COST", "5")
+	t.Setenv("MAX_PAGE_SIZE", "1000")
+	// the env variables are only unset after the cleanups, so the previous configuration is restored rather than loaded
+	previous := config.ApiConfiguration
+	t.Cleanup(func() { config.ApiConfiguration = previous })
+	_, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter, err := ratelimit.New(ratelimit.Options{Enabled: true, Tiers: "anonymous=1:2:0,default=1:10:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := Limit(limiter, "/list", ListCost, func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusTeapot)
+	})
+	keyRequest := func(target string) *http.Request {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		principal := auth.Principal{ID: "k1", Kind: auth.KindKey, Scopes: []string{auth.ScopeReadEvents}}
+		return request.WithContext(auth.WithPrincipal(request.Context(), principal))
+	}
+
+	tests := []struct {
+		name          string
+		request       *http.Request
+		wantCode      int
+		wantRemaining string
+	}{
+		{name: "anonymous request", request: httptest.NewRequest(http.MethodGet, "/list", nil), wantCode: http.StatusTeapot, wantRemaining: "1"},
+		{name: "anonymous request emptying the bucket", request: httptest.NewRequest(http.MethodGet, "/list", nil), wantCode: http.StatusTeapot, wantRemaining: "0"},
+		{name: "rate limited anonymous request", request: httptest.NewRequest(http.MethodGet, "/list", nil), wantCode: http.StatusTooManyRequests, wantRemaining: "0"},
+		{name: "key request in its own bucket", request: keyRequest("/list?limit=450"), wantCode: http.StatusTeapot, wantRemaining: "5"},
+		{name: "too costly request", request: keyRequest("/list?limit=0"), wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler(recorder, tt.request)
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("expected: %d, got: %d %s", tt.wantCode, recorder.Code, recorder.Body.String())
+			}
+			if remaining := recorder.Header().Get("X-RateLimit-Remaining"); remaining != tt.wantRemaining {
+				t.Errorf("expected %s remaining tokens, got: %s", tt.wantRemaining, remaining)
+			}
+			if recorder.Code == http.StatusTooManyRequests && recorder.Header().Get("Retry-After") != "1" {
+				t.Errorf("expected a Retry-After header, got: %v", recorder.Header())
+			}
+		})
+	}
+
+	usage := limiter.Usage()
+	if len(usage) != 2 || usage[0].Client != "key:k1" || usage[0].Cost != 5 || usage[1].Rejected != 1 {
+		t.Errorf("expected the usage of both clients, got: %+v", usage)
+	}
+}
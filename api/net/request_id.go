@@ -0,0 +1,39 @@
+package net
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github-events-microservices/logging"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	requestIdHeader    = "X-Request-Id"
+	maxRequestIdLength = 128
+)
+
+// WithRequestId tags the request context with the caller's X-Request-Id, or a generated one, so that every
+// record logged with the slog *Context functions carries it. The id is echoed in the response headers.
+func WithRequestId(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requestId := request.Header.Get(requestIdHeader)
+		if len(requestId) == 0 || len(requestId) > maxRequestIdLength {
+			requestId = newRequestId()
+		}
+		writer.Header().Set(requestIdHeader, requestId)
+
+		ctx := logging.WithRequestId(request.Context(), requestId)
+		start := time.Now()
+		handler.ServeHTTP(writer, request.WithContext(ctx))
+		slog.DebugContext(ctx, fmt.Sprintf("%s %s handled in %s", request.Method, request.URL.Path, time.Since(start)))
+	})
+}
+
+func newRequestId() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -1,22 +1,38 @@
 package net
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github-events-microservices/api/auth"
 	"github-events-microservices/api/config"
+	"github-events-microservices/api/metrics"
 	"github-events-microservices/model"
 	"github-events-microservices/stores"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
+var tracer = otel.Tracer("github-events-microservices/api/net")
+
 type RequestsHandler struct {
+	client             *mongo.Client
 	storesMap          map[string]stores.ReadStore
+	rulesStore         stores.DocumentStore
+	metaStore          stores.DocumentStore
+	keyStore           *auth.KeyStore
+	usersStore         botsStore
 	supportedDataTypes []string
 }
 
@@ -46,11 +62,27 @@ func (receiver RequestsHandler) List(writer http.ResponseWriter, request *http.R
 		errorMessage := fmt.Sprintf("unknown data type: '%s'. Supported data types are: %s.", listParams.DataType, strings.Join(supportedDataTypes, ", "))
 		writeError(writer, http.StatusBadRequest, errorMessage)
 	} else {
+		exclude, err := excludeBots(request, listParams.DataType)
+		if err != nil {
+			writeError(writer, http.StatusBadRequest, err.Error())
+			return
+		}
 		var results, _ = createResults(listParams.DataType)
-		err := store.Get(listParams.Limit, listParams.OrderBy, &results)
+		ctx, span := tracer.Start(request.Context(), "store.Get", trace.WithAttributes(
+			attribute.String("data_type", listParams.DataType),
+			attribute.Int64("limit", listParams.Limit),
+			attribute.Bool("exclude_bots", exclude)))
+		start := time.Now()
+		if exclude {
+			err = receiver.getHumans(ctx, listParams, &results)
+		} else {
+			err = store.GetWithContext(ctx, listParams.Limit, listParams.OrderBy, &results)
+		}
+		metrics.ObserveStoreQuery(listParams.DataType, "get", start)
+		endSpan(span, err)
 		if err != nil {
 			errorMessage := fmt.Sprintf("failed to list items: %s", err.Error())
-			slog.Error(errorMessage)
+			slog.ErrorContext(request.Context(), errorMessage)
 			writeError(writer, http.StatusBadRequest, errorMessage)
 		} else {
 			writeJsonResponse(writer, results, listParams.DataType)
@@ -65,9 +97,26 @@ func (receiver RequestsHandler) Count(writer http.ResponseWriter, request *http.
 		errorMessage := fmt.Sprintf("unknown data type: '%s'", storeKey)
 		writeError(writer, http.StatusBadRequest, errorMessage)
 	} else {
-		count, err := store.Count()
+		exclude, err := excludeBots(request, storeKey)
+		if err != nil {
+			writeError(writer, http.StatusBadRequest, err.Error())
+			return
+		}
+		ctx, span := tracer.Start(request.Context(), "store.Count", trace.WithAttributes(
+			attribute.String("data_type", storeKey),
+			attribute.Bool("exclude_bots", exclude)))
+		start := time.Now()
+		var count int64
+		if exclude {
+			count, err = receiver.countHumans(ctx, storeKey)
+		} else {
+			count, err = store.CountWithContext(ctx)
+		}
+		metrics.ObserveStoreQuery(storeKey, "count", start)
+		endSpan(span, err)
 		if err != nil {
 			errorMessage := fmt.Sprintf("Failed to count '%s'", storeKey)
+			slog.ErrorContext(request.Context(), fmt.Sprintf("%s: %s", errorMessage, err.Error()))
 			writeError(writer, http.StatusInternalServerError, errorMessage)
 		} else {
 			writeJsonResponse(writer, DataCount{Count: count}, "count")
@@ -75,6 +124,54 @@ func (receiver RequestsHandler) Count(writer http.ResponseWriter, request *http.
 	}
 }
 
+// getHumans lists the items of a data type but the bots and their events
+func (receiver RequestsHandler) getHumans(ctx context.Context, listParams *ListParams, results interface{}) error {
+	if listParams.DataType != config.ApiConfiguration.UsersCollection {
+		exclusion, err := botActors()
+		if err != nil {
+			return err
+		}
+		store, ok := receiver.storesMap[listParams.DataType].(stores.ExcludingStore)
+		if !ok {
+			return fmt.Errorf("%s can't be filtered", listParams.DataType)
+		}
+		return store.GetExcludingWithContext(ctx, exclusion, listParams.Limit, listParams.OrderBy, results)
+	}
+	store, ok := receiver.storesMap[listParams.DataType].(stores.FilteredStore)
+	if !ok {
+		return fmt.Errorf("%s can't be filtered", listParams.DataType)
+	}
+	return store.GetFilteredWithContext(ctx, humansFilter(), listParams.Limit, listParams.OrderBy, results)
+}
+
+// countHumans counts the items of a data type but the bots and their events
+func (receiver RequestsHandler) countHumans(ctx context.Context, dataType string) (int64, error) {
+	if dataType != config.ApiConfiguration.UsersCollection {
+		exclusion, err := botActors()
+		if err != nil {
+			return 0, err
+		}
+		store, ok := receiver.storesMap[dataType].(stores.ExcludingStore)
+		if !ok {
+			return 0, fmt.Errorf("%s can't be filtered", dataType)
+		}
+		return store.CountExcludingWithContext(ctx, exclusion)
+	}
+	store, ok := receiver.storesMap[dataType].(stores.FilteredStore)
+	if !ok {
+		return 0, fmt.Errorf("%s can't be filtered", dataType)
+	}
+	return store.CountFilteredWithContext(ctx, humansFilter())
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
 func parseListParams(request *http.Request) (*ListParams, error) {
 	limit, err := getLimit(request)
 	if err != nil {
@@ -103,6 +200,8 @@ func getLimit(request *http.Request) (*int64, error) {
 		return nil, err
 	} else if *val < 0 {
 		return nil, errors.New("invalid limit. Limit must be a non-negative integer")
+	} else if maxPageSize := config.ApiConfiguration.MaxPageSize; maxPageSize > 0 && (*val == 0 || *val > maxPageSize) {
+		return nil, fmt.Errorf("invalid limit. Limit must be between 1 and %d", maxPageSize)
 	}
 
 	limit := int64(*val)
@@ -164,6 +263,7 @@ func createResults(key string) (interface{}, error) {
 }
 
 func writeError(writer http.ResponseWriter, errorCode int, errorMessage string) {
+	writer.Header().Set("Content-Type", jsonContentType)
 	writer.WriteHeader(errorCode)
 
 	bytes, err := json.Marshal(ApiError{Error: errorMessage})
@@ -178,7 +278,12 @@ func writeError(writer http.ResponseWriter, errorCode int, errorMessage string)
 }
 
 func writeJsonResponse(writer http.ResponseWriter, response interface{}, responseKey string) {
-	writer.WriteHeader(http.StatusOK)
+	writeJsonStatus(writer, http.StatusOK, response, responseKey)
+}
+
+func writeJsonStatus(writer http.ResponseWriter, status int, response interface{}, responseKey string) {
+	writer.Header().Set("Content-Type", jsonContentType)
+	writer.WriteHeader(status)
 	bytes, serializationError := json.Marshal(response)
 	if serializationError != nil {
 		slog.Error(fmt.Sprintf("failed to serialize response '%s' to json. reason: %s", responseKey, serializationError.Error()))
@@ -189,23 +294,86 @@ func writeJsonResponse(writer http.ResponseWriter, response interface{}, respons
 	}
 }
 
-func NewRequestsHandler(url string, port string) *RequestsHandler {
-	storesMap := make(map[string]stores.ReadStore)
-	storesMap[config.ApiConfiguration.EventsCollection] = createMongoStore(url, port, config.ApiConfiguration.EventsDb, config.ApiConfiguration.EventsCollection)
-	storesMap[config.ApiConfiguration.ReposCollection] = createMongoStore(url, port, config.ApiConfiguration.ReposDb, config.ApiConfiguration.ReposCollection)
-	storesMap[config.ApiConfiguration.UsersCollection] = createMongoStore(url, port, config.ApiConfiguration.UsersDb, config.ApiConfiguration.UsersCollection)
+// EnsureIndexes creates the declared indexes of every collection
+func (receiver RequestsHandler) EnsureIndexes() error {
+	indexSpecs := map[string][]stores.IndexSpec{
+		config.ApiConfiguration.EventsCollection: stores.EventIndexes,
+		config.ApiConfiguration.ReposCollection:  stores.RepoIndexes,
+		config.ApiConfiguration.UsersCollection:  stores.UserIndexes,
+	}
+	var errs []error
+	for dataType, specs := range indexSpecs {
+		manager, ok := receiver.storesMap[dataType].(stores.IndexManager)
+		if ok {
+			errs = append(errs, manager.EnsureIndexes(specs))
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	return &RequestsHandler{
-		storesMap:          storesMap,
-		supportedDataTypes: []string{config.ApiConfiguration.EventsCollection, config.ApiConfiguration.ReposCollection, config.ApiConfiguration.UsersCollection},
+// KeyStore returns the store of the api keys, which shares the mongo client
+func (receiver RequestsHandler) KeyStore() *auth.KeyStore {
+	return receiver.keyStore
+}
+
+// WriteVersion reads the version of the last write of the collector, zero when it never recorded one
+func (receiver RequestsHandler) WriteVersion() (model.WriteVersion, error) {
+	var version model.WriteVersion
+	err := receiver.metaStore.FindById(model.WriteVersionId, &version)
+	if errors.Is(err, stores.ErrNotFound) {
+		return model.WriteVersion{}, nil
 	}
+	return version, err
+}
+
+// Bots returns the handler of the bot reviews, which share the mongo client
+func (receiver RequestsHandler) Bots() *BotsHandler {
+	return NewBotsHandler(receiver.usersStore, receiver.metaStore)
 }
 
-func createMongoStore(url string, port string, database string, collection string) *stores.MongoDbCollectionStore {
-	mongoDbStore, err := stores.NewMongoDbStore(url, port, database, collection)
+// Rules returns the handler of the alert rules, which share the mongo client
+func (receiver RequestsHandler) Rules() *RulesHandler {
+	return NewRulesHandler(receiver.rulesStore)
+}
+
+func (receiver RequestsHandler) Close() error {
+	var errs []error
+	for dataType, store := range receiver.storesMap {
+		err := store.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to close store '%s': %w", dataType, err))
+		}
+	}
+	if receiver.client != nil {
+		err := receiver.client.Disconnect(context.Background())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to disconnect from mongo: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewRequestsHandler connects to mongo with a single client shared by the stores of all the collections
+func NewRequestsHandler(mongoOptions stores.MongoOptions) *RequestsHandler {
+	client, err := stores.NewMongoClient(mongoOptions)
 	if err != nil {
-		slog.Error(err.Error())
+		slog.Error(fmt.Sprintf("failed to connect to mongo: %s", err.Error()))
 		os.Exit(1)
 	}
-	return mongoDbStore
+
+	storesMap := make(map[string]stores.ReadStore)
+	storesMap[config.ApiConfiguration.EventsCollection] = stores.NewMongoDbStore(client, config.ApiConfiguration.EventsDb, config.ApiConfiguration.EventsCollection)
+	storesMap[config.ApiConfiguration.ReposCollection] = stores.NewMongoDbStore(client, config.ApiConfiguration.ReposDb, config.ApiConfiguration.ReposCollection)
+	usersStore := stores.NewMongoDbStore(client, config.ApiConfiguration.UsersDb, config.ApiConfiguration.UsersCollection)
+	storesMap[config.ApiConfiguration.UsersCollection] = usersStore
+
+	return &RequestsHandler{
+		client:             client,
+		storesMap:          storesMap,
+		rulesStore:         stores.NewMongoDbStore(client, config.ApiConfiguration.RulesDb, config.ApiConfiguration.RulesCollection),
+		metaStore:          stores.NewMongoDbStore(client, config.ApiConfiguration.MetaDb, config.ApiConfiguration.MetaCollection),
+		keyStore:           auth.NewKeyStore(stores.NewMongoDbStore(client, config.ApiConfiguration.Auth.KeysDb, config.ApiConfiguration.Auth.KeysCollection)),
+		usersStore:         usersStore,
+		supportedDataTypes: []string{config.ApiConfiguration.EventsCollection, config.ApiConfiguration.ReposCollection, config.ApiConfiguration.UsersCollection},
+	}
 }
@@ -128,8 +128,37 @@ func mockStoresMap(mockCtrl *gomock.Controller) map[string]stores.ReadStore {
 	storesMap := make(map[string]stores.ReadStore)
 	eventsStoreMock := mockstores.NewMockReadStore(mockCtrl)
 	eventsStoreMock.EXPECT().
-		Get(gomock.Any(), gomock.Any(), gomock.Any()).
+		GetWithContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(nil)
 	storesMap[config.ApiConfiguration.EventsCollection] = eventsStoreMock
 	return storesMap
 }
+
+func TestGetLimit_defaultMaxPageSize(t *testing.T) {
+	// limit=0 lists everything unless MAX_PAGE_SIZE is set
+	for _, query := range []string{"limit=0", "limit=5000"} {
+		_, err := getLimit(&http.Request{URL: &url.URL{RawQuery: query}})
+		if err != nil {
+			t.Errorf("expected no error for '%s', got: %v", query, err)
+		}
+	}
+}
+
+func TestGetLimit_maxPageSize(t *testing.T) {
+	t.Setenv("AUTH_ENABLED", "false")
+	t.Setenv("MAX_PAGE_SIZE", "100")
+	// the env variables are only unset after the cleanups, so the previous configuration is restored rather than loaded
+	previous := config.ApiConfiguration
+	t.Cleanup(func() { config.ApiConfiguration = previous })
+	_, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for query, wantErr := range map[string]bool{"": false, "limit=100": false, "limit=101": true, "limit=0": true} {
+		_, err := getLimit(&http.Request{URL: &url.URL{RawQuery: query}})
+		if (err != nil) != wantErr {
+			t.Errorf("expected an error for '%s': %t, got: %v", query, wantErr, err)
+		}
+	}
+}
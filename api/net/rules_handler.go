@@ -0,0 +1,174 @@
+package net
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github-events-microservices/model"
+	"github-events-microservices/stores"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	RulesPath = "/rules"
+	// maxRuleBytes bounds the size of a submitted rule
+	maxRuleBytes = 64 << 10
+)
+
+// RulesHandler manages the alert rules evaluated by the collector: GET and POST /rules, GET, PUT and DELETE /rules/{id}.
+// Webhook secrets are write-only, a PUT without a secret keeps the current one.
+type RulesHandler struct {
+	store stores.DocumentStore
+	now   func() time.Time
+}
+
+func (receiver RulesHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(request.URL.Path, RulesPath), "/")
+	switch {
+	case len(id) == 0 && request.Method == http.MethodGet:
+		receiver.list(writer, request)
+	case len(id) == 0 && request.Method == http.MethodPost:
+		receiver.create(writer, request)
+	case len(id) > 0 && request.Method == http.MethodGet:
+		receiver.get(writer, request, id)
+	case len(id) > 0 && request.Method == http.MethodPut:
+		receiver.update(writer, request, id)
+	case len(id) > 0 && request.Method == http.MethodDelete:
+		receiver.delete(writer, request, id)
+	default:
+		writeError(writer, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed on %s", request.Method, request.URL.Path))
+	}
+}
+
+func (receiver RulesHandler) list(writer http.ResponseWriter, request *http.Request) {
+	rules := make([]model.Rule, 0)
+	err := receiver.store.All(&rules)
+	if err != nil {
+		receiver.storeError(writer, request, "failed to list rules", err)
+		return
+	}
+	for i := range rules {
+		rules[i] = redact(rules[i])
+	}
+	writeJsonResponse(writer, rules, "rules")
+}
+
+func (receiver RulesHandler) get(writer http.ResponseWriter, request *http.Request, id string) {
+	var rule model.Rule
+	err := receiver.store.FindByIdWithContext(request.Context(), id, &rule)
+	if err != nil {
+		receiver.storeError(writer, request, fmt.Sprintf("failed to get rule '%s'", id), err)
+		return
+	}
+	writeJsonResponse(writer, redact(rule), "rule")
+}
+
+func (receiver RulesHandler) create(writer http.ResponseWriter, request *http.Request) {
+	rule, err := decodeRule(writer, request)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	rule.ID = newRuleId()
+	rule.CreatedAt = receiver.now().UTC()
+	rule.UpdatedAt = rule.CreatedAt
+	err = rule.Validate()
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, fmt.Sprintf("invalid rule: %s", err.Error()))
+		return
+	}
+	err = receiver.store.Save(rule)
+	if err != nil {
+		receiver.storeError(writer, request, "failed to create rule", err)
+		return
+	}
+	slog.InfoContext(request.Context(), fmt.Sprintf("created rule %s (%s)", rule.ID, rule.Name))
+	writeJsonStatus(writer, http.StatusCreated, redact(rule), "rule")
+}
+
+func (receiver RulesHandler) update(writer http.ResponseWriter, request *http.Request, id string) {
+	var current model.Rule
+	err := receiver.store.FindByIdWithContext(request.Context(), id, &current)
+	if err != nil {
+		receiver.storeError(writer, request, fmt.Sprintf("failed to get rule '%s'", id), err)
+		return
+	}
+	rule, err := decodeRule(writer, request)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	rule.ID = current.ID
+	rule.CreatedAt = current.CreatedAt
+	rule.UpdatedAt = receiver.now().UTC()
+	if len(rule.Webhook.Secret) == 0 {
+		rule.Webhook.Secret = current.Webhook.Secret
+	}
+	err = rule.Validate()
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, fmt.Sprintf("invalid rule: %s", err.Error()))
+		return
+	}
+	err = receiver.store.ReplaceById(id, rule)
+	if err != nil {
+		receiver.storeError(writer, request, fmt.Sprintf("failed to update rule '%s'", id), err)
+		return
+	}
+	slog.InfoContext(request.Context(), fmt.Sprintf("updated rule %s (%s)", rule.ID, rule.Name))
+	writeJsonResponse(writer, redact(rule), "rule")
+}
+
+func (receiver RulesHandler) delete(writer http.ResponseWriter, request *http.Request, id string) {
+	deleted, err := receiver.store.DeleteById(id)
+	if err == nil && !deleted {
+		err = stores.ErrNotFound
+	}
+	if err != nil {
+		receiver.storeError(writer, request, fmt.Sprintf("failed to delete rule '%s'", id), err)
+		return
+	}
+	slog.InfoContext(request.Context(), fmt.Sprintf("deleted rule %s", id))
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (receiver RulesHandler) storeError(writer http.ResponseWriter, request *http.Request, message string, err error) {
+	if errors.Is(err, stores.ErrNotFound) {
+		writeError(writer, http.StatusNotFound, fmt.Sprintf("%s: rule not found", message))
+		return
+	}
+	slog.ErrorContext(request.Context(), fmt.Sprintf("%s: %s", message, err.Error()))
+	writeError(writer, http.StatusInternalServerError, message)
+}
+
+// decodeRule reads a submitted rule. Rules are enabled unless stated otherwise.
+func decodeRule(writer http.ResponseWriter, request *http.Request) (model.Rule, error) {
+	rule := model.Rule{Enabled: true}
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxRuleBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&rule)
+	if err != nil {
+		return rule, fmt.Errorf("invalid rule: %s", err.Error())
+	}
+	return rule, nil
+}
+
+// redact removes the webhook secret from a returned rule
+func redact(rule model.Rule) model.Rule {
+	rule.Webhook.Secret = ""
+	return rule
+}
+
+func newRuleId() string {
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func NewRulesHandler(store stores.DocumentStore) *RulesHandler {
+	return &RulesHandler{store: store, now: time.Now}
+}
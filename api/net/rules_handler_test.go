@@ -0,0 +1,196 @@
+package net
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github-events-microservices/model"
+	"github-events-microservices/stores"
+	mockstores "github-events-microservices/stores/mocks"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRulesHandler_ServeHTTP(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	created := now.Add(-time.Hour)
+	stored := model.Rule{
+		ID:        "r1",
+		Name:      "golang stars",
+		Enabled:   true,
+		Condition: model.RuleCondition{EventType: "WatchEvent", Repo: "golang/*", GroupBy: "repo", WindowSeconds: 3600, MinCount: 50},
+		Webhook:   model.RuleWebhook{Url: "https://example.com/hook", Secret: "secret"},
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+	validRule := `{"name":"golang stars","condition":{"event_type":"WatchEvent","repo":"golang/*","group_by":"repo","window_seconds":3600,"min_count":50},"webhook":{"url":"https://example.com/hook","secret":"secret"}}`
+	findStored := func(_ context.Context, id interface{}, result interface{}) error {
+		*result.(*model.Rule) = stored
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expect   func(store *mockstores.MockDocumentStore)
+		wantCode int
+		wantRule *model.Rule
+	}{
+		{
+			name:   "lists the rules without their secret",
+			method: http.MethodGet,
+			path:   "/rules",
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().All(gomock.Any()).DoAndReturn(func(results interface{}) error {
+					*results.(*[]model.Rule) = []model.Rule{stored}
+					return nil
+				})
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:   "creates an enabled rule",
+			method: http.MethodPost,
+			path:   "/rules",
+			body:   validRule,
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().Save(gomock.Any()).DoAndReturn(func(element interface{}) error {
+					rule := element.(model.Rule)
+					if !rule.Enabled || rule.Webhook.Secret != "secret" || !rule.CreatedAt.Equal(now) || len(rule.ID) == 0 {
+						t.Errorf("unexpected saved rule: %+v", rule)
+					}
+					return nil
+				})
+			},
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "rejects an invalid rule",
+			method:   http.MethodPost,
+			path:     "/rules",
+			body:     `{"name":"no window","condition":{"min_count":1},"webhook":{"url":"ftp://example.com","secret":"secret"}}`,
+			expect:   func(store *mockstores.MockDocumentStore) {},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "rejects unknown fields",
+			method:   http.MethodPost,
+			path:     "/rules",
+			body:     `{"name":"typo","conditions":{}}`,
+			expect:   func(store *mockstores.MockDocumentStore) {},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:   "gets a rule",
+			method: http.MethodGet,
+			path:   "/rules/r1",
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().FindByIdWithContext(gomock.Any(), "r1", gomock.Any()).DoAndReturn(findStored)
+			},
+			wantCode: http.StatusOK,
+			wantRule: &model.Rule{ID: "r1", Name: stored.Name, Enabled: true, Condition: stored.Condition, Webhook: model.RuleWebhook{Url: stored.Webhook.Url}, CreatedAt: created, UpdatedAt: created},
+		},
+		{
+			name:   "unknown rule",
+			method: http.MethodGet,
+			path:   "/rules/missing",
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().FindByIdWithContext(gomock.Any(), "missing", gomock.Any()).Return(stores.ErrNotFound)
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:   "replaces a rule and keeps its secret",
+			method: http.MethodPut,
+			path:   "/rules/r1",
+			body:   `{"name":"renamed","enabled":false,"condition":{"actor":"torvalds","window_seconds":600,"min_count":1},"webhook":{"url":"https://example.com/other"}}`,
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().FindByIdWithContext(gomock.Any(), "r1", gomock.Any()).DoAndReturn(findStored)
+				store.EXPECT().ReplaceById("r1", model.Rule{
+					ID:        "r1",
+					Name:      "renamed",
+					Condition: model.RuleCondition{Actor: "torvalds", WindowSeconds: 600, MinCount: 1},
+					Webhook:   model.RuleWebhook{Url: "https://example.com/other", Secret: "secret"},
+					CreatedAt: created,
+					UpdatedAt: now,
+				}).Return(nil)
+			},
+			wantCode: http.StatusOK,
+			wantRule: &model.Rule{
+				ID:        "r1",
+				Name:      "renamed",
+				Condition: model.RuleCondition{Actor: "torvalds", WindowSeconds: 600, MinCount: 1},
+				Webhook:   model.RuleWebhook{Url: "https://example.com/other"},
+				CreatedAt: created,
+				UpdatedAt: now,
+			},
+		},
+		{
+			name:   "deletes a rule",
+			method: http.MethodDelete,
+			path:   "/rules/r1",
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().DeleteById("r1").Return(true, nil)
+			},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:   "deletes an unknown rule",
+			method: http.MethodDelete,
+			path:   "/rules/missing",
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().DeleteById("missing").Return(false, nil)
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:   "store failure",
+			method: http.MethodGet,
+			path:   "/rules",
+			expect: func(store *mockstores.MockDocumentStore) {
+				store.EXPECT().All(gomock.Any()).Return(errors.New("connection refused"))
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name:     "method not allowed",
+			method:   http.MethodDelete,
+			path:     "/rules",
+			expect:   func(store *mockstores.MockDocumentStore) {},
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := mockstores.NewMockDocumentStore(mockCtrl)
+			tt.expect(store)
+			receiver := RulesHandler{store: store, now: func() time.Time { return now }}
+
+			recorder := httptest.NewRecorder()
+			receiver.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("expected: %d, got: %d %s", tt.wantCode, recorder.Code, recorder.Body.String())
+			}
+			if strings.Contains(recorder.Body.String(), `"secret"`) {
+				t.Errorf("expected no secret in the response, got: %s", recorder.Body.String())
+			}
+			if tt.wantRule != nil {
+				var got model.Rule
+				err := json.Unmarshal(recorder.Body.Bytes(), &got)
+				if err != nil || !reflect.DeepEqual(got, *tt.wantRule) {
+					t.Errorf("expected: %+v, got: %+v (%v)", *tt.wantRule, got, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"github-events-microservices/api/config"
+	"github-events-microservices/api/metrics"
+	"github-events-microservices/api/search"
+	"github-events-microservices/model"
+	"github-events-microservices/stores"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SearchPath         = "/search"
+	searchQueryParam   = "q"
+	searchTypesParam   = "types"
+	searchOffsetParam  = "offset"
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// maxSearchWindow bounds offset + limit, every type is searched for that many results before they are ranked
+	maxSearchWindow = 1000
+)
+
+// SearchResult is a found repo, user or event, with its matching fields highlighted
+type SearchResult struct {
+	Type       string            `json:"type"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+	Item       interface{}       `json:"item"`
+}
+
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+	Offset  int            `json:"offset"`
+	Limit   int            `json:"limit"`
+	// NextOffset is the offset of the next page, when there are more results
+	NextOffset *int `json:"next_offset,omitempty"`
+}
+
+type searchParams struct {
+	query  search.Query
+	types  []string
+	offset int
+	limit  int
+}
+
+// Search finds the repos, users and events matching a text, a word prefix or a fuzzy word, ranked across the types.
+// Pattern searches only rank the first matching documents by id of every type, not all of them.
+func (receiver RequestsHandler) Search(writer http.ResponseWriter, request *http.Request) {
+	params, err := receiver.parseSearchParams(request)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	window := int64(params.offset + params.limit + 1)
+	results := make([]SearchResult, 0)
+	for _, dataType := range params.types {
+		fields := searchFields(dataType)
+		store, ok := receiver.storesMap[dataType].(stores.SearchStore)
+		if !ok {
+			writeError(writer, http.StatusBadRequest, fmt.Sprintf("data type '%s' can't be searched", dataType))
+			return
+		}
+		ctx, span := tracer.Start(request.Context(), "store.Search", trace.WithAttributes(
+			attribute.String("data_type", dataType),
+			attribute.String("mode", params.query.Mode)))
+		start := time.Now()
+		var hits []stores.SearchHit
+		if params.query.Mode == search.ModeText {
+			hits, err = store.SearchTextWithContext(ctx, params.query.Text(), window)
+		} else {
+			hits, err = store.SearchPatternWithContext(ctx, fieldNames(fields), params.query.Pattern(), window, config.ApiConfiguration.SearchPatternTimeout)
+		}
+		metrics.ObserveStoreQuery(dataType, "search", start)
+		endSpan(span, err)
+		if errors.Is(err, stores.ErrTimeout) {
+			slog.WarnContext(request.Context(), fmt.Sprintf("%s search of %s timed out: %s", params.query.Mode, dataType, err.Error()))
+			writeError(writer, http.StatusServiceUnavailable, fmt.Sprintf("the %s search of %s took too long, search whole words or fewer types", params.query.Mode, dataType))
+			return
+		}
+		if err != nil {
+			errorMessage := fmt.Sprintf("failed to search %s", dataType)
+			slog.ErrorContext(request.Context(), fmt.Sprintf("%s: %s", errorMessage, err.Error()))
+			writeError(writer, http.StatusInternalServerError, errorMessage)
+			return
+		}
+		for _, hit := range hits {
+			result, err := newSearchResult(params.query, dataType, fields, hit)
+			if err != nil {
+				slog.WarnContext(request.Context(), fmt.Sprintf("skipped a search result of %s: %s", dataType, err.Error()))
+				continue
+			}
+			results = append(results, result)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score == results[j].Score {
+			return results[i].Type < results[j].Type
+		}
+		return results[i].Score > results[j].Score
+	})
+	response := SearchResponse{Results: results[min(params.offset, len(results)):], Offset: params.offset, Limit: params.limit}
+	if len(response.Results) > params.limit {
+		response.Results = response.Results[:params.limit]
+		nextOffset := params.offset + params.limit
+		response.NextOffset = &nextOffset
+	}
+	writeJsonResponse(writer, response, "search")
+}
+
+func (receiver RequestsHandler) parseSearchParams(request *http.Request) (*searchParams, error) {
+	query, err := search.ParseQuery(request.URL.Query().Get(searchQueryParam))
+	if err != nil {
+		return nil, err
+	}
+	limit, err := parseIntParam(config.LimitParamKey, request.URL.Query().Get(config.LimitParamKey), defaultSearchLimit)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := parseIntParam(searchOffsetParam, request.URL.Query().Get(searchOffsetParam), 0)
+	if err != nil {
+		return nil, err
+	}
+	if *limit < 1 || *limit > maxSearchLimit {
+		return nil, fmt.Errorf("invalid limit. Limit must be between 1 and %d", maxSearchLimit)
+	}
+	if *offset < 0 || *offset+*limit > maxSearchWindow {
+		return nil, fmt.Errorf("invalid offset. Offset + limit must be between 1 and %d", maxSearchWindow)
+	}
+
+	types := receiver.supportedDataTypes
+	if value := request.URL.Query().Get(searchTypesParam); len(value) > 0 {
+		types = nil
+		for _, dataType := range strings.Split(value, ",") {
+			dataType = strings.TrimSpace(dataType)
+			if len(searchFields(dataType)) == 0 {
+				return nil, fmt.Errorf("unknown data type: '%s'. Supported data types are: %s", dataType, strings.Join(receiver.supportedDataTypes, ", "))
+			}
+			types = append(types, dataType)
+		}
+	}
+	return &searchParams{query: query, types: types, offset: *offset, limit: *limit}, nil
+}
+
+// SearchCost grows with the searched types and results, a fuzzy search scanning the searched fields costs more
+func SearchCost(request *http.Request) int {
+	// events, repos and users unless types is set
+	types := 3
+	if value := request.URL.Query().Get(searchTypesParam); len(value) > 0 {
+		types = len(strings.Split(value, ","))
+	}
+	limit, limitErr := strconv.Atoi(getParam(request.URL.Query(), config.LimitParamKey, strconv.Itoa(defaultSearchLimit)))
+	offset, offsetErr := strconv.Atoi(getParam(request.URL.Query(), searchOffsetParam, "0"))
+	if limitErr != nil || offsetErr != nil || limit < 0 || offset < 0 {
+		return 1
+	}
+	cost := types * (1 + (offset+limit)/listCostPageSize)
+	if strings.HasSuffix(strings.TrimSpace(request.URL.Query().Get(searchQueryParam)), "~") {
+		cost *= 5
+	}
+	return cost
+}
+
+func newSearchResult(query search.Query, dataType string, fields []stores.TextField, hit stores.SearchHit) (SearchResult, error) {
+	item, err := createResult(dataType)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	err = bson.Unmarshal(hit.Document, item)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	values := make(map[string]string)
+	for _, field := range fields {
+		values[field.Field], _ = hit.Document.Lookup(field.Field).StringValueOK()
+	}
+	return SearchResult{
+		Type:       dataType,
+		Score:      query.Score(hit.Score, values, fields),
+		Highlights: query.Highlight(values, fields),
+		Item:       item,
+	}, nil
+}
+
+// createResult returns a pointer to a single item of a data type, like createResults does for lists
+func createResult(dataType string) (interface{}, error) {
+	switch dataType {
+	case config.ApiConfiguration.EventsCollection:
+		return &model.Event{}, nil
+	case config.ApiConfiguration.ReposCollection:
+		return &model.Repo{}, nil
+	case config.ApiConfiguration.UsersCollection:
+		return &model.User{}, nil
+	default:
+		return nil, fmt.Errorf("unknown data type: %s", dataType)
+	}
+}
+
+// searchFields returns the text indexed fields of a data type, none when it can't be searched
+func searchFields(dataType string) []stores.TextField {
+	switch dataType {
+	case config.ApiConfiguration.EventsCollection:
+		return stores.EventTextFields
+	case config.ApiConfiguration.ReposCollection:
+		return stores.RepoTextFields
+	case config.ApiConfiguration.UsersCollection:
+		return stores.UserTextFields
+	default:
+		return nil
+	}
+}
+
+func fieldNames(fields []stores.TextField) []string {
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		names = append(names, field.Field)
+	}
+	return names
+}
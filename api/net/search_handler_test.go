@@ -0,0 +1,118 @@
+package net
+
+import (
+	"encoding/json"
+	"github-events-microservices/api/config"
+	"github-events-microservices/model"
+	"github-events-microservices/stores"
+	mockstores "github-events-microservices/stores/mocks"
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// searchableStoreMock is a searchable read store, as the mongo stores are
+type searchableStoreMock struct {
+	*mockstores.MockReadStore
+	*mockstores.MockSearchStore
+}
+
+func TestRequestsHandler_Search(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	reposStore := mockstores.NewMockSearchStore(mockCtrl)
+	reposStore.EXPECT().
+		SearchPatternWithContext(gomock.Any(), []string{"name", "owner"}, gomock.Any(), int64(3), config.ApiConfiguration.SearchPatternTimeout).
+		Return([]stores.SearchHit{
+			searchHit(t, model.Repo{Name: "kubernetes-operator", Owner: "acme"}),
+			searchHit(t, model.Repo{Name: "kubectl", Owner: "kubernetes"}),
+		}, nil)
+	usersStore := mockstores.NewMockSearchStore(mockCtrl)
+	usersStore.EXPECT().
+		SearchPatternWithContext(gomock.Any(), []string{"login", "name", "company", "location"}, gomock.Any(), int64(3), config.ApiConfiguration.SearchPatternTimeout).
+		Return([]stores.SearchHit{searchHit(t, model.User{Login: "kube", Name: "Kube Rnetes"})}, nil)
+	receiver := RequestsHandler{
+		storesMap: map[string]stores.ReadStore{
+			config.ApiConfiguration.ReposCollection:  searchableStoreMock{mockstores.NewMockReadStore(mockCtrl), reposStore},
+			config.ApiConfiguration.UsersCollection:  searchableStoreMock{mockstores.NewMockReadStore(mockCtrl), usersStore},
+			config.ApiConfiguration.EventsCollection: mockstores.NewMockReadStore(mockCtrl),
+		},
+		supportedDataTypes: []string{config.ApiConfiguration.EventsCollection, config.ApiConfiguration.ReposCollection, config.ApiConfiguration.UsersCollection},
+	}
+
+	recorder := httptest.NewRecorder()
+	receiver.Search(recorder, httptest.NewRequest(http.MethodGet, "/search?q=kube*&types=repos,users&limit=2", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected: 200, got: %d %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		Results []struct {
+			Type       string            `json:"type"`
+			Score      float64           `json:"score"`
+			Highlights map[string]string `json:"highlights"`
+		} `json:"results"`
+		NextOffset *int `json:"next_offset"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the user login and name (10 + 5 doubled for the exact login) outrank the repo name and owner (10 + 5)
+	if len(response.Results) != 2 || response.Results[0].Type != "users" || response.Results[0].Score != 25 || response.Results[1].Score != 15 {
+		t.Fatalf("expected the 2 best results, got: %+v", response.Results)
+	}
+	if response.Results[1].Highlights["owner"] != "<em>kube</em>rnetes" || response.NextOffset == nil || *response.NextOffset != 2 {
+		t.Errorf("expected highlights and a next page, got: %+v %v", response.Results[1], response.NextOffset)
+	}
+
+	for _, target := range []string{"/search", "/search?q=k*", "/search?q=go&types=orgs", "/search?q=go&limit=101", "/search?q=go&offset=990&limit=20"} {
+		recorder := httptest.NewRecorder()
+		receiver.Search(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for '%s', got: %d", target, recorder.Code)
+		}
+	}
+}
+
+func TestRequestsHandler_Search_patternTimeout(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	reposStore := mockstores.NewMockSearchStore(mockCtrl)
+	reposStore.EXPECT().
+		SearchPatternWithContext(gomock.Any(), []string{"name", "owner"}, gomock.Any(), int64(21), config.ApiConfiguration.SearchPatternTimeout).
+		Return(nil, stores.ErrTimeout)
+	receiver := RequestsHandler{
+		storesMap: map[string]stores.ReadStore{
+			config.ApiConfiguration.ReposCollection: searchableStoreMock{mockstores.NewMockReadStore(mockCtrl), reposStore},
+		},
+		supportedDataTypes: []string{config.ApiConfiguration.ReposCollection},
+	}
+
+	recorder := httptest.NewRecorder()
+	receiver.Search(recorder, httptest.NewRequest(http.MethodGet, "/search?q=kubernets~&types=repos", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected: 503, got: %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestSearchCost(t *testing.T) {
+	for target, want := range map[string]int{
+		"/search?q=go":                           3,
+		"/search?q=go&types=repos&limit=100":     2,
+		"/search?q=kubernets~&types=repos,users": 10,
+		"/search?q=go&offset=180&limit=20":       9,
+		"/search?q=go&limit=invalid":             1,
+	} {
+		if got := SearchCost(httptest.NewRequest(http.MethodGet, target, nil)); got != want {
+			t.Errorf("expected a cost of %d for '%s', got: %d", want, target, got)
+		}
+	}
+}
+
+func searchHit(t *testing.T, document interface{}) stores.SearchHit {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stores.SearchHit{Document: raw}
+}
@@ -0,0 +1,225 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TierAnonymous limits the clients identified by their ip, TierDefault the authenticated clients without a tier
+	TierAnonymous = "anonymous"
+	TierDefault   = "default"
+)
+
+type Options struct {
+	Enabled bool `config:"RATE_LIMIT_ENABLED"`
+	// Tiers are comma separated <name>=<requests per second>:<burst>:<daily quota>, a 0 quota is unlimited
+	Tiers string `config:"RATE_LIMIT_TIERS" default:"anonymous=2:20:0,default=20:100:0" required:"true"`
+	// TrustForwardedFor identifies anonymous clients by the first X-Forwarded-For address, only behind a trusted proxy
+	TrustForwardedFor bool `config:"RATE_LIMIT_TRUST_FORWARDED_FOR"`
+}
+
+func (receiver Options) Validate() error {
+	_, err := ParseTiers(receiver.Tiers)
+	return err
+}
+
+// Tier is a token bucket refilled at Rate tokens per second up to Burst tokens, with a daily quota of tokens.
+// Requests take as many tokens as they cost.
+type Tier struct {
+	Name       string
+	Rate       float64
+	Burst      int
+	DailyQuota int64
+}
+
+// Decision is the outcome of a request, with the state of the bucket of its client
+type Decision struct {
+	Allowed bool
+	// QuotaExceeded is set when the request was rejected by the daily quota rather than by the rate
+	QuotaExceeded bool
+	Limit         int
+	Remaining     int
+	// Reset is when the bucket is full again
+	Reset      time.Time
+	RetryAfter time.Duration
+}
+
+// Usage is the usage of a client since the start of the day, in UTC
+type Usage struct {
+	Client   string `json:"client"`
+	Tier     string `json:"tier"`
+	Day      string `json:"day"`
+	Requests int64  `json:"requests"`
+	Cost     int64  `json:"cost"`
+	Rejected int64  `json:"rejected"`
+}
+
+// Limiter keeps a token bucket and the daily usage of every client. Usage is always tracked, requests are only
+// rejected when enforced. Clients are forgotten at the end of the day, so memory is bound by the daily clients.
+type Limiter struct {
+	mu      sync.Mutex
+	tiers   map[string]Tier
+	enforce bool
+	clients map[string]*client
+	day     string
+	now     func() time.Time
+}
+
+type client struct {
+	tokens    float64
+	updatedAt time.Time
+	usage     Usage
+}
+
+// refill adds the tokens of the time elapsed since the last request, up to the burst of the tier
+func (receiver *client) refill(tier Tier, now time.Time) {
+	receiver.tokens = math.Min(float64(tier.Burst), receiver.tokens+now.Sub(receiver.updatedAt).Seconds()*tier.Rate)
+	receiver.updatedAt = now
+}
+
+// Allow takes the cost of a request from the bucket of the client, in the given tier or else the default one.
+// A client moved to another tier starts with a full bucket.
+func (receiver *Limiter) Allow(clientId string, tierName string, cost int) Decision {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	now := receiver.now()
+	receiver.startDay(now)
+	tier := receiver.tier(tierName)
+	current, ok := receiver.clients[clientId]
+	if !ok || current.usage.Tier != tier.Name {
+		current = &client{tokens: float64(tier.Burst), updatedAt: now, usage: Usage{Client: clientId, Tier: tier.Name, Day: receiver.day}}
+		receiver.clients[clientId] = current
+	}
+	current.refill(tier, now)
+
+	decision := receiver.decide(current, tier, cost, now)
+	if decision.Allowed {
+		current.tokens -= float64(cost)
+		current.usage.Requests++
+		current.usage.Cost += int64(cost)
+	} else {
+		current.usage.Rejected++
+	}
+	decision.Remaining = max(0, int(current.tokens))
+	decision.Reset = now.Add(time.Duration((float64(tier.Burst) - current.tokens) / tier.Rate * float64(time.Second)))
+	return decision
+}
+
+// decide rejects a request exceeding the daily quota or the tokens of the bucket of its client, when enforced
+func (receiver *Limiter) decide(current *client, tier Tier, cost int, now time.Time) Decision {
+	decision := Decision{Allowed: true, Limit: tier.Burst}
+	// a request costing more than the burst empties the bucket, and the client pays the debt before the next one
+	required := math.Min(float64(cost), float64(tier.Burst))
+	if receiver.enforce && tier.DailyQuota > 0 && current.usage.Cost+int64(cost) > tier.DailyQuota {
+		decision.Allowed = false
+		decision.QuotaExceeded = true
+		decision.RetryAfter = now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+	} else if receiver.enforce && current.tokens < required {
+		decision.Allowed = false
+		decision.RetryAfter = time.Duration((required - current.tokens) / tier.Rate * float64(time.Second))
+	}
+	return decision
+}
+
+// tier returns the tier of the given name, or else the default one
+func (receiver *Limiter) tier(name string) Tier {
+	tier, ok := receiver.tiers[name]
+	if !ok {
+		return receiver.tiers[TierDefault]
+	}
+	return tier
+}
+
+// Usage returns the usage of the clients of the day, the costliest first
+func (receiver *Limiter) Usage() []Usage {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.startDay(receiver.now())
+	usages := make([]Usage, 0, len(receiver.clients))
+	for _, current := range receiver.clients {
+		usages = append(usages, current.usage)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Cost == usages[j].Cost {
+			return usages[i].Client < usages[j].Client
+		}
+		return usages[i].Cost > usages[j].Cost
+	})
+	return usages
+}
+
+// Enforced reports whether requests are rejected over their limits, rather than only tracked
+func (receiver *Limiter) Enforced() bool {
+	return receiver.enforce
+}
+
+// HasTier reports whether the tier is configured
+func (receiver *Limiter) HasTier(name string) bool {
+	_, ok := receiver.tiers[name]
+	return ok
+}
+
+func (receiver *Limiter) startDay(now time.Time) {
+	day := now.UTC().Format(time.DateOnly)
+	if day != receiver.day {
+		receiver.day = day
+		receiver.clients = make(map[string]*client)
+	}
+}
+
+// ParseTiers parses comma separated <name>=<rate>:<burst>:<daily quota> tiers, e.g. anonymous=2:20:0,default=20:100:100000.
+// The anonymous and default tiers are required.
+func ParseTiers(value string) (map[string]Tier, error) {
+	tiers := make(map[string]Tier)
+	var errs []error
+	for _, text := range strings.Split(value, ",") {
+		text = strings.TrimSpace(text)
+		if len(text) == 0 {
+			continue
+		}
+		tier, err := parseTier(text)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		tiers[tier.Name] = tier
+	}
+	for _, required := range []string{TierAnonymous, TierDefault} {
+		if _, ok := tiers[required]; !ok && len(errs) == 0 {
+			errs = append(errs, fmt.Errorf("the %s rate limit tier is required", required))
+		}
+	}
+	return tiers, errors.Join(errs...)
+}
+
+func parseTier(text string) (Tier, error) {
+	invalid := fmt.Errorf("invalid rate limit tier: '%s'. Expected <name>=<requests per second>:<burst>:<daily quota>", text)
+	name, limits, ok := strings.Cut(text, "=")
+	parts := strings.Split(limits, ":")
+	if !ok || len(name) == 0 || len(parts) != 3 {
+		return Tier{}, invalid
+	}
+	rate, rateErr := strconv.ParseFloat(parts[0], 64)
+	burst, burstErr := strconv.Atoi(parts[1])
+	quota, quotaErr := strconv.ParseInt(parts[2], 10, 64)
+	if rateErr != nil || burstErr != nil || quotaErr != nil || rate <= 0 || burst < 1 || quota < 0 {
+		return Tier{}, invalid
+	}
+	return Tier{Name: name, Rate: rate, Burst: burst, DailyQuota: quota}, nil
+}
+
+// New creates a limiter of the configured tiers, which only rejects requests when enabled
+func New(options Options) (*Limiter, error) {
+	tiers, err := ParseTiers(options.Tiers)
+	if err != nil {
+		return nil, err
+	}
+	return &Limiter{tiers: tiers, enforce: options.Enabled, clients: make(map[string]*client), now: time.Now}, nil
+}
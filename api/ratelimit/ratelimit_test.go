@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(t *testing.T, enforce bool, now *time.Time) *Limiter {
+	limiter, err := New(Options{Enabled: enforce, Tiers: "anonymous=1:5:0,default=10:20:50"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+func TestParseTiers(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]Tier
+		wantErr string
+	}{
+		{
+			name:  "tiers",
+			value: "anonymous=0.5:10:0, default=20:100:100000",
+			want: map[string]Tier{
+				TierAnonymous: {Name: TierAnonymous, Rate: 0.5, Burst: 10},
+				TierDefault:   {Name: TierDefault, Rate: 20, Burst: 100, DailyQuota: 100000},
+			},
+		},
+		{name: "missing default tier", value: "anonymous=1:10:0", wantErr: "the default rate limit tier is required"},
+		{name: "missing quota", value: "anonymous=1:10,default=1:10:0", wantErr: "invalid rate limit tier: 'anonymous=1:10'"},
+		{name: "zero rate", value: "anonymous=0:10:0,default=1:10:0", wantErr: "invalid rate limit tier: 'anonymous=0:10:0'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTiers(tt.value)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Errorf("expected error: %s, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected: %+v, got: %+v (%v)", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(t, true, &now)
+
+	for i := 0; i < 5; i++ {
+		if decision := limiter.Allow("ip:10.0.0.1", TierAnonymous, 1); !decision.Allowed || decision.Remaining != 4-i {
+			t.Fatalf("expected request %d to be allowed, got: %+v", i, decision)
+		}
+	}
+	decision := limiter.Allow("ip:10.0.0.1", TierAnonymous, 1)
+	if decision.Allowed || decision.QuotaExceeded || decision.RetryAfter != time.Second || decision.Limit != 5 {
+		t.Errorf("expected the empty bucket to reject the request for a second, got: %+v", decision)
+	}
+	if decision := limiter.Allow("ip:10.0.0.2", TierAnonymous, 1); !decision.Allowed {
+		t.Errorf("expected another client to have its own bucket, got: %+v", decision)
+	}
+
+	now = now.Add(2 * time.Second)
+	if decision := limiter.Allow("ip:10.0.0.1", TierAnonymous, 1); !decision.Allowed || decision.Remaining != 1 {
+		t.Errorf("expected the bucket to be refilled, got: %+v", decision)
+	}
+
+	// a request costing more than the burst is allowed on a full bucket, and paid back before the next one
+	if decision := limiter.Allow("key:k1", TierDefault, 30); !decision.Allowed || decision.Remaining != 0 {
+		t.Errorf("expected the costly request to be allowed, got: %+v", decision)
+	}
+	if decision := limiter.Allow("key:k1", TierDefault, 1); decision.Allowed || decision.RetryAfter != 1100*time.Millisecond {
+		t.Errorf("expected the debt to be paid first, got: %+v", decision)
+	}
+
+	now = now.Add(10 * time.Second)
+	if decision := limiter.Allow("key:k1", TierDefault, 20); !decision.Allowed {
+		t.Errorf("expected the request to be allowed, got: %+v", decision)
+	}
+	now = now.Add(10 * time.Second)
+	decision = limiter.Allow("key:k1", TierDefault, 1)
+	if decision.Allowed || !decision.QuotaExceeded || decision.RetryAfter != 12*time.Hour-22*time.Second {
+		t.Errorf("expected the daily quota to be exceeded until midnight, got: %+v", decision)
+	}
+
+	now = time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC)
+	if decision := limiter.Allow("key:k1", TierDefault, 1); !decision.Allowed {
+		t.Errorf("expected the quota to be reset the next day, got: %+v", decision)
+	}
+}
+
+func TestLimiter_Usage(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(t, false, &now)
+	for i := 0; i < 7; i++ {
+		if decision := limiter.Allow("ip:10.0.0.1", TierAnonymous, 1); !decision.Allowed {
+			t.Fatalf("expected requests to be allowed when not enforced, got: %+v", decision)
+		}
+	}
+	limiter.Allow("key:k1", "unknown", 100)
+
+	want := []Usage{
+		{Client: "key:k1", Tier: TierDefault, Day: "2024-05-10", Requests: 1, Cost: 100},
+		{Client: "ip:10.0.0.1", Tier: TierAnonymous, Day: "2024-05-10", Requests: 7, Cost: 7},
+	}
+	if got := limiter.Usage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected: %+v, got: %+v", want, got)
+	}
+
+	now = now.Add(24 * time.Hour)
+	if got := limiter.Usage(); len(got) != 0 {
+		t.Errorf("expected the usage to be reset the next day, got: %+v", got)
+	}
+}
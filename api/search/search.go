@@ -0,0 +1,209 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"github-events-microservices/stores"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	ModeText   = "text"
+	ModePrefix = "prefix"
+	ModeFuzzy  = "fuzzy"
+
+	MaxQueryLength  = 100
+	minPrefixLength = 2
+	minFuzzyLength  = 4
+
+	highlightStart = "<em>"
+	highlightEnd   = "</em>"
+	// wordBoundary matches the start or the end of a value or a non alphanumeric character, e.g. the - of awesome-k8s
+	wordBoundary = `(?:^|[^\p{L}\p{N}])`
+)
+
+// Query is a parsed search query: alphanumeric words matched by the text index, or a single word prefix ending with *,
+// or a single word ending with ~ matched within one edit
+type Query struct {
+	Mode  string
+	Words []string
+}
+
+// ParseQuery parses the q param of a search
+func ParseQuery(text string) (Query, error) {
+	text = strings.TrimSpace(text)
+	if len(text) == 0 {
+		return Query{}, errors.New("missing search query 'q'")
+	}
+	if len(text) > MaxQueryLength {
+		return Query{}, fmt.Errorf("search query is longer than %d characters", MaxQueryLength)
+	}
+	query := Query{Mode: ModeText}
+	minLength := 1
+	if strings.HasSuffix(text, "*") {
+		query.Mode, minLength = ModePrefix, minPrefixLength
+	} else if strings.HasSuffix(text, "~") {
+		query.Mode, minLength = ModeFuzzy, minFuzzyLength
+	}
+	// words are split like the text index splits the fields, the phrases and negations of its syntax are not supported
+	query.Words = strings.FieldsFunc(strings.ToLower(strings.TrimRight(text, "*~")), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(query.Words) == 0 {
+		return Query{}, fmt.Errorf("invalid search query: '%s'", text)
+	}
+	if query.Mode != ModeText && (len(query.Words) > 1 || len([]rune(query.Words[0])) < minLength) {
+		return Query{}, fmt.Errorf("a %s search is a single word of at least %d characters, e.g. k8s* or kubernets~", query.Mode, minLength)
+	}
+	return query, nil
+}
+
+// Text returns the words of a text search
+func (receiver Query) Text() string {
+	return strings.Join(receiver.Words, " ")
+}
+
+// Pattern returns the regular expression matching the prefix of a word, or a word within one edit
+func (receiver Query) Pattern() string {
+	word := []rune(receiver.Words[0])
+	if receiver.Mode == ModePrefix {
+		return wordBoundary + regexp.QuoteMeta(string(word))
+	}
+	// a deleted or substituted character at every position, and an inserted one before, between and after them
+	alternatives := make([]string, 0, 2*len(word)+1)
+	for i := 0; i <= len(word); i++ {
+		alternatives = append(alternatives, regexp.QuoteMeta(string(word[:i]))+"."+regexp.QuoteMeta(string(word[i:])))
+		if i < len(word) {
+			alternatives = append(alternatives, regexp.QuoteMeta(string(word[:i]))+".?"+regexp.QuoteMeta(string(word[i+1:])))
+		}
+	}
+	return wordBoundary + "(?:" + strings.Join(alternatives, "|") + ")" + `(?:$|[^\p{L}\p{N}])`
+}
+
+// Score ranks a document by its text score plus the weight of every field matching a word, doubled when the whole
+// field is the query, so exact matches come first
+func (receiver Query) Score(textScore float64, values map[string]string, fields []stores.TextField) float64 {
+	score := textScore
+	for _, field := range fields {
+		value := values[field.Field]
+		matched := 0
+		for _, word := range receiver.Words {
+			if len(receiver.matches([]rune(value), word)) > 0 {
+				matched++
+			}
+		}
+		weight := float64(field.Weight * int32(matched))
+		if strings.EqualFold(value, receiver.Text()) {
+			weight *= 2
+		}
+		score += weight
+	}
+	return score
+}
+
+// Highlight returns the html escaped fields matching a word, with their matches between <em> tags
+func (receiver Query) Highlight(values map[string]string, fields []stores.TextField) map[string]string {
+	highlights := make(map[string]string)
+	for _, field := range fields {
+		value := []rune(values[field.Field])
+		highlighted := make([]bool, len(value))
+		found := false
+		for _, word := range receiver.Words {
+			for _, match := range receiver.matches(value, word) {
+				for i := match[0]; i < match[1]; i++ {
+					highlighted[i] = true
+				}
+				found = true
+			}
+		}
+		if found {
+			highlights[field.Field] = highlight(value, highlighted)
+		}
+	}
+	return highlights
+}
+
+// matches returns the rune ranges of the words of a value matching a query word: the whole equal words of a text
+// search, the matching prefixes of a prefix search or the whole words within one edit of a fuzzy search
+func (receiver Query) matches(value []rune, word string) [][2]int {
+	var matches [][2]int
+	wordRunes := []rune(word)
+	for _, token := range tokens(value) {
+		candidate := []rune(strings.ToLower(string(value[token[0]:token[1]])))
+		switch receiver.Mode {
+		case ModePrefix:
+			if len(candidate) >= len(wordRunes) && string(candidate[:len(wordRunes)]) == word {
+				matches = append(matches, [2]int{token[0], min(token[0]+len(wordRunes), token[1])})
+			}
+		case ModeFuzzy:
+			if editDistance(candidate, wordRunes) <= 1 {
+				matches = append(matches, token)
+			}
+		default:
+			if string(candidate) == word {
+				matches = append(matches, token)
+			}
+		}
+	}
+	return matches
+}
+
+// tokens returns the rune ranges of the alphanumeric words of a value
+func tokens(value []rune) [][2]int {
+	var ranges [][2]int
+	start := -1
+	for i, r := range value {
+		alphanumeric := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if alphanumeric && start < 0 {
+			start = i
+		} else if !alphanumeric && start >= 0 {
+			ranges = append(ranges, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		ranges = append(ranges, [2]int{start, len(value)})
+	}
+	return ranges
+}
+
+func highlight(value []rune, highlighted []bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); {
+		j := i
+		for j < len(value) && highlighted[j] == highlighted[i] {
+			j++
+		}
+		if highlighted[i] {
+			builder.WriteString(highlightStart + html.EscapeString(string(value[i:j])) + highlightEnd)
+		} else {
+			builder.WriteString(html.EscapeString(string(value[i:j])))
+		}
+		i = j
+	}
+	return builder.String()
+}
+
+// editDistance is the Levenshtein distance of two words
+func editDistance(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,125 @@
+package search
+
+import (
+	"github-events-microservices/stores"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    Query
+		wantErr bool
+	}{
+		{name: "text", text: " Kubernetes Operator ", want: Query{Mode: ModeText, Words: []string{"kubernetes", "operator"}}},
+		{name: "hyphenated words", text: "awesome-k8s", want: Query{Mode: ModeText, Words: []string{"awesome", "k8s"}}},
+		{name: "prefix", text: "K8*", want: Query{Mode: ModePrefix, Words: []string{"k8"}}},
+		{name: "fuzzy", text: "kubernets~", want: Query{Mode: ModeFuzzy, Words: []string{"kubernets"}}},
+		{name: "empty", text: "  ", wantErr: true},
+		{name: "no words", text: "-*", wantErr: true},
+		{name: "short prefix", text: "k*", wantErr: true},
+		{name: "short fuzzy", text: "k8s~", wantErr: true},
+		{name: "several words prefix", text: "go k8*", wantErr: true},
+		{name: "too long", text: string(make([]byte, MaxQueryLength+1)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuery(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected an error: %t, got: %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestQuery_Pattern(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		matches   []string
+		unmatched []string
+	}{
+		{name: "prefix", text: "kube*", matches: []string{"kubernetes", "awesome-kubectl", "Go Kube"}, unmatched: []string{"minikube", "kub"}},
+		{name: "fuzzy", text: "kubernets~", matches: []string{"kubernetes", "kubernets", "my-kubernetz", "kubernet", "xkubernets", "kubernetsx"}, unmatched: []string{"kubernetesxx", "kubernetesio", "kubrnetz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseQuery(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Mongo matches the pattern case insensitively
+			pattern := regexp.MustCompile("(?i)" + query.Pattern())
+			for _, value := range tt.matches {
+				if !pattern.MatchString(value) {
+					t.Errorf("expected %s to match '%s'", pattern, value)
+				}
+			}
+			for _, value := range tt.unmatched {
+				if pattern.MatchString(value) {
+					t.Errorf("expected %s not to match '%s'", pattern, value)
+				}
+			}
+		})
+	}
+}
+
+func TestQuery_Score(t *testing.T) {
+	fields := []stores.TextField{{Field: "name", Weight: 10}, {Field: "owner", Weight: 5}}
+	query, err := ParseQuery("kubernetes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exact := query.Score(1.5, map[string]string{"name": "Kubernetes", "owner": "kubernetes"}, fields)
+	partial := query.Score(1.5, map[string]string{"name": "kubernetes-operator", "owner": "acme"}, fields)
+	none := query.Score(1.5, map[string]string{"name": "operator", "owner": "acme"}, fields)
+	if exact != 31.5 || partial != 11.5 || none != 1.5 {
+		t.Errorf("expected scores 31.5, 11.5 and 1.5, got: %v, %v and %v", exact, partial, none)
+	}
+}
+
+func TestQuery_Highlight(t *testing.T) {
+	fields := []stores.TextField{{Field: "name", Weight: 10}, {Field: "owner", Weight: 5}, {Field: "company", Weight: 1}}
+	tests := []struct {
+		name   string
+		text   string
+		values map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "text",
+			text:   "go tools",
+			values: map[string]string{"name": "Go <tools> for go", "owner": "golang", "company": "tools"},
+			want:   map[string]string{"name": "<em>Go</em> &lt;<em>tools</em>&gt; for <em>go</em>", "company": "<em>tools</em>"},
+		},
+		{
+			name:   "prefix",
+			text:   "kube*",
+			values: map[string]string{"name": "awesome-kubernetes", "owner": "minikube"},
+			want:   map[string]string{"name": "awesome-<em>kube</em>rnetes"},
+		},
+		{
+			name:   "fuzzy",
+			text:   "kubernets~",
+			values: map[string]string{"name": "kubernetes operator", "owner": "kubernetesio"},
+			want:   map[string]string{"name": "<em>kubernetes</em> operator"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseQuery(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := query.Highlight(tt.values, fields); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
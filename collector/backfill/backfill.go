@@ -0,0 +1,305 @@
+package backfill
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github-events-microservices/model"
+	"io"
+	"log/slog"
+	"time"
+)
+
+const (
+	hourFormat = "2006-01-02T15"
+	dayFormat  = "2006-01-02"
+)
+
+type Options struct {
+	// Source is the directory or the url of the archive files
+	Source string `config:"BACKFILL_SOURCE" default:"https://data.gharchive.org" required:"true"`
+	// From and To are the first and the last backfilled hours, e.g. 2015-01-01T15, or days, e.g. 2015-01-01
+	From         string `config:"BACKFILL_FROM"`
+	To           string `config:"BACKFILL_TO"`
+	ProgressFile string `config:"BACKFILL_PROGRESS_FILE" default:"./backfill-progress.json" required:"true"`
+	BatchSize    int    `config:"BACKFILL_BATCH_SIZE" default:"1000" min:"1"`
+	MaxRate      int    `config:"BACKFILL_MAX_EVENTS_PER_SECOND" default:"0" min:"0"`
+	// Timeout bounds the connection and the response headers of a download, the body is read as the batches are stored
+	Timeout time.Duration `config:"BACKFILL_DOWNLOAD_TIMEOUT_SECONDS" default:"60" unit:"seconds" min:"1"`
+}
+
+func (receiver Options) Validate() error {
+	var errs []error
+	from, err := parseHour(receiver.From, false)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid BACKFILL_FROM: %w", err))
+	}
+	to, err := parseHour(receiver.To, true)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid BACKFILL_TO: %w", err))
+	}
+	if len(errs) == 0 && !from.IsZero() && !to.IsZero() && to.Before(from) {
+		errs = append(errs, errors.New("BACKFILL_TO must not be before BACKFILL_FROM"))
+	}
+	return errors.Join(errs...)
+}
+
+// Range returns the first and the last backfilled hours, both required
+func (receiver Options) Range() (time.Time, time.Time, error) {
+	if len(receiver.From) == 0 || len(receiver.To) == 0 {
+		return time.Time{}, time.Time{}, errors.New("BACKFILL_FROM and BACKFILL_TO are required")
+	}
+	err := receiver.Validate()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, _ := parseHour(receiver.From, false)
+	to, _ := parseHour(receiver.To, true)
+	return from, to, nil
+}
+
+// parseHour parses an hour, or a day which starts at its first hour, or ends at its last one
+func parseHour(value string, end bool) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	hour, err := time.Parse(hourFormat, value)
+	if err == nil {
+		return hour, nil
+	}
+	day, err := time.Parse(dayFormat, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is neither an hour like 2015-01-01T15 nor a day like 2015-01-01", value)
+	}
+	if end {
+		return day.Add(23 * time.Hour), nil
+	}
+	return day, nil
+}
+
+// Store stores the backfilled events
+type Store interface {
+	SaveHistoryWithContext(ctx context.Context, events []model.Event) error
+	RecordWriteVersion()
+}
+
+// Result reports what a run backfilled
+type Result struct {
+	Hours    int
+	Missing  int
+	Events   int64
+	Filtered int64
+	Invalid  int64
+}
+
+// Job backfills the events of the GH Archive hours of a range, in batches of BACKFILL_BATCH_SIZE events and at most
+// BACKFILL_MAX_EVENTS_PER_SECOND. The progress is written to BACKFILL_PROGRESS_FILE after every stored batch, so an
+// interrupted run resumes from its last batch. Events stored twice are skipped as duplicates.
+type Job struct {
+	options Options
+	from    time.Time
+	to      time.Time
+	source  Source
+	store   Store
+	filter  func([]model.Event) []model.Event
+	now     func() time.Time
+	started time.Time
+	read    int64
+}
+
+// archiveEvent holds the fields of a GH Archive event, which are the ones of the GitHub events api
+type archiveEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Public    bool      `json:"public"`
+	CreatedAt time.Time `json:"created_at"`
+	Repo      struct {
+		Name string `json:"name"`
+		Url  string `json:"url"`
+	} `json:"repo"`
+	Actor struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Url       string `json:"url"`
+		AvatarUrl string `json:"avatar_url"`
+	} `json:"actor"`
+}
+
+// Run backfills the range from the saved progress of the same range, if any. Missing hours are skipped.
+func (receiver *Job) Run(ctx context.Context) (Result, error) {
+	progress := Progress{From: receiver.from, To: receiver.to, Hour: receiver.from}
+	saved, ok, err := readProgress(receiver.options.ProgressFile)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read progress: %w", err)
+	}
+	if ok && saved.resumes(receiver.from) {
+		progress, progress.To = saved, receiver.to
+		slog.Info(fmt.Sprintf("resuming backfill from %s, line %d", FileName(progress.Hour), progress.Lines))
+	}
+
+	var result Result
+	receiver.started, receiver.read = receiver.now(), 0
+	for !progress.Hour.After(receiver.to) {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		hourResult, err := receiver.backfillHour(ctx, &progress)
+		result.Events += hourResult.Events
+		result.Filtered += hourResult.Filtered
+		result.Invalid += hourResult.Invalid
+		if errors.Is(err, ErrMissingHour) {
+			slog.Warn(fmt.Sprintf("skipping %s: no archive file", FileName(progress.Hour)))
+			result.Missing++
+		} else if err != nil {
+			return result, fmt.Errorf("failed to backfill %s: %w", FileName(progress.Hour), err)
+		} else {
+			slog.Info(fmt.Sprintf("backfilled %s: %d events stored, %d filtered, %d invalid lines",
+				FileName(progress.Hour), hourResult.Events, hourResult.Filtered, hourResult.Invalid))
+		}
+		if hourResult.Events > 0 {
+			receiver.store.RecordWriteVersion()
+		}
+		result.Hours++
+
+		progress.Hour, progress.Lines = progress.Hour.Add(time.Hour), 0
+		err = receiver.saveProgress(&progress)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// backfillHour stores the events of the hour of the progress, from the first line not stored yet
+func (receiver *Job) backfillHour(ctx context.Context, progress *Progress) (Result, error) {
+	var result Result
+	file, err := receiver.source.Open(ctx, progress.Hour)
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+	archive, err := gzip.NewReader(file)
+	if err != nil {
+		return result, err
+	}
+	defer archive.Close()
+
+	// events have no size limit, some payloads take megabytes
+	lines := bufio.NewReader(archive)
+	line := int64(0)
+	batch := make([]model.Event, 0, receiver.options.BatchSize)
+	for {
+		data, readErr := lines.ReadBytes('\n')
+		if len(data) > 0 {
+			line++
+		}
+		if len(data) > 0 && line > progress.Lines {
+			receiver.read++
+			event, ok := decode(data)
+			if ok {
+				batch = append(batch, event)
+			} else {
+				result.Invalid++
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return result, readErr
+		}
+		if len(batch) >= receiver.options.BatchSize {
+			err = receiver.flush(ctx, progress, &result, batch, line)
+			if err != nil {
+				return result, err
+			}
+			batch = make([]model.Event, 0, receiver.options.BatchSize)
+		}
+	}
+	return result, receiver.flush(ctx, progress, &result, batch, line)
+}
+
+// flush stores a batch, records the lines it ends at and waits for the max rate
+func (receiver *Job) flush(ctx context.Context, progress *Progress, result *Result, batch []model.Event, line int64) error {
+	events := batch
+	if receiver.filter != nil {
+		events = receiver.filter(batch)
+	}
+	result.Filtered += int64(len(batch) - len(events))
+	if len(events) > 0 {
+		err := receiver.store.SaveHistoryWithContext(ctx, events)
+		if err != nil {
+			return err
+		}
+	}
+	result.Events += int64(len(events))
+	progress.Events += int64(len(events))
+	progress.Lines = line
+	err := receiver.saveProgress(progress)
+	if err != nil {
+		return err
+	}
+	return receiver.pace(ctx)
+}
+
+func (receiver *Job) saveProgress(progress *Progress) error {
+	progress.UpdatedAt = receiver.now().UTC()
+	err := writeProgress(receiver.options.ProgressFile, *progress)
+	if err != nil {
+		return fmt.Errorf("failed to write progress: %w", err)
+	}
+	return nil
+}
+
+// pace waits until the events read by the run are within BACKFILL_MAX_EVENTS_PER_SECOND
+func (receiver *Job) pace(ctx context.Context) error {
+	if receiver.options.MaxRate <= 0 {
+		return ctx.Err()
+	}
+	due := receiver.started.Add(time.Duration(float64(receiver.read) / float64(receiver.options.MaxRate) * float64(time.Second)))
+	wait := due.Sub(receiver.now())
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// decode maps a line of an archive file to an event, lines missing the event fields are invalid
+func decode(data []byte) (model.Event, bool) {
+	var event archiveEvent
+	err := json.Unmarshal(data, &event)
+	if err != nil || len(event.ID) == 0 || len(event.Type) == 0 || len(event.Repo.Name) == 0 || len(event.Actor.Login) == 0 || event.CreatedAt.IsZero() {
+		return model.Event{}, false
+	}
+	return model.Event{
+		ID:             event.ID,
+		Type:           event.Type,
+		CreatedAt:      event.CreatedAt.UTC(),
+		Public:         event.Public,
+		RepoFullName:   event.Repo.Name,
+		RepoUrl:        event.Repo.Url,
+		ActorLogin:     event.Actor.Login,
+		ActorId:        event.Actor.ID,
+		ActorUrl:       event.Actor.Url,
+		ActorAvatarUrl: event.Actor.AvatarUrl,
+	}, true
+}
+
+// NewJob returns the job backfilling the range of the options, filter applies the event filters to every batch
+func NewJob(options Options, source Source, store Store, filter func([]model.Event) []model.Event) (*Job, error) {
+	from, to, err := options.Range()
+	if err != nil {
+		return nil, err
+	}
+	return &Job{options: options, from: from, to: to, source: source, store: store, filter: filter, now: time.Now}, nil
+}
@@ -0,0 +1,261 @@
+package backfill
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"github-events-microservices/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+// fakeStore records the stored events, and fails the calls listed in failures
+type fakeStore struct {
+	events   []model.Event
+	versions int
+	calls    int
+	failures map[int]bool
+}
+
+func (receiver *fakeStore) SaveHistoryWithContext(_ context.Context, events []model.Event) error {
+	receiver.calls++
+	if receiver.failures[receiver.calls] {
+		return errors.New("mongo is down")
+	}
+	receiver.events = append(receiver.events, events...)
+	return nil
+}
+
+func (receiver *fakeStore) RecordWriteVersion() {
+	receiver.versions++
+}
+
+// archiveDir gzips the fixture files of testdata into a directory, like the GH Archive ones
+func archiveDir(t *testing.T) string {
+	dir := t.TempDir()
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fixture := range fixtures {
+		data, err := os.ReadFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Create(filepath.Join(dir, filepath.Base(fixture)+".gz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer := gzip.NewWriter(file)
+		_, err = writer.Write(data)
+		if err = errors.Join(err, writer.Close(), file.Close()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func newTestJob(t *testing.T, dir string, store Store, filter func([]model.Event) []model.Event) *Job {
+	options := Options{Source: dir, From: "2015-01-01T15", To: "2015-01-01T17", ProgressFile: filepath.Join(t.TempDir(), "progress.json"), BatchSize: 2}
+	job, err := NewJob(options, NewSource(dir, time.Second), store, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.now = func() time.Time { return now }
+	return job
+}
+
+func eventIds(events []model.Event) []string {
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestJob_Run(t *testing.T) {
+	store := &fakeStore{}
+	job := newTestJob(t, archiveDir(t), store, nil)
+
+	result, err := job.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Result{Hours: 3, Missing: 1, Events: 5, Invalid: 1}
+	if result != want {
+		t.Errorf("expected: %+v, got: %+v", want, result)
+	}
+	wantIds := []string{"2489651045", "2489651051", "2489651053", "2489677001", "2489677002"}
+	if !reflect.DeepEqual(eventIds(store.events), wantIds) || store.versions != 2 {
+		t.Errorf("expected events %v and 2 write versions, got: %v and %d", wantIds, eventIds(store.events), store.versions)
+	}
+	wantEvent := model.Event{
+		ID:             "2489651045",
+		Type:           "CreateEvent",
+		CreatedAt:      time.Date(2015, 1, 1, 15, 0, 0, 0, time.UTC),
+		Public:         true,
+		RepoFullName:   "petroav/6.828",
+		RepoUrl:        "https://api.github.com/repos/petroav/6.828",
+		ActorLogin:     "petroav",
+		ActorId:        665991,
+		ActorUrl:       "https://api.github.com/users/petroav",
+		ActorAvatarUrl: "https://avatars.githubusercontent.com/u/665991?",
+	}
+	if store.events[0] != wantEvent {
+		t.Errorf("expected: %+v, got: %+v", wantEvent, store.events[0])
+	}
+
+	progress, ok, err := readProgress(job.options.ProgressFile)
+	wantProgress := Progress{From: job.from, To: job.to, Hour: job.to.Add(time.Hour), Events: 5, UpdatedAt: now}
+	if err != nil || !ok || progress != wantProgress {
+		t.Errorf("expected progress: %+v, got: %+v %v", wantProgress, progress, err)
+	}
+
+	// a completed backfill has nothing left to store
+	result, err = job.Run(context.Background())
+	if err != nil || result != (Result{}) {
+		t.Errorf("expected nothing to backfill again, got: %+v %v", result, err)
+	}
+}
+
+func TestJob_Run_resumes(t *testing.T) {
+	dir := archiveDir(t)
+	failing := &fakeStore{failures: map[int]bool{2: true}}
+	job := newTestJob(t, dir, failing, nil)
+
+	_, err := job.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected the failed batch to stop the backfill")
+	}
+	progress, _, _ := readProgress(job.options.ProgressFile)
+	if !progress.Hour.Equal(job.from) || progress.Lines != 2 {
+		t.Fatalf("expected the progress of the first batch, got: %+v", progress)
+	}
+
+	store := &fakeStore{}
+	job.store = store
+	result, err := job.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIds := []string{"2489651053", "2489677001", "2489677002"}
+	if !reflect.DeepEqual(eventIds(store.events), wantIds) || result.Events != 3 {
+		t.Errorf("expected to resume after the stored batch with %v, got: %v", wantIds, eventIds(store.events))
+	}
+
+	// a backfill of another range starts over
+	job.from = job.from.Add(time.Hour)
+	store = &fakeStore{}
+	job.store = store
+	result, err = job.Run(context.Background())
+	if err != nil || result.Events != 2 {
+		t.Errorf("expected the events of the new range, got: %+v %v", result, err)
+	}
+}
+
+func TestJob_Run_filters(t *testing.T) {
+	store := &fakeStore{}
+	job := newTestJob(t, archiveDir(t), store, func(events []model.Event) []model.Event {
+		kept := make([]model.Event, 0, len(events))
+		for _, event := range events {
+			if event.Type != "WatchEvent" {
+				kept = append(kept, event)
+			}
+		}
+		return kept
+	})
+
+	result, err := job.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Events != 3 || result.Filtered != 2 {
+		t.Errorf("expected 3 stored and 2 filtered events, got: %+v", result)
+	}
+}
+
+func TestJob_Run_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store := &fakeStore{}
+	_, err := newTestJob(t, archiveDir(t), store, nil).Run(ctx)
+	if !errors.Is(err, context.Canceled) || len(store.events) != 0 {
+		t.Errorf("expected a cancelled backfill to stop, got: %v and %d events", err, len(store.events))
+	}
+}
+
+func TestOptions_Range(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  bool
+	}{
+		{name: "hours", from: "2015-01-01T15", to: "2015-01-02T03", wantFrom: time.Date(2015, 1, 1, 15, 0, 0, 0, time.UTC), wantTo: time.Date(2015, 1, 2, 3, 0, 0, 0, time.UTC)},
+		{name: "days", from: "2015-01-01", to: "2015-01-01", wantFrom: time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2015, 1, 1, 23, 0, 0, 0, time.UTC)},
+		{name: "missing", from: "2015-01-01", wantErr: true},
+		{name: "invalid", from: "01/01/2015", to: "2015-01-01", wantErr: true},
+		{name: "reversed", from: "2015-01-02", to: "2015-01-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := Options{From: tt.from, To: tt.to}.Range()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Range() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("expected: %s - %s, got: %s - %s", tt.wantFrom, tt.wantTo, from, to)
+			}
+		})
+	}
+}
+
+func TestUrlSource_Open(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir(archiveDir(t))))
+	defer server.Close()
+	source := NewSource(server.URL+"/", time.Second)
+
+	file, err := source.Open(context.Background(), time.Date(2015, 1, 1, 16, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+	_, err = source.Open(context.Background(), time.Date(2015, 1, 1, 17, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrMissingHour) {
+		t.Errorf("expected a missing hour, got: %v", err)
+	}
+	if name := FileName(time.Date(2015, 1, 2, 3, 0, 0, 0, time.UTC)); name != "2015-01-02-3.json.gz" {
+		t.Errorf("expected hours not to be zero padded, got: %s", name)
+	}
+}
+
+func TestUrlSource_Open_slowBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+		writer.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		_, _ = writer.Write([]byte("events"))
+	}))
+	defer server.Close()
+
+	file, err := NewSource(server.URL, 20*time.Millisecond).Open(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	// the body is read as the batches are stored, so the timeout only applies until the response headers
+	data, err := io.ReadAll(file)
+	if err != nil || string(data) != "events" {
+		t.Errorf("expected the body read after the timeout, got: %q %v", data, err)
+	}
+}
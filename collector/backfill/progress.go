@@ -0,0 +1,62 @@
+package backfill
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Progress is what a backfill of a range already stored: the hours before Hour, and the first Lines lines of Hour
+type Progress struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Hour      time.Time `json:"hour"`
+	Lines     int64     `json:"lines"`
+	Events    int64     `json:"events"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// resumes reports whether the progress is the one of a backfill from the same hour, which may have been extended since
+func (receiver Progress) resumes(from time.Time) bool {
+	return receiver.From.Equal(from) && !receiver.Hour.Before(from)
+}
+
+// readProgress reads the progress file, a missing file is no progress
+func readProgress(path string) (Progress, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Progress{}, false, nil
+	}
+	if err != nil {
+		return Progress{}, false, err
+	}
+	var progress Progress
+	err = json.Unmarshal(data, &progress)
+	if err != nil {
+		return Progress{}, false, err
+	}
+	return progress, true, nil
+}
+
+// writeProgress replaces the progress file through a temporary file, so a crash never leaves a partial one
+func writeProgress(path string, progress Progress) error {
+	data, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	err = errors.Join(err, temp.Close())
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(temp.Name())
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrMissingHour is returned for the hours without an archive file, GH Archive has a few of them
+var ErrMissingHour = errors.New("missing hour")
+
+// Source opens the GH Archive file of an hour, a gzip compressed file of the events of the hour, one json per line
+type Source interface {
+	Open(ctx context.Context, hour time.Time) (io.ReadCloser, error)
+}
+
+// FileName returns the name of the archive file of an hour, e.g. 2015-01-01-15.json.gz. Hours aren't zero padded.
+func FileName(hour time.Time) string {
+	return fmt.Sprintf("%s-%d.json.gz", hour.Format("2006-01-02"), hour.Hour())
+}
+
+// NewSource returns the source of the files of a local directory, or of an http(s) url like https://data.gharchive.org.
+// timeout bounds the connection and the wait for the response headers of a download, not the read of its body.
+func NewSource(location string, timeout time.Duration) Source {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		// the body is read along with the stored batches, which may take longer than any download timeout
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = (&net.Dialer{Timeout: timeout}).DialContext
+		transport.TLSHandshakeTimeout = timeout
+		transport.ResponseHeaderTimeout = timeout
+		return urlSource{baseUrl: strings.TrimSuffix(location, "/"), client: &http.Client{Transport: transport}}
+	}
+	return dirSource{dir: location}
+}
+
+type dirSource struct {
+	dir string
+}
+
+func (receiver dirSource) Open(_ context.Context, hour time.Time) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(receiver.dir, FileName(hour)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMissingHour
+	}
+	return file, err
+}
+
+type urlSource struct {
+	baseUrl string
+	client  *http.Client
+}
+
+func (receiver urlSource) Open(ctx context.Context, hour time.Time) (io.ReadCloser, error) {
+	url := receiver.baseUrl + "/" + FileName(hour)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := receiver.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case response.StatusCode == http.StatusOK:
+		return response.Body, nil
+	case response.StatusCode == http.StatusNotFound:
+		_ = response.Body.Close()
+		return nil, ErrMissingHour
+	default:
+		_ = response.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: %s", url, response.Status)
+	}
+}
@@ -0,0 +1,224 @@
+package bots
+
+import (
+	"errors"
+	"github-events-microservices/model"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ReasonEventRate    = "event_rate"
+	ReasonTypeEntropy  = "type_entropy"
+	ReasonRepoSpread   = "repo_spread"
+	ReasonLoginPattern = "login_pattern"
+
+	// the weights of the features in the score, out of 100
+	rateWeight    = 35
+	entropyWeight = 20
+	spreadWeight  = 25
+	loginWeight   = 20
+
+	// appSuffix ends the logins of the GitHub apps, which are bots for sure
+	appSuffix = "[bot]"
+	// maxEntropy is the type entropy, in bits, of an actor using 4 event types evenly, above which it isn't suspicious
+	maxEntropy = 2
+	// reasonFeature is the value from which a feature is a reason of the score
+	reasonFeature = 0.5
+	// rescoreDelta is the change of score reported even when the suspicion doesn't change
+	rescoreDelta = 10
+)
+
+var (
+	botLogin       = regexp.MustCompile(`(^|[-_])bots?($|[-_])|bot$`)
+	generatedLogin = regexp.MustCompile(`[0-9]{4,}$`)
+)
+
+type Options struct {
+	Enabled bool `config:"BOTS_ENABLED" default:"true"`
+	// Window is the activity an actor is scored on, in event time
+	Window time.Duration `config:"BOTS_WINDOW_MINUTES" default:"60" unit:"minutes" min:"1"`
+	// MinEvents is the activity from which an actor is scored, only GitHub apps are scored before
+	MinEvents int `config:"BOTS_MIN_EVENTS" default:"20" min:"2"`
+	// MaxRate is the hourly rate of events of the most suspicious actors
+	MaxRate   int `config:"BOTS_MAX_EVENTS_PER_HOUR" default:"300" min:"1"`
+	Threshold int `config:"BOTS_SCORE_THRESHOLD" default:"60" min:"1"`
+}
+
+func (receiver Options) Validate() error {
+	if receiver.Threshold > 100 {
+		return errors.New("BOTS_SCORE_THRESHOLD must not exceed 100")
+	}
+	return nil
+}
+
+// Detector scores the actors of the stored events by their event rate, the entropy of their event types, the spread of
+// their events over repos and their login. Actors scoring at least the threshold are suspected bots.
+// Actors are forgotten once they have no event within the window, their last score stays stored.
+type Detector struct {
+	mu      sync.Mutex
+	options Options
+	actors  map[int64]*activity
+	latest  time.Time
+}
+
+// activity holds the events of an actor within the window, oldest first, and its last reported score
+type activity struct {
+	login    string
+	events   []observed
+	seen     map[string]bool
+	reported *model.BotScore
+}
+
+type observed struct {
+	id        string
+	eventType string
+	repo      string
+	at        time.Time
+}
+
+// Observe adds the events to the activity of their actors, and returns the scores of the actors scored for the first
+// time, whose suspicion changed or whose score changed by 10 or more. Replayed events are only counted once.
+func (receiver *Detector) Observe(events []model.Event) []model.BotScore {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	changed := make(map[int64]bool)
+	for _, event := range events {
+		actor, ok := receiver.actors[event.ActorId]
+		if !ok {
+			actor = &activity{seen: make(map[string]bool)}
+			receiver.actors[event.ActorId] = actor
+		}
+		if actor.seen[event.ID] {
+			continue
+		}
+		actor.seen[event.ID] = true
+		actor.login = event.ActorLogin
+		actor.events = append(actor.events, observed{id: event.ID, eventType: event.Type, repo: strings.ToLower(event.RepoFullName), at: event.CreatedAt})
+		if event.CreatedAt.After(receiver.latest) {
+			receiver.latest = event.CreatedAt
+		}
+		changed[event.ActorId] = true
+	}
+
+	scores := make([]model.BotScore, 0)
+	for id, actor := range receiver.actors {
+		if changed[id] {
+			// the feeds list the newest events first
+			sort.SliceStable(actor.events, func(i, j int) bool { return actor.events[i].at.Before(actor.events[j].at) })
+		}
+		actor.expire(receiver.latest.Add(-receiver.options.Window))
+		if len(actor.events) == 0 {
+			delete(receiver.actors, id)
+			continue
+		}
+		if !changed[id] {
+			continue
+		}
+		score, ok := receiver.score(id, actor)
+		if !ok || !actor.report(score) {
+			continue
+		}
+		scores = append(scores, score)
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].UserId < scores[j].UserId })
+	return scores
+}
+
+// score scores an actor with enough events, or a GitHub app
+func (receiver *Detector) score(id int64, actor *activity) (model.BotScore, bool) {
+	score := model.BotScore{UserId: id, Login: actor.login, Reasons: make([]string, 0), ScoredAt: actor.events[len(actor.events)-1].at}
+	login := strings.ToLower(actor.login)
+	if strings.HasSuffix(login, appSuffix) {
+		score.Score, score.Suspected, score.Reasons = 100, true, []string{ReasonLoginPattern}
+		return score, true
+	}
+	if len(actor.events) < receiver.options.MinEvents {
+		return score, false
+	}
+
+	types := make(map[string]int)
+	repos := make(map[string]bool)
+	for _, event := range actor.events {
+		types[event.eventType]++
+		repos[event.repo] = true
+	}
+	count := float64(len(actor.events))
+	perHour := count / receiver.options.Window.Hours()
+	features := []struct {
+		reason string
+		weight int
+		value  float64
+	}{
+		{reason: ReasonEventRate, weight: rateWeight, value: math.Min(1, perHour/float64(receiver.options.MaxRate))},
+		{reason: ReasonTypeEntropy, weight: entropyWeight, value: 1 - math.Min(1, entropy(types, count)/maxEntropy)},
+		{reason: ReasonRepoSpread, weight: spreadWeight, value: float64(len(repos)) / count},
+		{reason: ReasonLoginPattern, weight: loginWeight, value: loginFeature(login)},
+	}
+	total := 0.0
+	for _, feature := range features {
+		total += float64(feature.weight) * feature.value
+		if feature.value >= reasonFeature {
+			score.Reasons = append(score.Reasons, feature.reason)
+		}
+	}
+	score.Score = int(math.Round(total))
+	score.Suspected = score.Score >= receiver.options.Threshold
+	return score, true
+}
+
+// expire drops the events older than the cutoff
+func (receiver *activity) expire(cutoff time.Time) {
+	expired := 0
+	for expired < len(receiver.events) && receiver.events[expired].at.Before(cutoff) {
+		delete(receiver.seen, receiver.events[expired].id)
+		expired++
+	}
+	receiver.events = receiver.events[expired:]
+}
+
+// report records the score when it is worth reporting
+func (receiver *activity) report(score model.BotScore) bool {
+	last := receiver.reported
+	if last != nil && last.Suspected == score.Suspected && abs(last.Score-score.Score) < rescoreDelta {
+		return false
+	}
+	receiver.reported = &score
+	return true
+}
+
+// entropy is the Shannon entropy of the event types, in bits
+func entropy(types map[string]int, count float64) float64 {
+	bits := 0.0
+	for _, typeCount := range types {
+		p := float64(typeCount) / count
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// loginFeature is 1 for logins naming a bot, and 0.5 for the logins ending with a number, as generated ones do
+func loginFeature(login string) float64 {
+	if botLogin.MatchString(login) {
+		return 1
+	}
+	if generatedLogin.MatchString(login) {
+		return 0.5
+	}
+	return 0
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func NewDetector(options Options) *Detector {
+	return &Detector{options: options, actors: make(map[int64]*activity)}
+}
@@ -0,0 +1,113 @@
+package bots
+
+import (
+	"fmt"
+	"github-events-microservices/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+var options = Options{Enabled: true, Window: time.Hour, MinEvents: 10, MaxRate: 100, Threshold: 60}
+
+// activityOf returns count events of an actor, one per interval, the newest first as the feeds list them
+func activityOf(actorId int64, login string, count int, interval time.Duration, eventType func(int) string, repo func(int) string) []model.Event {
+	events := make([]model.Event, 0, count)
+	for i := count - 1; i >= 0; i-- {
+		events = append(events, model.Event{
+			ID:           fmt.Sprintf("%d-%d", actorId, i),
+			Type:         eventType(i),
+			CreatedAt:    start.Add(time.Duration(i) * interval),
+			RepoFullName: repo(i),
+			ActorLogin:   login,
+			ActorId:      actorId,
+		})
+	}
+	return events
+}
+
+func same(value string) func(int) string {
+	return func(int) string { return value }
+}
+
+func numbered(prefix string) func(int) string {
+	return func(i int) string { return fmt.Sprintf("%s%d", prefix, i) }
+}
+
+func cycled(values ...string) func(int) string {
+	return func(i int) string { return values[i%len(values)] }
+}
+
+func TestDetector_Observe(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []model.Event
+		want   []model.BotScore
+	}{
+		{
+			name:   "spammer starring many repos",
+			events: activityOf(1, "spammer", 100, 30*time.Second, same("WatchEvent"), numbered("acme/repo")),
+			want:   []model.BotScore{{UserId: 1, Login: "spammer", Score: 80, Suspected: true, Reasons: []string{ReasonEventRate, ReasonTypeEntropy, ReasonRepoSpread}, ScoredAt: start.Add(99 * 30 * time.Second)}},
+		},
+		{
+			name:   "busy human",
+			events: activityOf(2, "alice", 30, time.Minute, cycled("PushEvent", "PullRequestEvent", "IssueCommentEvent", "PullRequestReviewEvent"), cycled("acme/api", "acme/web")),
+			want:   []model.BotScore{{UserId: 2, Login: "alice", Score: 12, Reasons: []string{}, ScoredAt: start.Add(29 * time.Minute)}},
+		},
+		{
+			name:   "named bot",
+			events: activityOf(3, "release-bot", 20, time.Minute, same("CreateEvent"), same("acme/api")),
+			want:   []model.BotScore{{UserId: 3, Login: "release-bot", Score: 48, Reasons: []string{ReasonTypeEntropy, ReasonLoginPattern}, ScoredAt: start.Add(19 * time.Minute)}},
+		},
+		{
+			name:   "GitHub app",
+			events: activityOf(4, "dependabot[bot]", 1, time.Minute, same("PullRequestEvent"), same("acme/api")),
+			want:   []model.BotScore{{UserId: 4, Login: "dependabot[bot]", Score: 100, Suspected: true, Reasons: []string{ReasonLoginPattern}, ScoredAt: start}},
+		},
+		{
+			name:   "too few events",
+			events: activityOf(5, "bob", 9, time.Second, same("WatchEvent"), numbered("acme/repo")),
+			want:   []model.BotScore{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewDetector(options).Observe(tt.events)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected: %+v, got: %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDetector_Observe_reports(t *testing.T) {
+	detector := NewDetector(options)
+	events := activityOf(1, "spammer", 100, 30*time.Second, same("WatchEvent"), numbered("acme/repo"))
+	if got := detector.Observe(events[90:]); len(got) != 1 || got[0].Suspected || got[0].Score != 49 {
+		t.Fatalf("expected the first 10 events to be scored unsuspected, got: %+v", got)
+	}
+	if got := detector.Observe(events); len(got) != 1 || !got[0].Suspected || got[0].Score != 80 {
+		t.Fatalf("expected a suspected bot once its rate grows, replayed events counted once, got: %+v", got)
+	}
+	if got := detector.Observe(events[:1]); len(got) != 0 {
+		t.Errorf("expected an unchanged score not to be reported again, got: %+v", got)
+	}
+
+	// two hours later, the spammer only pushes to a repo now and then
+	later := activityOf(1, "spammer", 10, 5*time.Minute, same("PushEvent"), same("acme/api"))
+	for i := range later {
+		later[i].ID = "later-" + later[i].ID
+		later[i].CreatedAt = later[i].CreatedAt.Add(2 * time.Hour)
+	}
+	if got := detector.Observe(later); len(got) != 1 || got[0].Suspected || got[0].Score != 26 {
+		t.Errorf("expected the expired events to be forgotten and the suspicion cleared, got: %+v", got)
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	if err := (Options{Threshold: 101}).Validate(); err == nil {
+		t.Error("expected a threshold over 100 to be rejected")
+	}
+}
@@ -0,0 +1,171 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github-events-microservices/collector/config"
+	"github-events-microservices/collector/feeds"
+	"github-events-microservices/collector/metrics"
+	"github-events-microservices/model"
+	"github.com/google/go-github/v57/github"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// FeedScheduler polls the configured events feeds. Each feed keeps its own ETag and cursor, the id of the newest
+// event seen, so unchanged feeds cost no rate limit and only new events are returned.
+type FeedScheduler struct {
+	restApiClient GitHubRestClient
+	// states are only used by the fetch loop
+	states map[feeds.Feed]*feedState
+	now    func() time.Time
+}
+
+type feedState struct {
+	feed     feeds.Feed
+	etag     string
+	cursor   int64
+	lastPoll time.Time
+	nextPoll time.Time
+}
+
+// FetchWithContext polls the due feeds, least recently polled first, up to FEED_MAX_REQUESTS requests,
+// so the feeds left out are polled first on the next fetch. Events seen in several feeds are returned once.
+func (receiver FeedScheduler) FetchWithContext(ctx context.Context) ([]model.Event, error) {
+	ctx, span := tracer.Start(ctx, "github.FetchFeeds")
+	defer span.End()
+
+	due, err := receiver.dueFeeds()
+	if err != nil {
+		return nil, err
+	}
+	requests := config.Current().FeedMaxRequests
+	seen := make(map[string]bool)
+	events := make([]model.Event, 0)
+	var errs []error
+	for _, state := range due {
+		if requests <= 0 || ctx.Err() != nil {
+			break
+		}
+		feedEvents, used, err := receiver.poll(ctx, state, requests)
+		requests -= used
+		if err != nil {
+			metrics.FetchErrors.Inc()
+			metrics.FeedPolls.WithLabelValues(state.feed.String(), "error").Inc()
+			errs = append(errs, fmt.Errorf("failed to poll feed '%s': %w", state.feed, err))
+			continue
+		}
+		for _, event := range feedEvents {
+			if !seen[event.ID] {
+				seen[event.ID] = true
+				events = append(events, event)
+			}
+		}
+	}
+	metrics.EventsFetched.Add(float64(len(events)))
+	span.SetAttributes(attribute.Int("feeds.polled", config.Current().FeedMaxRequests-requests), attribute.Int("events.count", len(events)))
+	err = errors.Join(errs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return events, err
+}
+
+// dueFeeds syncs the feed states with the configured feeds and returns the due ones, least recently polled first
+func (receiver FeedScheduler) dueFeeds() ([]*feedState, error) {
+	configured, err := feeds.Parse(config.Current().Feeds)
+	if err != nil {
+		return nil, err
+	}
+	isConfigured := make(map[feeds.Feed]bool)
+	for _, feed := range configured {
+		isConfigured[feed] = true
+		_, ok := receiver.states[feed]
+		if !ok {
+			receiver.states[feed] = &feedState{feed: feed}
+		}
+	}
+	due := make([]*feedState, 0, len(configured))
+	now := receiver.now()
+	for feed, state := range receiver.states {
+		if !isConfigured[feed] {
+			delete(receiver.states, feed)
+		} else if !now.Before(state.nextPoll) {
+			due = append(due, state)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].lastPoll.Equal(due[j].lastPoll) {
+			return due[i].feed.String() < due[j].feed.String()
+		}
+		return due[i].lastPoll.Before(due[j].lastPoll)
+	})
+	return due, nil
+}
+
+// poll returns the events of the feed newer than its cursor, along with the number of requests made.
+// Pages are followed, up to FEED_MAX_PAGES, while all their events are new.
+func (receiver FeedScheduler) poll(ctx context.Context, state *feedState, requests int) ([]model.Event, int, error) {
+	maxPages := min(config.Current().FeedMaxPages, requests)
+	events := make([]model.Event, 0)
+	newest := state.cursor
+	etag := state.etag
+	used := 0
+	for page := 1; page <= maxPages; page++ {
+		ifNoneMatch := ""
+		if page == 1 {
+			ifNoneMatch = state.etag
+		}
+		result, err := receiver.restApiClient.ListFeedEvents(ctx, state.feed.Path(), ifNoneMatch, &github.ListOptions{Page: page})
+		used++
+		if err != nil {
+			return nil, used, err
+		}
+		if page == 1 {
+			state.lastPoll = receiver.now()
+			state.nextPoll = state.lastPoll.Add(result.PollInterval)
+			if result.NotModified {
+				metrics.FeedPolls.WithLabelValues(state.feed.String(), "not_modified").Inc()
+				return events, used, nil
+			}
+			etag = result.ETag
+			metrics.FeedPolls.WithLabelValues(state.feed.String(), "ok").Inc()
+		}
+
+		allNew := true
+		for _, githubEvent := range result.Events {
+			id, err := strconv.ParseInt(githubEvent.GetID(), 10, 64)
+			if err == nil && id <= state.cursor {
+				allNew = false
+				continue
+			}
+			newest = max(newest, id)
+			events = append(events, toEvent(githubEvent))
+		}
+		// a first poll doesn't backfill the feed
+		if !allNew || state.cursor == 0 || result.NextPage == 0 {
+			break
+		}
+	}
+	if newest > state.cursor {
+		slog.Debug(fmt.Sprintf("feed %s: %d new events", state.feed, len(events)))
+	}
+	// the state only changes once all the pages were read, so a failed poll is repeated as a whole
+	state.etag = etag
+	state.cursor = newest
+	return events, used, nil
+}
+
+func NewFeedScheduler(gitHubClient *GitHubPublicEventsClient) *FeedScheduler {
+	return &FeedScheduler{
+		restApiClient: gitHubClient.restApiClient,
+		states:        make(map[feeds.Feed]*feedState),
+		now:           time.Now,
+	}
+}
@@ -0,0 +1,122 @@
+package clients
+
+import (
+	"context"
+	mockclients "github-events-microservices/collector/clients/mocks"
+	"github-events-microservices/collector/config"
+	"github-events-microservices/collector/feeds"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v57/github"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFeedScheduler_FetchWithContext(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "test")
+	t.Setenv("FEEDS", "public,org:golang")
+	t.Setenv("FEED_MAX_REQUESTS", "10")
+	_, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	restApiClient := mockclients.NewMockGitHubRestClient(mockCtrl)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	receiver := FeedScheduler{
+		restApiClient: restApiClient,
+		states:        make(map[feeds.Feed]*feedState),
+		now:           func() time.Time { return now },
+	}
+
+	// first fetch: feeds are polled in order, the event seen in both feeds is returned once
+	gomock.InOrder(
+		restApiClient.EXPECT().ListFeedEvents(gomock.Any(), "orgs/golang/events", "", &github.ListOptions{Page: 1}).
+			Return(feeds.Page{Events: githubEvents("5", "3"), ETag: "org-1", NextPage: 2}, nil),
+		restApiClient.EXPECT().ListFeedEvents(gomock.Any(), "events", "", &github.ListOptions{Page: 1}).
+			Return(feeds.Page{Events: githubEvents("3", "2"), ETag: "public-1", PollInterval: time.Minute}, nil),
+	)
+	assertFetched(t, receiver, "5", "3", "2")
+
+	// the public feed is not due yet, the org feed is unchanged
+	now = now.Add(time.Second)
+	restApiClient.EXPECT().ListFeedEvents(gomock.Any(), "orgs/golang/events", "org-1", &github.ListOptions{Page: 1}).
+		Return(feeds.Page{NotModified: true}, nil)
+	assertFetched(t, receiver)
+
+	// pages are followed while all their events are newer than the cursor
+	now = now.Add(time.Minute)
+	gomock.InOrder(
+		restApiClient.EXPECT().ListFeedEvents(gomock.Any(), "events", "public-1", &github.ListOptions{Page: 1}).
+			Return(feeds.Page{Events: githubEvents("8", "7"), ETag: "public-2", NextPage: 2}, nil),
+		restApiClient.EXPECT().ListFeedEvents(gomock.Any(), "events", "", &github.ListOptions{Page: 2}).
+			Return(feeds.Page{Events: githubEvents("6", "3"), NextPage: 3}, nil),
+		restApiClient.EXPECT().ListFeedEvents(gomock.Any(), "orgs/golang/events", "org-1", &github.ListOptions{Page: 1}).
+			Return(feeds.Page{NotModified: true}, nil),
+	)
+	assertFetched(t, receiver, "8", "7", "6")
+}
+
+func TestFeedScheduler_FetchWithContext_Fair(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "test")
+	t.Setenv("FEEDS", "org:a,org:b,org:c")
+	t.Setenv("FEED_MAX_REQUESTS", "2")
+	_, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	restApiClient := mockclients.NewMockGitHubRestClient(mockCtrl)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	receiver := FeedScheduler{
+		restApiClient: restApiClient,
+		states:        make(map[feeds.Feed]*feedState),
+		now:           func() time.Time { return now },
+	}
+
+	var polled []string
+	restApiClient.EXPECT().ListFeedEvents(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, path string, etag string, options *github.ListOptions) (feeds.Page, error) {
+			polled = append(polled, path)
+			now = now.Add(time.Second)
+			return feeds.Page{NotModified: true}, nil
+		}).Times(4)
+	for i := 0; i < 2; i++ {
+		_, err := receiver.FetchWithContext(context.Background())
+		if err != nil {
+			t.Fatalf("FetchWithContext() error = %v", err)
+		}
+	}
+	want := []string{"orgs/a/events", "orgs/b/events", "orgs/c/events", "orgs/a/events"}
+	if !reflect.DeepEqual(polled, want) {
+		t.Errorf("polled = %v, want %v", polled, want)
+	}
+}
+
+func assertFetched(t *testing.T, receiver FeedScheduler, want ...string) {
+	t.Helper()
+	events, err := receiver.FetchWithContext(context.Background())
+	if err != nil {
+		t.Fatalf("FetchWithContext() error = %v", err)
+	}
+	got := make([]string, 0)
+	for _, event := range events {
+		got = append(got, event.ID)
+	}
+	if want == nil {
+		want = []string{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchWithContext() got = %v, want %v", got, want)
+	}
+}
+
+func githubEvents(ids ...string) []*github.Event {
+	events := make([]*github.Event, len(ids))
+	for i := range ids {
+		events[i] = &github.Event{ID: &ids[i]}
+	}
+	return events
+}
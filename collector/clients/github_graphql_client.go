@@ -2,26 +2,31 @@ package clients
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github-events-microservices/collector/health"
+	"github-events-microservices/collector/metrics"
 	"github-events-microservices/collector/net"
 	"github-events-microservices/model"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
 	repoQueryPrefix = "repoQuery"
+	userQueryPrefix = "userQuery"
+	rateLimitQuery  = "rateLimit{cost,remaining,resetAt}"
 )
 
 type GithubGraphQLClient struct {
 	httpClient       net.HttpClient
 	githubGraphQLUrl string
-	token            string
 }
 
 type RepoIdentifier struct {
@@ -50,10 +55,46 @@ type RepoOwner struct {
 	Login string `json:"login"`
 }
 
+// RateLimit is the GraphQL rate limit budget after a query
+type RateLimit struct {
+	Cost      int       `json:"cost"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+type RepoDetails struct {
+	ID    string `json:"id"`
+	Url   string `json:"url"`
+	Stars int    `json:"stargazerCount"`
+}
+
+type UserDetails struct {
+	Login     string         `json:"login"`
+	Name      string         `json:"name"`
+	Company   string         `json:"company"`
+	Location  string         `json:"location"`
+	Followers FollowersCount `json:"followers"`
+}
+
+type FollowersCount struct {
+	TotalCount int `json:"totalCount"`
+}
+
+type detailsQueryResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
 func (receiver GithubGraphQLClient) FetchRepos(events []model.Event) ([]model.Repo, error) {
+	return receiver.FetchReposWithContext(context.Background(), events)
+}
+
+func (receiver GithubGraphQLClient) FetchReposWithContext(ctx context.Context, events []model.Event) ([]model.Repo, error) {
 	repoLastUpdatedMap := buildRepoLastUpdatedMap(events)
 	query := buildQuery(events)
-	body, err := receiver.sendRequest(query)
+	body, err := receiver.sendRequest(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -66,33 +107,40 @@ func (receiver GithubGraphQLClient) FetchRepos(events []model.Event) ([]model.Re
 	return repos, nil
 }
 
-func (receiver GithubGraphQLClient) sendRequest(query GraphQLQuery) ([]byte, error) {
+func (receiver GithubGraphQLClient) sendRequest(ctx context.Context, query GraphQLQuery) ([]byte, error) {
 	marshal, err := json.Marshal(query)
 	if err != nil {
 		return nil, err
 	}
 
-	request, err := http.NewRequest("POST", receiver.githubGraphQLUrl, bytes.NewReader(marshal))
+	request, err := http.NewRequestWithContext(ctx, "POST", receiver.githubGraphQLUrl, bytes.NewReader(marshal))
 	if err != nil {
 		slog.Error(fmt.Sprintf("Failed to create fetch repos request: %s", err.Error()))
 		return nil, err
 	}
 
-	request.Header.Add("Authorization", receiver.token)
-	if err != nil {
-		slog.Error(fmt.Sprintf("Failed to fetch repos: %s", err.Error()))
-	}
-
 	response, err := receiver.httpClient.Do(request)
 	if err != nil {
 		slog.Error(fmt.Sprintf("Failed to fetch repos: %s", err.Error()))
+		return nil, err
 	}
 	defer response.Body.Close()
+	recordRateLimit(response)
+	if response.StatusCode == http.StatusUnauthorized {
+		health.CollectorState.RecordTokenValidity(false)
+	}
 	body, _ := io.ReadAll(response.Body)
 	slog.Debug(fmt.Sprintf("response Body: %s", string(body)))
 	return body, nil
 }
 
+func recordRateLimit(response *http.Response) {
+	remaining, err := strconv.Atoi(response.Header.Get("X-RateLimit-Remaining"))
+	if err == nil {
+		metrics.RateLimitRemaining.WithLabelValues("graphql").Set(float64(remaining))
+	}
+}
+
 func buildRepoLastUpdatedMap(events []model.Event) map[RepoIdentifier]time.Time {
 	repoToLastUpdated := make(map[RepoIdentifier]time.Time)
 	for _, event := range events {
@@ -111,7 +159,7 @@ func buildRepoLastUpdatedMap(events []model.Event) map[RepoIdentifier]time.Time
 
 func getRepoIdentifier(repoName string) (*RepoIdentifier, error) {
 	repoOwnerAndName := strings.Split(repoName, "/")
-	if len(repoOwnerAndName) != 2 || len(repoOwnerAndName[0]) == 0 || len(repoOwnerAndName[0]) == 0 {
+	if len(repoOwnerAndName) != 2 || len(repoOwnerAndName[0]) == 0 || len(repoOwnerAndName[1]) == 0 {
 		return nil, errors.New(fmt.Sprintf("Failed to parse repo identifier from: %s", repoName))
 	}
 
@@ -182,3 +230,92 @@ func logErrors(responseMap map[string]interface{}) {
 		slog.Error(fmt.Sprintf("Failed to fetch the following repos due to the following errors: %s", strings.Join(errorMessages, ", ")))
 	}
 }
+
+// FetchRepoDetailsWithContext queries the repos by node id. Repos that no longer exist are omitted.
+func (receiver GithubGraphQLClient) FetchRepoDetailsWithContext(ctx context.Context, ids []string) ([]RepoDetails, RateLimit, error) {
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = strconv.Quote(id)
+	}
+	query := GraphQLQuery{Query: fmt.Sprintf("query {%s,nodes(ids: [%s]) {... on Repository {id,url,stargazerCount}}}", rateLimitQuery, strings.Join(quoted, ","))}
+	response, rateLimit, err := receiver.sendDetailsQuery(ctx, query)
+	if err != nil {
+		return nil, rateLimit, err
+	}
+
+	var nodes []*RepoDetails
+	err = json.Unmarshal(response.Data["nodes"], &nodes)
+	if err != nil {
+		return nil, rateLimit, err
+	}
+	repos := make([]RepoDetails, 0, len(nodes))
+	for _, node := range nodes {
+		if node != nil && len(node.ID) > 0 {
+			repos = append(repos, *node)
+		}
+	}
+	return repos, rateLimit, nil
+}
+
+// FetchUserDetailsWithContext queries the users by login. Users that no longer exist are omitted.
+func (receiver GithubGraphQLClient) FetchUserDetailsWithContext(ctx context.Context, logins []string) ([]UserDetails, RateLimit, error) {
+	var sb strings.Builder
+	sb.WriteString("query {" + rateLimitQuery)
+	for i, login := range logins {
+		sb.WriteString(fmt.Sprintf(",%s%d:user(login: %s) {login,name,company,location,followers{totalCount}}", userQueryPrefix, i, strconv.Quote(login)))
+	}
+	sb.WriteString("}")
+	response, rateLimit, err := receiver.sendDetailsQuery(ctx, GraphQLQuery{Query: sb.String()})
+	if err != nil {
+		return nil, rateLimit, err
+	}
+
+	users := make([]UserDetails, 0, len(logins))
+	for key, data := range response.Data {
+		if !strings.HasPrefix(key, userQueryPrefix) {
+			continue
+		}
+		var user *UserDetails
+		err = json.Unmarshal(data, &user)
+		if err != nil {
+			return nil, rateLimit, err
+		}
+		if user != nil {
+			users = append(users, *user)
+		}
+	}
+	return users, rateLimit, nil
+}
+
+func (receiver GithubGraphQLClient) sendDetailsQuery(ctx context.Context, query GraphQLQuery) (detailsQueryResponse, RateLimit, error) {
+	var response detailsQueryResponse
+	var rateLimit RateLimit
+	body, err := receiver.sendRequest(ctx, query)
+	if err != nil {
+		return response, rateLimit, err
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return response, rateLimit, err
+	}
+	if len(response.Errors) > 0 {
+		// not found items are reported as errors along with the data of the others
+		messages := make([]string, len(response.Errors))
+		for i, queryError := range response.Errors {
+			messages[i] = queryError.Message
+		}
+		slog.DebugContext(ctx, fmt.Sprintf("GraphQL query errors: %s", strings.Join(messages, ", ")))
+	}
+	if response.Data == nil {
+		return response, rateLimit, errors.New("GraphQL response has no data")
+	}
+	rateLimitData, ok := response.Data["rateLimit"]
+	if ok {
+		err = json.Unmarshal(rateLimitData, &rateLimit)
+		if err != nil {
+			return response, rateLimit, err
+		}
+		metrics.RateLimitRemaining.WithLabelValues("graphql").Set(float64(rateLimit.Remaining))
+	}
+	return response, rateLimit, nil
+}
@@ -54,7 +54,6 @@ func TestGithubGraphQLClient_FetchRepos(t *testing.T) {
 	type fields struct {
 		httpClient       net.HttpClient
 		githubGraphQLUrl string
-		token            string
 	}
 	type args struct {
 		events []model.Event
@@ -71,7 +70,6 @@ func TestGithubGraphQLClient_FetchRepos(t *testing.T) {
 			fields: fields{
 				httpClient:       mockHttpClient(mockCtrl, validResponse),
 				githubGraphQLUrl: "",
-				token:            "",
 			},
 			args: args{[]model.Event{{RepoFullName: "a/b", CreatedAt: now}, {RepoFullName: "c/d", CreatedAt: now}}},
 			want: []model.Repo{{
@@ -89,7 +87,6 @@ func TestGithubGraphQLClient_FetchRepos(t *testing.T) {
 			fields: fields{
 				httpClient:       mockHttpClient(mockCtrl, "{\"data\":{}}"),
 				githubGraphQLUrl: "",
-				token:            "",
 			},
 			args:    args{[]model.Event{{RepoFullName: "a/b", CreatedAt: now}, {RepoFullName: "c/d", CreatedAt: now}}},
 			want:    []model.Repo{},
@@ -100,7 +97,6 @@ func TestGithubGraphQLClient_FetchRepos(t *testing.T) {
 			fields: fields{
 				httpClient:       mockHttpClient(mockCtrl, "hello world"),
 				githubGraphQLUrl: "",
-				token:            "",
 			},
 			args:    args{[]model.Event{{RepoFullName: "a/b", CreatedAt: now}, {RepoFullName: "c/d", CreatedAt: now}}},
 			want:    nil,
@@ -112,7 +108,6 @@ func TestGithubGraphQLClient_FetchRepos(t *testing.T) {
 			receiver := GithubGraphQLClient{
 				httpClient:       tt.fields.httpClient,
 				githubGraphQLUrl: tt.fields.githubGraphQLUrl,
-				token:            tt.fields.token,
 			}
 			got, err := receiver.FetchRepos(tt.args.events)
 			if (err != nil) != tt.wantErr {
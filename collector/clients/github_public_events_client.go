@@ -3,13 +3,20 @@ package clients
 import (
 	"context"
 	"github-events-microservices/collector/config"
+	"github-events-microservices/collector/metrics"
 	"github-events-microservices/collector/net"
 	"github-events-microservices/model"
 	"github.com/google/go-github/v57/github"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"log/slog"
 	"net/http"
 )
 
+var tracer = otel.Tracer("github-events-microservices/collector/clients")
+
 type GitHubPublicEventsClient struct {
 	restApiClient       GitHubRestClient
 	githubGraphQLClient GithubGraphQLClient
@@ -21,38 +28,74 @@ func (receiver GitHubPublicEventsClient) ListEvents() ([]model.Event, error) {
 }
 
 func (receiver GitHubPublicEventsClient) ListEventsWithOptions(options *github.ListOptions) ([]model.Event, error) {
-	results, err := receiver.restApiClient.ListEvents(context.Background(), options)
+	return receiver.ListEventsWithContext(context.Background(), options)
+}
+
+func (receiver GitHubPublicEventsClient) ListEventsWithContext(ctx context.Context, options *github.ListOptions) ([]model.Event, error) {
+	ctx, span := tracer.Start(ctx, "github.ListEvents")
+	defer span.End()
+
+	results, err := receiver.restApiClient.ListEvents(ctx, options)
 	if err != nil {
+		metrics.FetchErrors.Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
+	metrics.EventsFetched.Add(float64(len(results)))
+	span.SetAttributes(attribute.Int("events.count", len(results)))
 	var events = make([]model.Event, len(results))
 	for i, eventPointer := range results {
-		events[i] = model.Event{
-			ID:             *eventPointer.ID,
-			Type:           *eventPointer.Type,
-			CreatedAt:      eventPointer.CreatedAt.Time,
-			Public:         *eventPointer.Public,
-			RepoFullName:   *eventPointer.Repo.Name,
-			RepoUrl:        *eventPointer.Repo.URL,
-			ActorLogin:     *eventPointer.Actor.Login,
-			ActorId:        *eventPointer.Actor.ID,
-			ActorUrl:       *eventPointer.Actor.URL,
-			ActorAvatarUrl: *eventPointer.Actor.AvatarURL,
-		}
+		events[i] = toEvent(eventPointer)
 	}
 	return events, nil
 }
 
+func toEvent(eventPointer *github.Event) model.Event {
+	return model.Event{
+		ID:             eventPointer.GetID(),
+		Type:           eventPointer.GetType(),
+		CreatedAt:      eventPointer.GetCreatedAt().Time,
+		Public:         eventPointer.GetPublic(),
+		RepoFullName:   eventPointer.GetRepo().GetName(),
+		RepoUrl:        eventPointer.GetRepo().GetURL(),
+		ActorLogin:     eventPointer.GetActor().GetLogin(),
+		ActorId:        eventPointer.GetActor().GetID(),
+		ActorUrl:       eventPointer.GetActor().GetURL(),
+		ActorAvatarUrl: eventPointer.GetActor().GetAvatarURL(),
+	}
+}
+
 func (receiver GitHubPublicEventsClient) FetchRepos(events []model.Event) ([]model.Repo, error) {
-	slog.Debug("fetching repos")
-	return receiver.githubGraphQLClient.FetchRepos(events)
+	return receiver.FetchReposWithContext(context.Background(), events)
+}
+
+func (receiver GitHubPublicEventsClient) FetchReposWithContext(ctx context.Context, events []model.Event) ([]model.Repo, error) {
+	slog.DebugContext(ctx, "fetching repos")
+	ctx, span := tracer.Start(ctx, "github.FetchRepos")
+	defer span.End()
+
+	repos, err := receiver.githubGraphQLClient.FetchReposWithContext(ctx, events)
+	requested := len(buildRepoLastUpdatedMap(events))
+	metrics.ReposResolved.Add(float64(len(repos)))
+	if requested > len(repos) {
+		metrics.ReposFailed.Add(float64(requested - len(repos)))
+	}
+	span.SetAttributes(attribute.Int("repos.requested", requested), attribute.Int("repos.resolved", len(repos)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return repos, err
 }
 
 func NewGitHubClient(client *github.Client) *GitHubPublicEventsClient {
 	graphQLClient := GithubGraphQLClient{
-		httpClient:       net.NewSimpleHttpClient(http.Client{Timeout: config.CollectorConfiguration.GitHubGraphQLRequestTimeout}),
+		httpClient: net.NewSimpleHttpClient(http.Client{
+			Timeout:   config.Current().GitHubGraphQLRequestTimeout,
+			Transport: NewTokenTransport(otelhttp.NewTransport(http.DefaultTransport)),
+		}),
 		githubGraphQLUrl: "https://api.github.com/graphql",
-		token:            "Bearer " + config.CollectorConfiguration.GitHubToken,
 	}
 
 	return &GitHubPublicEventsClient{
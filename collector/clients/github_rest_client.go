@@ -2,11 +2,20 @@ package clients
 
 import (
 	"context"
+	"errors"
+	"github-events-microservices/collector/feeds"
+	"github-events-microservices/collector/health"
+	"github-events-microservices/collector/metrics"
 	"github.com/google/go-github/v57/github"
+	"net/http"
+	"strconv"
+	"time"
 )
 
 type GitHubRestClient interface {
 	ListEvents(context.Context, *github.ListOptions) ([]*github.Event, error)
+	// ListFeedEvents lists a page of an events feed. With an etag, an unchanged page is reported as not modified.
+	ListFeedEvents(ctx context.Context, path string, etag string, options *github.ListOptions) (feeds.Page, error)
 }
 
 type SimpleGitHubRestClient struct {
@@ -18,6 +27,62 @@ func NewSimpleGitHubRestClient(restApiClient *github.Client) *SimpleGitHubRestCl
 }
 
 func (simpleGitHubRestClient SimpleGitHubRestClient) ListEvents(ctx context.Context, options *github.ListOptions) ([]*github.Event, error) {
-	events, _, err := simpleGitHubRestClient.restApiClient.Activity.ListEvents(ctx, options)
+	events, response, err := simpleGitHubRestClient.restApiClient.Activity.ListEvents(ctx, options)
+	recordResponse(response)
 	return events, err
 }
+
+func (simpleGitHubRestClient SimpleGitHubRestClient) ListFeedEvents(ctx context.Context, path string, etag string, options *github.ListOptions) (feeds.Page, error) {
+	request, err := simpleGitHubRestClient.restApiClient.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return feeds.Page{}, err
+	}
+	query := request.URL.Query()
+	if options.Page > 0 {
+		query.Set("page", strconv.Itoa(options.Page))
+	}
+	if options.PerPage > 0 {
+		query.Set("per_page", strconv.Itoa(options.PerPage))
+	}
+	request.URL.RawQuery = query.Encode()
+	if len(etag) > 0 {
+		request.Header.Set("If-None-Match", etag)
+	}
+
+	var events []*github.Event
+	response, err := simpleGitHubRestClient.restApiClient.Do(ctx, request, &events)
+	recordResponse(response)
+	if response == nil {
+		return feeds.Page{}, err
+	}
+	page := feeds.Page{
+		Events:       events,
+		ETag:         response.Header.Get("ETag"),
+		NotModified:  response.StatusCode == http.StatusNotModified,
+		PollInterval: pollInterval(response),
+		NextPage:     response.NextPage,
+	}
+	var errorResponse *github.ErrorResponse
+	if page.NotModified && errors.As(err, &errorResponse) {
+		// go-github reports 304 responses as errors
+		return page, nil
+	}
+	return page, err
+}
+
+func recordResponse(response *github.Response) {
+	if response != nil {
+		metrics.RateLimitRemaining.WithLabelValues("rest").Set(float64(response.Rate.Remaining))
+		health.CollectorState.RecordRateLimit(response.Rate.Limit, response.Rate.Remaining, response.Rate.Reset.Time)
+		health.CollectorState.RecordTokenValidity(response.StatusCode != http.StatusUnauthorized)
+	}
+}
+
+// pollInterval is the minimum time before polling the feed again, as requested by the X-Poll-Interval header
+func pollInterval(response *github.Response) time.Duration {
+	seconds, err := strconv.Atoi(response.Header.Get("X-Poll-Interval"))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -1,116 +1,430 @@
 package clients
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github-events-microservices/collector/config"
+	"github-events-microservices/collector/metrics"
 	"github-events-microservices/model"
 	"github-events-microservices/stores"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
 	"os"
+	"sort"
 	"sync"
+	"time"
 )
 
 type StoreType string
 
 type GithubStoreClient struct {
-	storesMap map[string]stores.ReadWriteStore
-	wg        *sync.WaitGroup
+	client      *mongo.Client
+	storesMap   map[string]stores.ReadWriteStore
+	wg          *sync.WaitGroup
+	retryPolicy RetryPolicy
 }
 
-func (receiver GithubStoreClient) Save(events []model.Event, repos []model.Repo) {
+type storeItem struct {
+	id    interface{}
+	value interface{}
+}
+
+type writeFunc func(ctx context.Context, store stores.ReadWriteStore, items []storeItem) error
+
+// Save stores the events, repos and users of a batch in parallel and returns the joined errors of the three saves.
+// Transient errors are retried, items failing with a permanent error are dead-lettered.
+func (receiver GithubStoreClient) Save(events []model.Event, repos []model.Repo) error {
+	return receiver.SaveWithContext(context.Background(), events, repos)
+}
+
+func (receiver GithubStoreClient) SaveWithContext(ctx context.Context, events []model.Event, repos []model.Repo) error {
+	ctx, span := tracer.Start(ctx, "store.Save", trace.WithAttributes(
+		attribute.Int("events.count", len(events)),
+		attribute.Int("repos.count", len(repos))))
+	defer span.End()
+
+	errs := make([]error, 3)
 	receiver.wg.Add(3)
 
-	go receiver.saveEvents(events)
-	go receiver.saveRepos(repos)
-	go receiver.saveUsers(events)
+	go func() { errs[0] = receiver.saveEvents(ctx, events) }()
+	go func() { errs[1] = receiver.saveRepos(ctx, repos) }()
+	go func() { errs[2] = receiver.saveUsers(ctx, events, upsertItems) }()
 
 	receiver.wg.Wait()
+	err := errors.Join(errs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
 }
 
-func (receiver GithubStoreClient) saveEvents(events []model.Event) {
-	slog.Debug("storing events")
+// SaveHistoryWithContext stores past events, like the backfilled ones, along with the users missing from the store.
+// Existing users are left as they are, so their last_updated_at doesn't go back in time. Repos aren't fetched for past
+// events.
+func (receiver GithubStoreClient) SaveHistoryWithContext(ctx context.Context, events []model.Event) error {
+	ctx, span := tracer.Start(ctx, "store.SaveHistory", trace.WithAttributes(attribute.Int("events.count", len(events))))
+	defer span.End()
+
+	errs := make([]error, 2)
+	receiver.wg.Add(2)
+
+	go func() { errs[0] = receiver.saveEvents(ctx, events) }()
+	go func() { errs[1] = receiver.saveUsers(ctx, events, insertItems) }()
+
+	receiver.wg.Wait()
+	err := errors.Join(errs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+func (receiver GithubStoreClient) saveEvents(ctx context.Context, events []model.Event) error {
+	slog.DebugContext(ctx, "storing events")
 	defer receiver.wg.Done()
 
-	items := make([]interface{}, 0)
+	items := make([]storeItem, 0)
 	for _, event := range events {
-		eventItem := event
-		items = append(items, eventItem)
+		items = append(items, storeItem{id: event.ID, value: event})
 	}
 
-	err := receiver.eventsStore().SaveAll(items)
+	err := receiver.write(ctx, config.Current().EventsCollection, items, insertItems)
 	if err != nil {
-		slog.Error(fmt.Sprintf("failed to save events %s", err.Error()))
+		slog.ErrorContext(ctx, fmt.Sprintf("failed to save events %s", err.Error()))
+		return fmt.Errorf("failed to save events: %w", err)
 	}
+	return nil
 }
 
-func (receiver GithubStoreClient) saveRepos(repos []model.Repo) {
-	slog.Debug("storing events")
+func (receiver GithubStoreClient) saveRepos(ctx context.Context, repos []model.Repo) error {
+	slog.DebugContext(ctx, "storing events")
 	defer receiver.wg.Done()
 
-	reposMap := make(map[interface{}]interface{})
+	items := make([]storeItem, 0)
 	for _, repo := range repos {
-		reposMap[repo.ID] = repo
+		items = append(items, storeItem{id: repo.ID, value: repo})
 	}
 
-	err := receiver.reposStore().UpdateAllById(reposMap)
+	err := receiver.write(ctx, config.Current().ReposCollection, items, upsertItems)
 	if err != nil {
-		slog.Error(fmt.Sprintf("failed to save repos %s", err.Error()))
+		slog.ErrorContext(ctx, fmt.Sprintf("failed to save repos %s", err.Error()))
+		return fmt.Errorf("failed to save repos: %w", err)
 	}
+	return nil
 }
 
-func (receiver GithubStoreClient) saveUsers(events []model.Event) {
-	slog.Debug("storing users")
+func (receiver GithubStoreClient) saveUsers(ctx context.Context, events []model.Event, writeItems writeFunc) error {
+	slog.DebugContext(ctx, "storing users")
 	defer receiver.wg.Done()
 
-	usersMap := make(map[interface{}]interface{})
+	usersMap := make(map[int64]model.User)
 	for _, event := range events {
-		user := model.User{
+		usersMap[event.ActorId] = model.User{
 			ID:            event.ActorId,
 			Login:         event.ActorLogin,
 			Url:           event.ActorUrl,
 			AvatarUrl:     event.ActorAvatarUrl,
 			LastUpdatedAt: event.CreatedAt,
 		}
-		usersMap[event.ActorId] = user
 	}
+	items := make([]storeItem, 0)
+	for id, user := range usersMap {
+		items = append(items, storeItem{id: id, value: user})
+	}
+
+	err := receiver.write(ctx, config.Current().UsersCollection, items, writeItems)
+	if err != nil {
+		slog.ErrorContext(ctx, fmt.Sprintf("failed to save users: %s", err.Error()))
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+	return nil
+}
+
+// write stores items with retries. On a permanent error the items are written one by one, so only
+// the failing ones are dead-lettered. An error is returned only when the write may succeed later.
+func (receiver GithubStoreClient) write(ctx context.Context, collection string, items []storeItem, writeItems writeFunc) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+	ctx, span := tracer.Start(ctx, "store.save "+collection, trace.WithAttributes(attribute.Int("items.count", len(items))))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	store := receiver.storesMap[collection]
+	start := time.Now()
+	attempts, err := receiver.retryPolicy.Do(ctx, "save "+collection, func() error {
+		return writeItems(ctx, store, items)
+	})
+	metrics.StoreWriteLatency.WithLabelValues(collection).Observe(time.Since(start).Seconds())
+	span.SetAttributes(attribute.Int("attempts", attempts))
+	if err == nil || stores.IsTransientError(err) {
+		return err
+	}
+
+	slog.WarnContext(ctx, fmt.Sprintf("permanent error while saving %d items into %s, isolating failed items: %s", len(items), collection, err.Error()))
+	deadLetters := make([]model.DeadLetter, 0)
+	for _, item := range items {
+		attempts, err := receiver.retryPolicy.Do(ctx, "save "+collection, func() error {
+			return writeItems(ctx, store, []storeItem{item})
+		})
+		if err == nil {
+			continue
+		} else if stores.IsTransientError(err) {
+			return err
+		}
+		deadLetters = append(deadLetters, newDeadLetter(collection, item, err, attempts))
+	}
+	span.SetAttributes(attribute.Int("items.dead_lettered", len(deadLetters)))
+	return receiver.deadLetter(ctx, deadLetters)
+}
+
+func (receiver GithubStoreClient) deadLetter(ctx context.Context, deadLetters []model.DeadLetter) error {
+	if len(deadLetters) == 0 {
+		return nil
+	}
+	slog.ErrorContext(ctx, fmt.Sprintf("dead-lettering %d items", len(deadLetters)))
+	items := make(map[interface{}]interface{})
+	for _, deadLetter := range deadLetters {
+		items[deadLetter.ID] = deadLetter
+		metrics.DeadLettered.WithLabelValues(deadLetter.Collection).Inc()
+	}
+	_, err := receiver.retryPolicy.Do(ctx, "save dead letters", func() error {
+		return receiver.deadLettersStore().UpdateAllByIdWithContext(ctx, items)
+	})
+	return err
+}
+
+// ReplayDeadLetters writes dead-lettered items back into their collections and removes the ones that succeeded.
+func (receiver GithubStoreClient) ReplayDeadLetters() (int, error) {
+	deadLetters := make([]model.DeadLetter, 0)
+	err := receiver.deadLettersStore().All(&deadLetters)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := make([]interface{}, 0)
+	var errs []error
+	for _, deadLetter := range deadLetters {
+		store, ok := receiver.storesMap[deadLetter.Collection]
+		if !ok {
+			errs = append(errs, fmt.Errorf("dead letter %s: unknown collection '%s'", deadLetter.ID, deadLetter.Collection))
+			continue
+		}
+		writeItems := upsertItems
+		if deadLetter.Collection == config.Current().EventsCollection {
+			writeItems = insertItems
+		}
+		item := storeItem{id: deadLetter.ItemId, value: deadLetter.Item}
+		_, err := receiver.retryPolicy.Do(context.Background(), "replay "+deadLetter.ID, func() error {
+			return writeItems(context.Background(), store, []storeItem{item})
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dead letter %s: %w", deadLetter.ID, err))
+			continue
+		}
+		replayed = append(replayed, deadLetter.ID)
+	}
+
+	err = receiver.deadLettersStore().DeleteAllById(replayed)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return len(replayed), errors.Join(errs...)
+}
+
+// SaveBotScoresWithContext stores the bot scores of actors on their users, the reviews of the api are left as they are
+func (receiver GithubStoreClient) SaveBotScoresWithContext(ctx context.Context, scores []model.BotScore) error {
+	if len(scores) == 0 {
+		return nil
+	}
+	items := make(map[interface{}]interface{})
+	for _, score := range scores {
+		items[score.UserId] = score
+	}
+	_, err := receiver.retryPolicy.Do(ctx, "save bot scores", func() error {
+		return receiver.storesMap[config.Current().UsersCollection].UpdateAllByIdWithContext(ctx, items)
+	})
+	return err
+}
 
-	err := receiver.usersStore().UpdateAllById(usersMap)
+// RecordWriteVersion bumps the write version read by the api, after events, repos or users were written or deleted
+func (receiver GithubStoreClient) RecordWriteVersion() {
+	version := model.NewWriteVersion(time.Now())
+	err := receiver.storesMap[config.Current().MetaCollection].UpdateAllById(map[interface{}]interface{}{version.ID: version})
 	if err != nil {
-		slog.Error(fmt.Sprintf("failed to save users: %s", err.Error()))
+		// the api falls back to the expiry of its cached responses
+		slog.Warn(fmt.Sprintf("failed to record write version: %s", err.Error()))
+	}
+}
+
+func (receiver GithubStoreClient) Ping() error {
+	var errs []error
+	for collection, store := range receiver.storesMap {
+		err := store.Ping()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to ping store '%s': %w", collection, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (receiver GithubStoreClient) Close() error {
+	var errs []error
+	for collection, store := range receiver.storesMap {
+		err := store.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to close store '%s': %w", collection, err))
+		}
+	}
+	if receiver.client != nil {
+		err := receiver.client.Disconnect(context.Background())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to disconnect from mongo: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// EnsureIndexes creates the declared indexes of every collection
+func (receiver GithubStoreClient) EnsureIndexes() error {
+	var errs []error
+	for collection, specs := range receiver.indexSpecs() {
+		manager, ok := receiver.storesMap[collection].(stores.IndexManager)
+		if ok {
+			errs = append(errs, manager.EnsureIndexes(specs))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IndexReports compares the declared indexes of every collection with the existing ones
+func (receiver GithubStoreClient) IndexReports() ([]stores.IndexReport, error) {
+	var reports []stores.IndexReport
+	var errs []error
+	for collection, specs := range receiver.indexSpecs() {
+		manager, ok := receiver.storesMap[collection].(stores.IndexManager)
+		if !ok {
+			continue
+		}
+		report, err := manager.IndexReport(specs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to report indexes of '%s': %w", collection, err))
+			continue
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Collection < reports[j].Collection })
+	return reports, errors.Join(errs...)
+}
+
+// ExpiringStores returns the stores of the collections that can be pruned, by collection
+func (receiver GithubStoreClient) ExpiringStores() map[string]stores.ExpiringStore {
+	expiringStores := make(map[string]stores.ExpiringStore)
+	for collection, store := range receiver.storesMap {
+		expiringStore, ok := store.(stores.ExpiringStore)
+		if ok {
+			expiringStores[collection] = expiringStore
+		}
+	}
+	return expiringStores
+}
+
+func (receiver GithubStoreClient) indexSpecs() map[string][]stores.IndexSpec {
+	configuration := config.Current()
+	return map[string][]stores.IndexSpec{
+		configuration.EventsCollection:      stores.EventIndexes,
+		configuration.ReposCollection:       stores.RepoIndexes,
+		configuration.UsersCollection:       stores.UserIndexes,
+		configuration.DeadLettersCollection: stores.WithTTL(stores.DeadLetterIndexes, "failed_at", configuration.DeadLettersTTL),
 	}
 }
 
 func (receiver GithubStoreClient) eventsStore() stores.ReadWriteStore {
-	return receiver.storesMap[config.CollectorConfiguration.EventsCollection]
+	return receiver.storesMap[config.Current().EventsCollection]
 }
 
 func (receiver GithubStoreClient) reposStore() stores.ReadWriteStore {
-	return receiver.storesMap[config.CollectorConfiguration.ReposCollection]
+	return receiver.storesMap[config.Current().ReposCollection]
 }
 
 func (receiver GithubStoreClient) usersStore() stores.ReadWriteStore {
-	return receiver.storesMap[config.CollectorConfiguration.UsersCollection]
+	return receiver.storesMap[config.Current().UsersCollection]
 }
 
-func NewGithubStoreClient(url string, port string) *GithubStoreClient {
-	var wg sync.WaitGroup
+// RulesStore returns the store of the alert rules, which are managed through the api
+func (receiver GithubStoreClient) RulesStore() stores.ReadStore {
+	return receiver.storesMap[config.Current().RulesCollection]
+}
 
-	storesMap := make(map[string]stores.ReadWriteStore)
-	storesMap[config.CollectorConfiguration.EventsCollection] = createMongoStore(url, port, config.CollectorConfiguration.EventsDb, config.CollectorConfiguration.EventsCollection)
-	storesMap[config.CollectorConfiguration.ReposCollection] = createMongoStore(url, port, config.CollectorConfiguration.ReposDb, config.CollectorConfiguration.ReposCollection)
-	storesMap[config.CollectorConfiguration.UsersCollection] = createMongoStore(url, port, config.CollectorConfiguration.UsersDb, config.CollectorConfiguration.UsersCollection)
+func (receiver GithubStoreClient) deadLettersStore() stores.ReadWriteStore {
+	return receiver.storesMap[config.Current().DeadLettersCollection]
+}
 
-	return &GithubStoreClient{
-		storesMap: storesMap,
-		wg:        &wg,
+func insertItems(ctx context.Context, store stores.ReadWriteStore, items []storeItem) error {
+	values := make([]interface{}, len(items))
+	for i, item := range items {
+		values[i] = item.value
+	}
+	return store.SaveAllWithContext(ctx, values)
+}
+
+func upsertItems(ctx context.Context, store stores.ReadWriteStore, items []storeItem) error {
+	values := make(map[interface{}]interface{})
+	for _, item := range items {
+		values[item.id] = item.value
 	}
+	return store.UpdateAllByIdWithContext(ctx, values)
 }
 
-func createMongoStore(url string, port string, database string, collection string) *stores.MongoDbCollectionStore {
-	mongoDbStore, err := stores.NewMongoDbStore(url, port, database, collection)
+func newDeadLetter(collection string, item storeItem, err error, attempts int) model.DeadLetter {
+	return model.DeadLetter{
+		ID:         fmt.Sprintf("%s:%v", collection, item.id),
+		Collection: collection,
+		ItemId:     item.id,
+		Item:       item.value,
+		Error:      err.Error(),
+		Attempts:   attempts,
+		FailedAt:   time.Now(),
+	}
+}
+
+// NewGithubStoreClient connects to mongo with a single client shared by the stores of all the collections
+func NewGithubStoreClient(mongoOptions stores.MongoOptions) *GithubStoreClient {
+	var wg sync.WaitGroup
+
+	client, err := stores.NewMongoClient(mongoOptions)
 	if err != nil {
-		slog.Error(err.Error())
+		slog.Error(fmt.Sprintf("failed to connect to mongo: %s", err.Error()))
 		os.Exit(1)
 	}
-	return mongoDbStore
+
+	configuration := config.Current()
+	storesMap := make(map[string]stores.ReadWriteStore)
+	storesMap[configuration.EventsCollection] = stores.NewMongoDbStore(client, configuration.EventsDb, configuration.EventsCollection)
+	storesMap[configuration.ReposCollection] = stores.NewMongoDbStore(client, configuration.ReposDb, configuration.ReposCollection)
+	storesMap[configuration.UsersCollection] = stores.NewMongoDbStore(client, configuration.UsersDb, configuration.UsersCollection)
+	storesMap[configuration.DeadLettersCollection] = stores.NewMongoDbStore(client, configuration.DeadLettersDb, configuration.DeadLettersCollection)
+	storesMap[configuration.RulesCollection] = stores.NewMongoDbStore(client, configuration.RulesDb, configuration.RulesCollection)
+	storesMap[configuration.MetaCollection] = stores.NewMongoDbStore(client, configuration.MetaDb, configuration.MetaCollection)
+
+	return &GithubStoreClient{
+		client:    client,
+		storesMap: storesMap,
+		wg:        &wg,
+		retryPolicy: NewRetryPolicy(
+			configuration.RetryMaxAttempts,
+			configuration.RetryInitialBackoff,
+			configuration.RetryMaxBackoff),
+	}
 }
@@ -1,12 +1,17 @@
 package clients
 
 import (
+	"context"
 	"github-events-microservices/collector/config"
 	"github-events-microservices/model"
 	"github-events-microservices/stores"
+	mockstores "github-events-microservices/stores/mocks"
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/mongo"
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestGithubStoreClient_Save(t *testing.T) {
@@ -77,8 +82,95 @@ func createStoresMap() map[string]stores.ReadWriteStore {
 	var eventsData []interface{}
 	var reposData []interface{}
 	var usersData []interface{}
-	storesMap[config.CollectorConfiguration.EventsCollection] = stores.NewStubStore(eventsData)
-	storesMap[config.CollectorConfiguration.ReposCollection] = stores.NewStubStore(reposData)
-	storesMap[config.CollectorConfiguration.UsersCollection] = stores.NewStubStore(usersData)
+	storesMap[config.Current().EventsCollection] = stores.NewStubStore(eventsData)
+	storesMap[config.Current().ReposCollection] = stores.NewStubStore(reposData)
+	storesMap[config.Current().UsersCollection] = stores.NewStubStore(usersData)
 	return storesMap
 }
+
+func TestGithubStoreClient_SaveDeadLettersPermanentFailures(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	permanentError := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 121, Message: "Document failed validation"}}}
+	event1 := model.Event{ID: "ev1", ActorId: 1}
+	event2 := model.Event{ID: "ev2", ActorId: 2}
+
+	eventsStore := mockstores.NewMockReadWriteStore(mockCtrl)
+	gomock.InOrder(
+		eventsStore.EXPECT().SaveAllWithContext(gomock.Any(), []interface{}{event1, event2}).Return(permanentError),
+		eventsStore.EXPECT().SaveAllWithContext(gomock.Any(), []interface{}{event1}).Return(nil),
+		eventsStore.EXPECT().SaveAllWithContext(gomock.Any(), []interface{}{event2}).Return(permanentError),
+	)
+	deadLettersStore := mockstores.NewMockReadWriteStore(mockCtrl)
+	deadLettersStore.EXPECT().
+		UpdateAllByIdWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, items map[interface{}]interface{}) error {
+			deadLetter, ok := items["events:ev2"].(model.DeadLetter)
+			if len(items) != 1 || !ok {
+				t.Errorf("expected a single dead letter for ev2, got %v", items)
+			} else if deadLetter.Item != event2 || deadLetter.Error != permanentError.Error() {
+				t.Errorf("unexpected dead letter: %v", deadLetter)
+			}
+			return nil
+		})
+
+	storesMap := createStoresMap()
+	storesMap[config.Current().EventsCollection] = eventsStore
+	storesMap[config.Current().DeadLettersCollection] = deadLettersStore
+	receiver := GithubStoreClient{
+		storesMap:   storesMap,
+		wg:          &sync.WaitGroup{},
+		retryPolicy: RetryPolicy{MaxAttempts: 3},
+	}
+
+	err := receiver.Save([]model.Event{event1, event2}, nil)
+	if err != nil {
+		t.Errorf("Save() error = %v, want nil", err)
+	}
+}
+
+func TestGithubStoreClient_SaveHistoryWithContext(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	created := time.Date(2015, 1, 1, 15, 0, 0, 0, time.UTC)
+	event := model.Event{ID: "ev1", ActorId: 1, ActorLogin: "octocat", CreatedAt: created}
+	eventsStore := mockstores.NewMockReadWriteStore(mockCtrl)
+	eventsStore.EXPECT().SaveAllWithContext(gomock.Any(), []interface{}{event}).Return(nil)
+	// past events only insert the missing users, the existing ones keep their last_updated_at
+	usersStore := mockstores.NewMockReadWriteStore(mockCtrl)
+	usersStore.EXPECT().SaveAllWithContext(gomock.Any(), []interface{}{model.User{ID: 1, Login: "octocat", LastUpdatedAt: created}}).Return(nil)
+
+	storesMap := createStoresMap()
+	storesMap[config.Current().EventsCollection] = eventsStore
+	storesMap[config.Current().UsersCollection] = usersStore
+	receiver := GithubStoreClient{storesMap: storesMap, wg: &sync.WaitGroup{}, retryPolicy: RetryPolicy{MaxAttempts: 3}}
+
+	err := receiver.SaveHistoryWithContext(context.Background(), []model.Event{event})
+	if err != nil {
+		t.Errorf("SaveHistoryWithContext() error = %v, want nil", err)
+	}
+}
+
+func TestGithubStoreClient_SaveBotScores(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	score := model.BotScore{UserId: 1, Login: "spammer", Score: 80, Suspected: true, Reasons: []string{"event_rate"}}
+	usersStore := mockstores.NewMockReadWriteStore(mockCtrl)
+	usersStore.EXPECT().
+		UpdateAllByIdWithContext(gomock.Any(), map[interface{}]interface{}{int64(1): score}).
+		Return(nil)
+
+	storesMap := createStoresMap()
+	storesMap[config.Current().UsersCollection] = usersStore
+	receiver := GithubStoreClient{storesMap: storesMap, retryPolicy: RetryPolicy{MaxAttempts: 3}}
+
+	for _, scores := range [][]model.BotScore{nil, {score}} {
+		err := receiver.SaveBotScoresWithContext(context.Background(), scores)
+		if err != nil {
+			t.Errorf("SaveBotScoresWithContext() error = %v, want nil", err)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package mock_clients
 
 import (
 	context "context"
+	feeds "github-events-microservices/collector/feeds"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -49,3 +50,18 @@ func (mr *MockGitHubRestClientMockRecorder) ListEvents(arg0, arg1 interface{}) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEvents", reflect.TypeOf((*MockGitHubRestClient)(nil).ListEvents), arg0, arg1)
 }
+
+// ListFeedEvents mocks base method.
+func (m *MockGitHubRestClient) ListFeedEvents(ctx context.Context, path, etag string, options *github.ListOptions) (feeds.Page, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFeedEvents", ctx, path, etag, options)
+	ret0, _ := ret[0].(feeds.Page)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFeedEvents indicates an expected call of ListFeedEvents.
+func (mr *MockGitHubRestClientMockRecorder) ListFeedEvents(ctx, path, etag, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFeedEvents", reflect.TypeOf((*MockGitHubRestClient)(nil).ListFeedEvents), ctx, path, etag, options)
+}
@@ -0,0 +1,197 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github-events-microservices/collector/config"
+	"github-events-microservices/collector/metrics"
+	"github-events-microservices/model"
+	"github-events-microservices/stores"
+	"time"
+)
+
+const refreshedAtField = "refreshed_at"
+
+// errRateLimitReserve stops a refresh once the GraphQL budget reaches the reserve kept for the enrichment of new events
+var errRateLimitReserve = errors.New("rate limit reserve reached")
+
+// Refresher re-queries the least recently refreshed repos and users, so the ones that no longer show up in events don't go stale
+type Refresher struct {
+	graphQLClient GithubGraphQLClient
+	reposStore    stores.ReadWriteStore
+	usersStore    stores.ReadWriteStore
+	now           func() time.Time
+}
+
+// RefreshResult counts the items refreshed by a run, per collection. Items no longer found on GitHub are only marked as refreshed.
+type RefreshResult struct {
+	Collection string
+	Updated    int
+	NotFound   int
+}
+
+// repoRefresh and userRefresh are the refreshed fields, set even when empty
+type repoRefresh struct {
+	Url         string    `bson:"url"`
+	Stars       int       `bson:"stars"`
+	RefreshedAt time.Time `bson:"refreshed_at"`
+}
+
+type userRefresh struct {
+	Name        string    `bson:"name"`
+	Company     string    `bson:"company"`
+	Location    string    `bson:"location"`
+	Followers   int       `bson:"followers"`
+	RefreshedAt time.Time `bson:"refreshed_at"`
+}
+
+type notFoundRefresh struct {
+	RefreshedAt time.Time `bson:"refreshed_at"`
+}
+
+// RefreshWithContext refreshes, in batches, the repos and then the users not refreshed for REFRESH_AFTER_DAYS.
+// It stops after REFRESH_MAX_BATCHES batches per collection or once the GraphQL budget reaches REFRESH_RATE_LIMIT_RESERVE.
+func (receiver Refresher) RefreshWithContext(ctx context.Context) ([]RefreshResult, error) {
+	reposResult, err := receiver.refreshRepos(ctx)
+	results := []RefreshResult{reposResult}
+	if err != nil {
+		return results, err
+	}
+	usersResult, err := receiver.refreshUsers(ctx)
+	return append(results, usersResult), err
+}
+
+func (receiver Refresher) refreshRepos(ctx context.Context) (RefreshResult, error) {
+	configuration := config.Current()
+	result := RefreshResult{Collection: configuration.ReposCollection}
+	cutoff := receiver.now().Add(-configuration.RefreshAfter)
+	for batch := 0; batch < configuration.RefreshMaxBatches && ctx.Err() == nil; batch++ {
+		repos := make([]model.Repo, 0)
+		err := receiver.reposStore.GetWithContext(ctx, int64(configuration.RefreshBatchSize), stores.OrderBy{Column: refreshedAtField, Order: 1}, &repos)
+		if err != nil {
+			return result, fmt.Errorf("failed to read repos: %w", err)
+		}
+		ids := make([]string, 0, len(repos))
+		for _, repo := range repos {
+			if repo.RefreshedAt.Before(cutoff) {
+				ids = append(ids, repo.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return result, nil
+		}
+
+		details, rateLimit, err := receiver.graphQLClient.FetchRepoDetailsWithContext(ctx, ids)
+		if err != nil {
+			return result, fmt.Errorf("failed to fetch repos: %w", err)
+		}
+		refreshedAt := receiver.now()
+		items := make(map[interface{}]interface{})
+		for _, id := range ids {
+			items[id] = notFoundRefresh{RefreshedAt: refreshedAt}
+		}
+		for _, repo := range details {
+			items[repo.ID] = repoRefresh{Url: repo.Url, Stars: repo.Stars, RefreshedAt: refreshedAt}
+		}
+		err = receiver.reposStore.UpdateAllByIdWithContext(ctx, items)
+		if err != nil {
+			return result, fmt.Errorf("failed to update repos: %w", err)
+		}
+		result.count(len(details), len(ids)-len(details))
+
+		err = receiver.checkBudget(rateLimit)
+		if err != nil || len(ids) < len(repos) {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (receiver Refresher) refreshUsers(ctx context.Context) (RefreshResult, error) {
+	configuration := config.Current()
+	result := RefreshResult{Collection: configuration.UsersCollection}
+	cutoff := receiver.now().Add(-configuration.RefreshAfter)
+	for batch := 0; batch < configuration.RefreshMaxBatches && ctx.Err() == nil; batch++ {
+		users := make([]model.User, 0)
+		err := receiver.usersStore.GetWithContext(ctx, int64(configuration.RefreshBatchSize), stores.OrderBy{Column: refreshedAtField, Order: 1}, &users)
+		if err != nil {
+			return result, fmt.Errorf("failed to read users: %w", err)
+		}
+		idsByLogin := make(map[string]int64)
+		logins := make([]string, 0, len(users))
+		for _, user := range users {
+			if user.RefreshedAt.Before(cutoff) {
+				idsByLogin[user.Login] = user.ID
+				logins = append(logins, user.Login)
+			}
+		}
+		if len(logins) == 0 {
+			return result, nil
+		}
+
+		details, rateLimit, err := receiver.graphQLClient.FetchUserDetailsWithContext(ctx, logins)
+		if err != nil {
+			return result, fmt.Errorf("failed to fetch users: %w", err)
+		}
+		refreshedAt := receiver.now()
+		items := make(map[interface{}]interface{})
+		for _, id := range idsByLogin {
+			items[id] = notFoundRefresh{RefreshedAt: refreshedAt}
+		}
+		updated := 0
+		for _, user := range details {
+			id, ok := idsByLogin[user.Login]
+			if !ok {
+				continue
+			}
+			items[id] = userRefresh{
+				Name:        user.Name,
+				Company:     user.Company,
+				Location:    user.Location,
+				Followers:   user.Followers.TotalCount,
+				RefreshedAt: refreshedAt,
+			}
+			updated++
+		}
+		err = receiver.usersStore.UpdateAllByIdWithContext(ctx, items)
+		if err != nil {
+			return result, fmt.Errorf("failed to update users: %w", err)
+		}
+		result.count(updated, len(idsByLogin)-updated)
+
+		err = receiver.checkBudget(rateLimit)
+		if err != nil || len(logins) < len(users) {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (receiver Refresher) checkBudget(rateLimit RateLimit) error {
+	if rateLimit.Remaining < config.Current().RefreshRateLimitReserve {
+		return fmt.Errorf("%w: %d points remaining until %s", errRateLimitReserve, rateLimit.Remaining, rateLimit.ResetAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (receiver *RefreshResult) count(updated int, notFound int) {
+	receiver.Updated += updated
+	receiver.NotFound += notFound
+	metrics.Refreshed.WithLabelValues(receiver.Collection, "updated").Add(float64(updated))
+	metrics.Refreshed.WithLabelValues(receiver.Collection, "not_found").Add(float64(notFound))
+}
+
+// IsRateLimitReserve reports whether a refresh stopped to keep the rate limit reserve
+func IsRateLimitReserve(err error) bool {
+	return errors.Is(err, errRateLimitReserve)
+}
+
+func NewRefresher(gitHubClient *GitHubPublicEventsClient, storeClient *GithubStoreClient) *Refresher {
+	return &Refresher{
+		graphQLClient: gitHubClient.githubGraphQLClient,
+		reposStore:    storeClient.reposStore(),
+		usersStore:    storeClient.usersStore(),
+		now:           time.Now,
+	}
+}
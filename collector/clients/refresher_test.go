@@ -0,0 +1,113 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	mocknet "github-events-microservices/collector/net/mocks"
+	"github-events-microservices/model"
+	"github-events-microservices/stores"
+	mockstores "github-events-microservices/stores/mocks"
+	"github.com/golang/mock/gomock"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRefresher_RefreshWithContext(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	reposResponse := `{"data":{"rateLimit":{"cost":1,"remaining":4000,"resetAt":"2024-05-10T13:00:00Z"},"nodes":[{"id":"R_1","url":"https://github.com/a/b","stargazerCount":42},null]}}`
+	usersResponse := `{"data":{"rateLimit":{"cost":1,"remaining":3999,"resetAt":"2024-05-10T13:00:00Z"},"userQuery0":{"login":"octo","name":"Octo Cat","company":"GitHub","location":"SF","followers":{"totalCount":7}},"userQuery1":null},"errors":[{"message":"Could not resolve to a User with the login of 'gone'."}]}`
+	lowBudgetResponse := `{"data":{"rateLimit":{"cost":1,"remaining":10,"resetAt":"2024-05-10T13:00:00Z"},"nodes":[{"id":"R_1","url":"https://github.com/a/b","stargazerCount":42}]}}`
+
+	tests := []struct {
+		name        string
+		responses   []string
+		repos       []model.Repo
+		users       []model.User
+		wantRepos   map[interface{}]interface{}
+		wantUsers   map[interface{}]interface{}
+		want        []RefreshResult
+		wantReserve bool
+	}{
+		{
+			name:      "refreshes stale repos and users",
+			responses: []string{reposResponse, usersResponse},
+			repos:     []model.Repo{{ID: "R_1"}, {ID: "R_2", RefreshedAt: now.Add(-time.Hour)}},
+			users:     []model.User{{ID: 1, Login: "octo"}, {ID: 2, Login: "gone"}},
+			wantRepos: map[interface{}]interface{}{
+				"R_1": repoRefresh{Url: "https://github.com/a/b", Stars: 42, RefreshedAt: now},
+				"R_2": notFoundRefresh{RefreshedAt: now},
+			},
+			wantUsers: map[interface{}]interface{}{
+				int64(1): userRefresh{Name: "Octo Cat", Company: "GitHub", Location: "SF", Followers: 7, RefreshedAt: now},
+				int64(2): notFoundRefresh{RefreshedAt: now},
+			},
+			want: []RefreshResult{{Collection: "repos", Updated: 1, NotFound: 1}, {Collection: "users", Updated: 1, NotFound: 1}},
+		},
+		{
+			name:      "stops at the rate limit reserve",
+			responses: []string{lowBudgetResponse},
+			repos:     []model.Repo{{ID: "R_1"}},
+			wantRepos: map[interface{}]interface{}{
+				"R_1": repoRefresh{Url: "https://github.com/a/b", Stars: 42, RefreshedAt: now},
+			},
+			want:        []RefreshResult{{Collection: "repos", Updated: 1}},
+			wantReserve: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			httpClient := mocknet.NewMockHttpClient(mockCtrl)
+			var previous *gomock.Call
+			for _, response := range tt.responses {
+				call := httpClient.EXPECT().Do(gomock.Any()).
+					Return(&http.Response{Body: io.NopCloser(bytes.NewBufferString(response))}, nil)
+				if previous != nil {
+					call.After(previous)
+				}
+				previous = call
+			}
+			reposStore := mockstores.NewMockReadWriteStore(mockCtrl)
+			expectRefresh(reposStore, tt.repos, []model.Repo{{ID: "R_1", RefreshedAt: now}}, tt.wantRepos, !tt.wantReserve)
+			usersStore := mockstores.NewMockReadWriteStore(mockCtrl)
+			if tt.users != nil {
+				expectRefresh(usersStore, tt.users, []model.User{}, tt.wantUsers, true)
+			}
+
+			receiver := Refresher{
+				graphQLClient: GithubGraphQLClient{httpClient: httpClient},
+				reposStore:    reposStore,
+				usersStore:    usersStore,
+				now:           func() time.Time { return now },
+			}
+			got, err := receiver.RefreshWithContext(context.Background())
+			if IsRateLimitReserve(err) != tt.wantReserve || (err != nil && !tt.wantReserve) {
+				t.Fatalf("RefreshWithContext() error = %v, wantReserve %v", err, tt.wantReserve)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RefreshWithContext() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// expectRefresh returns the stale items on the first read and the refreshed ones on the next read, if any
+func expectRefresh[T any](store *mockstores.MockReadWriteStore, stale []T, refreshed []T, want map[interface{}]interface{}, readAgain bool) {
+	orderBy := stores.OrderBy{Column: "refreshed_at", Order: 1}
+	first := store.EXPECT().GetWithContext(gomock.Any(), int64(50), orderBy, gomock.Any()).DoAndReturn(func(_ context.Context, limit int64, orderBy stores.OrderBy, results interface{}) error {
+		*results.(*[]T) = stale
+		return nil
+	})
+	store.EXPECT().UpdateAllByIdWithContext(gomock.Any(), want).Return(nil)
+	if readAgain {
+		store.EXPECT().GetWithContext(gomock.Any(), int64(50), orderBy, gomock.Any()).After(first).DoAndReturn(func(_ context.Context, limit int64, orderBy stores.OrderBy, results interface{}) error {
+			*results.(*[]T) = refreshed
+			return nil
+		})
+	}
+}
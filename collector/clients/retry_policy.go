@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"github-events-microservices/stores"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	sleep          func(time.Duration)
+}
+
+// Do runs operation until it succeeds, fails with a permanent error, runs out of attempts or ctx is done while backing
+// off. It returns the last error along with the number of attempts made.
+func (receiver RetryPolicy) Do(ctx context.Context, name string, operation func() error) (int, error) {
+	attempt := 1
+	for {
+		err := operation()
+		if err == nil || !stores.IsTransientError(err) || attempt >= receiver.MaxAttempts {
+			return attempt, err
+		}
+		backoff := receiver.backoff(attempt)
+		slog.WarnContext(ctx, fmt.Sprintf("%s failed (attempt %d/%d), retrying in %s: %s", name, attempt, receiver.MaxAttempts, backoff, err.Error()))
+		if receiver.sleep != nil {
+			receiver.sleep(backoff)
+		} else {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				// the error stays transient, so the caller keeps the items for later rather than dead-lettering them
+				return attempt, fmt.Errorf("%w, stopped retrying: %w", err, ctx.Err())
+			}
+		}
+		attempt++
+	}
+}
+
+// backoff returns a full-jitter exponential backoff for the given attempt
+func (receiver RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := receiver.InitialBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > receiver.MaxBackoff {
+		backoff = receiver.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+func NewRetryPolicy(maxAttempts int, initialBackoff time.Duration, maxBackoff time.Duration) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: initialBackoff,
+		MaxBackoff:     maxBackoff,
+	}
+}
@@ -0,0 +1,93 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"github-events-microservices/stores"
+	"go.mongodb.org/mongo-driver/mongo"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Do(t *testing.T) {
+	transientError := mongo.CommandError{Code: 10107, Message: "not writable primary"}
+	permanentError := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 121, Message: "Document failed validation"}}}
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "success",
+			errs:         []error{nil},
+			wantAttempts: 1,
+		},
+		{
+			name:         "transient error then success",
+			errs:         []error{transientError, transientError, nil},
+			wantAttempts: 3,
+		},
+		{
+			name:         "transient errors exhaust attempts",
+			errs:         []error{transientError, transientError, transientError, transientError},
+			wantAttempts: 3,
+			wantErr:      transientError,
+		},
+		{
+			name:         "permanent error is not retried",
+			errs:         []error{permanentError, nil},
+			wantAttempts: 1,
+			wantErr:      permanentError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sleeps []time.Duration
+			receiver := RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     10 * time.Millisecond,
+				sleep:          func(duration time.Duration) { sleeps = append(sleeps, duration) },
+			}
+			calls := 0
+			attempts, err := receiver.Do(context.Background(), "test", func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+			if attempts != tt.wantAttempts || calls != tt.wantAttempts {
+				t.Errorf("Do() attempts = %d, calls = %d, want %d", attempts, calls, tt.wantAttempts)
+			}
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			for _, sleep := range sleeps {
+				if sleep > receiver.MaxBackoff {
+					t.Errorf("backoff %s exceeds max backoff %s", sleep, receiver.MaxBackoff)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Do_canceled(t *testing.T) {
+	transientError := mongo.CommandError{Code: 10107, Message: "not writable primary"}
+	ctx, cancel := context.WithCancel(context.Background())
+	receiver := NewRetryPolicy(3, time.Hour, time.Hour)
+
+	calls := 0
+	attempts, err := receiver.Do(ctx, "test", func() error {
+		calls++
+		cancel()
+		return transientError
+	})
+	if attempts != 1 || calls != 1 {
+		t.Errorf("Do() attempts = %d, calls = %d, want 1", attempts, calls)
+	}
+	if !errors.Is(err, context.Canceled) || !stores.IsTransientError(err) {
+		t.Errorf("Do() error = %v, want a transient error canceled by the context", err)
+	}
+}
@@ -0,0 +1,26 @@
+package clients
+
+import (
+	"github-events-microservices/collector/config"
+	"net/http"
+)
+
+// tokenTransport authenticates GitHub requests with the current token, so that a reloaded token applies to the next request
+type tokenTransport struct {
+	base http.RoundTripper
+}
+
+func (receiver tokenTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	token := config.Current().GitHubToken
+	if len(token) == 0 || len(request.Header.Get("Authorization")) > 0 {
+		return receiver.base.RoundTrip(request)
+	}
+	// a RoundTripper must not modify the original request
+	authenticated := request.Clone(request.Context())
+	authenticated.Header.Set("Authorization", "Bearer "+token)
+	return receiver.base.RoundTrip(authenticated)
+}
+
+func NewTokenTransport(base http.RoundTripper) http.RoundTripper {
+	return tokenTransport{base: base}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github-events-microservices/collector/backfill"
+	"github-events-microservices/collector/clients"
+	"github-events-microservices/collector/config"
+	"github-events-microservices/settings"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	replayDeadLettersCommand = "replay-dead-letters"
+	configPrintCommand       = "config print"
+	indexesReportCommand     = "indexes report"
+	pruneCommand             = "prune"
+	backfillCommand          = "backfill"
+)
+
+// splitCommand splits the command line into the command words, e.g. `config print`, and the flags that follow them
+func splitCommand(args []string) (string, []string) {
+	i := 0
+	for i < len(args) && !strings.HasPrefix(args[i], "-") {
+		i++
+	}
+	return strings.Join(args[:i], " "), args[i:]
+}
+
+func isConfigPrintCommand(command string) bool {
+	return command == configPrintCommand
+}
+
+func runCommand(command string) {
+	switch command {
+	case replayDeadLettersCommand:
+		replayDeadLetters()
+	case indexesReportCommand:
+		reportIndexes()
+	case pruneCommand:
+		pruneOnce()
+	case backfillCommand:
+		backfillArchive()
+	default:
+		slog.Error(fmt.Sprintf("unknown command: '%s'. Supported commands are: %s, %s, %s, %s, %s", command, replayDeadLettersCommand, configPrintCommand, indexesReportCommand, pruneCommand, backfillCommand))
+		os.Exit(2)
+	}
+}
+
+// printConfig prints the effective configuration, with secrets redacted, followed by its validation errors
+func printConfig(loaded *settings.Loaded, err error) {
+	if loaded != nil {
+		printErr := loaded.Print(os.Stdout)
+		if printErr != nil {
+			slog.Error(fmt.Sprintf("failed to print configuration: %s", printErr.Error()))
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+}
+
+func replayDeadLetters() {
+	batchStore := clients.NewGithubStoreClient(config.Current().Mongo)
+	defer batchStore.Close()
+
+	replayed, err := batchStore.ReplayDeadLetters()
+	slog.Info(fmt.Sprintf("replayed %d dead-lettered items", replayed))
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to replay some dead-lettered items: %s", err.Error()))
+		batchStore.Close()
+		os.Exit(1)
+	}
+}
+
+// reportIndexes prints the missing, undeclared and unused indexes of every collection
+func reportIndexes() {
+	batchStore := clients.NewGithubStoreClient(config.Current().Mongo)
+	defer batchStore.Close()
+
+	reports, err := batchStore.IndexReports()
+	for _, report := range reports {
+		fmt.Printf("%s:\n", report.Collection)
+		fmt.Printf("  missing: %s\n", joinOrNone(report.Missing))
+		fmt.Printf("  undeclared: %s\n", joinOrNone(report.Undeclared))
+		unused := make([]string, 0, len(report.Unused))
+		for _, usage := range report.Unused {
+			unused = append(unused, fmt.Sprintf("%s (since %s)", usage.Name, usage.Since.Format(time.RFC3339)))
+		}
+		fmt.Printf("  unused: %s\n", joinOrNone(unused))
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to report indexes: %s", err.Error()))
+		batchStore.Close()
+		os.Exit(1)
+	}
+}
+
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+// pruneOnce runs the retention job once, e.g. `prune --retention-dry-run=true`
+func pruneOnce() {
+	batchStore := clients.NewGithubStoreClient(config.Current().Mongo)
+	defer batchStore.Close()
+
+	job, err := newRetentionJob(batchStore)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to create retention job: %s", err.Error()))
+		batchStore.Close()
+		os.Exit(1)
+	}
+	prune(job, batchStore)
+}
+
+// backfillArchive stores the events of the GH Archive hours of a range, e.g. `backfill --backfill-from=2015-01-01 --backfill-to=2015-01-31`.
+// An interrupted backfill resumes from its progress file when run again.
+func backfillArchive() {
+	options := config.Current().Backfill
+	batchStore := clients.NewGithubStoreClient(config.Current().Mongo)
+	defer batchStore.Close()
+
+	job, err := backfill.NewJob(options, backfill.NewSource(options.Source, options.Timeout), batchStore, filterEvents)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to create backfill: %s", err.Error()))
+		batchStore.Close()
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	result, err := job.Run(ctx)
+	slog.Info(fmt.Sprintf("backfilled %d hours (%d missing) in %s: %d events stored, %d filtered, %d invalid lines",
+		result.Hours, result.Missing, time.Since(start).Round(time.Second), result.Events, result.Filtered, result.Invalid))
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			slog.Warn(fmt.Sprintf("backfill interrupted, run it again to resume from %s", options.ProgressFile))
+		} else {
+			slog.Error(fmt.Sprintf("backfill failed, run it again to resume from %s: %s", options.ProgressFile, err.Error()))
+		}
+		batchStore.Close()
+		os.Exit(1)
+	}
+}
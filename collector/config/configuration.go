@@ -1,90 +1,208 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"log/slog"
-	"os"
-	"strconv"
+	"github-events-microservices/collector/backfill"
+	"github-events-microservices/collector/bots"
+	"github-events-microservices/collector/feeds"
+	"github-events-microservices/collector/filter"
+	"github-events-microservices/collector/sinks"
+	"github-events-microservices/logging"
+	"github-events-microservices/settings"
+	"github-events-microservices/stores"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var CollectorConfiguration *Configuration
+// maxRefreshBatchSize is the maximum number of nodes of a GraphQL query
+const maxRefreshBatchSize = 100
+
+var (
+	// current holds the default configuration until Load is called
+	current  = newCurrent()
+	reloaded = make(chan struct{})
+	// loaded records the values of the current configuration, guarded by reloadMu
+	loaded   *settings.Loaded
+	reloadMu sync.Mutex
+)
 
 type Configuration struct {
-	MongoDbUrl                  string
-	MongoDbPort                 string
-	GitHubGraphQLRequestTimeout time.Duration
-	GitHubToken                 string
-	FetchInterval               time.Duration
-	MaxItems                    int
-	MaxTimeout                  time.Duration
-	EventsDb                    string
-	EventsCollection            string
-	ReposDb                     string
-	ReposCollection             string
-	UsersDb                     string
-	UsersCollection             string
+	Mongo                       stores.MongoOptions
+	GitHubGraphQLRequestTimeout time.Duration `config:"GITHUB_GRAPHQL_REQUEST_TIMEOUT" default:"30" unit:"seconds" min:"1"`
+	GitHubToken                 string        `config:"GITHUB_TOKEN" required:"true" secret:"true" reload:"true"`
+	FetchInterval               time.Duration `config:"FETCH_INTERVAL_MINUTES" default:"1" unit:"minutes" min:"1" reload:"true"`
+	MaxItems                    int           `config:"MAX_ITEMS" default:"3" min:"1" reload:"true"`
+	MaxTimeout                  time.Duration `config:"MAX_TIMEOUT_SECONDS" default:"10" unit:"seconds" min:"1" reload:"true"`
+	Feeds                       string        `config:"FEEDS" default:"public" reload:"true"`
+	FeedMaxRequests             int           `config:"FEED_MAX_REQUESTS" default:"10" min:"1" reload:"true"`
+	FeedMaxPages                int           `config:"FEED_MAX_PAGES" default:"3" min:"1" reload:"true"`
+	WebhookPort                 string        `config:"WEBHOOK_PORT"`
+	WebhookPath                 string        `config:"WEBHOOK_PATH" default:"/webhooks/github" required:"true"`
+	WebhookSecret               string        `config:"WEBHOOK_SECRET" secret:"true" reload:"true"`
+	EventsInclude               string        `config:"EVENTS_INCLUDE" reload:"true"`
+	EventsExclude               string        `config:"EVENTS_EXCLUDE" reload:"true"`
+	EventsExcludeBots           bool          `config:"EVENTS_EXCLUDE_BOTS" reload:"true"`
+	EventsDb                    string        `config:"EVENTS_DB" default:"github" required:"true"`
+	EventsCollection            string        `config:"EVENTS_COLLECTION" default:"events" required:"true"`
+	ReposDb                     string        `config:"REPOS_DB" default:"github" required:"true"`
+	ReposCollection             string        `config:"REPOS_COLLECTION" default:"repos" required:"true"`
+	UsersDb                     string        `config:"USERS_DB" default:"github" required:"true"`
+	UsersCollection             string        `config:"USERS_COLLECTION" default:"users" required:"true"`
+	DeadLettersDb               string        `config:"DEAD_LETTERS_DB" default:"github" required:"true"`
+	DeadLettersCollection       string        `config:"DEAD_LETTERS_COLLECTION" default:"dead_letters" required:"true"`
+	DeadLettersTTL              time.Duration `config:"DEAD_LETTERS_TTL_DAYS" default:"0" unit:"days" min:"0"`
+	RulesDb                     string        `config:"RULES_DB" default:"github" required:"true"`
+	RulesCollection             string        `config:"RULES_COLLECTION" default:"rules" required:"true"`
+	MetaDb                      string        `config:"META_DB" default:"github" required:"true"`
+	MetaCollection              string        `config:"META_COLLECTION" default:"meta" required:"true"`
+	RulesReloadInterval         time.Duration `config:"RULES_RELOAD_SECONDS" default:"30" unit:"seconds" min:"1" reload:"true"`
+	AlertMaxAttempts            int           `config:"ALERT_MAX_ATTEMPTS" default:"5" min:"1" reload:"true"`
+	AlertTimeout                time.Duration `config:"ALERT_TIMEOUT_SECONDS" default:"10" unit:"seconds" min:"1" reload:"true"`
+	AlertInitialBackoff         time.Duration `config:"ALERT_INITIAL_BACKOFF_MS" default:"1000" unit:"ms" min:"0" reload:"true"`
+	EnsureIndexes               bool          `config:"ENSURE_INDEXES" default:"true"`
+	EventsRetention             time.Duration `config:"EVENTS_RETENTION_DAYS" default:"0" unit:"days" min:"0"`
+	ReposRetention              time.Duration `config:"REPOS_RETENTION_DAYS" default:"0" unit:"days" min:"0"`
+	UsersRetention              time.Duration `config:"USERS_RETENTION_DAYS" default:"0" unit:"days" min:"0"`
+	RetentionInterval           time.Duration `config:"RETENTION_INTERVAL_MINUTES" default:"60" unit:"minutes" min:"1"`
+	RetentionArchiveDir         string        `config:"RETENTION_ARCHIVE_DIR"`
+	RetentionDryRun             bool          `config:"RETENTION_DRY_RUN"`
+	RefreshAfter                time.Duration `config:"REFRESH_AFTER_DAYS" default:"0" unit:"days" min:"0"`
+	RefreshInterval             time.Duration `config:"REFRESH_INTERVAL_MINUTES" default:"10" unit:"minutes" min:"1" reload:"true"`
+	RefreshBatchSize            int           `config:"REFRESH_BATCH_SIZE" default:"50" min:"1" reload:"true"`
+	RefreshMaxBatches           int           `config:"REFRESH_MAX_BATCHES" default:"20" min:"1" reload:"true"`
+	RefreshRateLimitReserve     int           `config:"REFRESH_RATE_LIMIT_RESERVE" default:"1000" min:"0" reload:"true"`
+	RetryMaxAttempts            int           `config:"RETRY_MAX_ATTEMPTS" default:"5" min:"1"`
+	RetryInitialBackoff         time.Duration `config:"RETRY_INITIAL_BACKOFF_MS" default:"200" unit:"ms" min:"0"`
+	RetryMaxBackoff             time.Duration `config:"RETRY_MAX_BACKOFF_MS" default:"10000" unit:"ms" min:"0"`
+	AdminPort                   string        `config:"ADMIN_PORT" default:"9090" required:"true"`
+	ReadinessStaleIntervals     int           `config:"READINESS_STALE_INTERVALS" default:"5" min:"1"`
+	TracesExporter              string        `config:"TRACES_EXPORTER" default:"none" oneof:"otlp|stdout|none"`
+	Logging                     logging.Options
+	Sink                        sinks.Options
+	Bots                        bots.Options
+	Backfill                    backfill.Options
+	QueueDir                    string `config:"QUEUE_DIR" default:"./queue" required:"true"`
+	QueueMaxBytes               int64  `config:"QUEUE_MAX_SIZE_MB" default:"512" unit:"mb" min:"1"`
+	QueueSegmentBytes           int64  `config:"QUEUE_SEGMENT_SIZE_MB" default:"8" unit:"mb" min:"1"`
+	// eventsFilter is built from the event filters once per load or reload, rather than for every batch
+	eventsFilter *filter.Filter
 }
 
-func init() {
-	CollectorConfiguration = &Configuration{
-		MongoDbUrl:                  getOrDefault(mongoDbUrlKey, defaultMongodbUrl),
-		MongoDbPort:                 getOrDefault(mongoDbPortKey, defaultMongoDbPort),
-		GitHubGraphQLRequestTimeout: getGitHubGraphQLRequestTimeout(),
-		GitHubToken:                 getToken(),
-		FetchInterval:               getFetchInterval(),
-		MaxItems:                    getAsInt(maxItemsKey, defaultMaxItems),
-		MaxTimeout:                  getMaxTimeout(),
-		EventsDb:                    getOrDefault(eventsDbKey, defaultDb),
-		EventsCollection:            getOrDefault(eventsCollectionKey, defaultEventsCollection),
-		ReposDb:                     getOrDefault(reposDbKey, defaultDb),
-		ReposCollection:             getOrDefault(reposCollectionKey, defaultReposCollection),
-		UsersDb:                     getOrDefault(usersDbKey, defaultDb),
-		UsersCollection:             getOrDefault(usersCollectionKey, defaultUsersCollection),
-	}
+// EventsFilter returns the filter of EVENTS_INCLUDE, EVENTS_EXCLUDE and EVENTS_EXCLUDE_BOTS
+func (receiver *Configuration) EventsFilter() *filter.Filter {
+	return receiver.eventsFilter
 }
 
-func getToken() string {
-	value := os.Getenv(gitHubToken)
-	if len(value) == 0 {
-		slog.Error(fmt.Sprintf("Please provide a valid token using th ENV variable: '%s'", gitHubToken))
-		os.Exit(1)
-	}
-	return value
+// Current returns the configuration in use. The returned configuration must not be modified.
+// Fields tagged reload:"true" may change on Reload, so long-running loops should read them on every iteration.
+func Current() *Configuration {
+	return current.Load()
 }
 
-func getGitHubGraphQLRequestTimeout() time.Duration {
-	fetchInterval := getAsInt(gitHubGraphQLRequestTimeoutSeconds, defaultGraphQLRequestTimeoutSeconds)
-	return time.Duration(fetchInterval) * time.Second
+// Reloaded returns a channel closed on the next applied reload
+func Reloaded() <-chan struct{} {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return reloaded
 }
 
-func getMaxTimeout() time.Duration {
-	fetchInterval := getAsInt(maxTimeoutSecondsKey, defaultMaxTimeout)
-	return time.Duration(fetchInterval) * time.Second
+// Load loads the configuration from the configuration file, the env variables and the flags in args.
+// The configuration is only applied when it is valid.
+func Load(args []string) (*settings.Loaded, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	configuration := &Configuration{}
+	next, err := settings.Load(configuration, args)
+	if err != nil {
+		return next, err
+	}
+	err = configuration.buildEventsFilter()
+	if err != nil {
+		return next, err
+	}
+	loaded = next
+	current.Store(configuration)
+	return next, nil
 }
 
-func getFetchInterval() time.Duration {
-	fetchInterval := getAsInt(fetchIntervalKey, defaultFetchInterval)
-	return time.Duration(fetchInterval) * time.Minute
+// Reload loads the configuration again and applies the changed reloadable values.
+// It returns all the changes, the ones that require a restart are not applied.
+func Reload(args []string) ([]settings.Change, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	if loaded == nil {
+		return nil, errors.New("configuration was not loaded")
+	}
+
+	next := &Configuration{}
+	nextLoaded, err := settings.Load(next, args)
+	if err != nil {
+		return nil, err
+	}
+	changes := loaded.Diff(nextLoaded)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	applied := *Current()
+	err = settings.CopyReloadable(&applied, next)
+	if err != nil {
+		return nil, err
+	}
+	err = applied.buildEventsFilter()
+	if err != nil {
+		return nil, err
+	}
+	loaded = loaded.Reloaded(nextLoaded)
+	current.Store(&applied)
+	close(reloaded)
+	reloaded = make(chan struct{})
+	return changes, nil
 }
 
-func getOrDefault(key string, defaultValue string) string {
-	value := os.Getenv(key)
-	if len(value) == 0 {
-		return defaultValue
+func (receiver *Configuration) Validate() error {
+	var errs []error
+	errs = append(errs, receiver.Logging.Validate(), receiver.Mongo.Validate(), receiver.Sink.Validate(), receiver.Bots.Validate(), receiver.Backfill.Validate())
+	if receiver.RetryInitialBackoff > receiver.RetryMaxBackoff {
+		errs = append(errs, fmt.Errorf("RETRY_INITIAL_BACKOFF_MS (%d) must not exceed RETRY_MAX_BACKOFF_MS (%d)", receiver.RetryInitialBackoff.Milliseconds(), receiver.RetryMaxBackoff.Milliseconds()))
+	}
+	_, err := feeds.Parse(receiver.Feeds)
+	errs = append(errs, err)
+	_, err = filter.New(receiver.EventsInclude, receiver.EventsExclude, receiver.EventsExcludeBots)
+	errs = append(errs, err)
+	if len(receiver.WebhookPort) > 0 && len(receiver.WebhookSecret) == 0 {
+		errs = append(errs, errors.New("WEBHOOK_SECRET is required when WEBHOOK_PORT is set"))
 	}
-	return value
+	if receiver.RefreshBatchSize > maxRefreshBatchSize {
+		errs = append(errs, fmt.Errorf("REFRESH_BATCH_SIZE (%d) must not exceed %d", receiver.RefreshBatchSize, maxRefreshBatchSize))
+	}
+	if receiver.QueueSegmentBytes >= receiver.QueueMaxBytes {
+		errs = append(errs, errors.New("QUEUE_SEGMENT_SIZE_MB must be less than QUEUE_MAX_SIZE_MB"))
+	}
+	if receiver.Sink.Type != sinks.TypeNone && receiver.QueueSegmentBytes >= receiver.Sink.QueueMaxBytes {
+		errs = append(errs, errors.New("QUEUE_SEGMENT_SIZE_MB must be less than SINK_QUEUE_MAX_SIZE_MB"))
+	}
+	return errors.Join(errs...)
 }
 
-func getAsInt(key string, defaultValue int) int {
-	valueString := os.Getenv(key)
-	if len(valueString) == 0 {
-		return defaultValue
+func (receiver *Configuration) buildEventsFilter() error {
+	eventsFilter, err := filter.New(receiver.EventsInclude, receiver.EventsExclude, receiver.EventsExcludeBots)
+	if err != nil {
+		return err
 	}
-	value, err := strconv.Atoi(valueString)
+	receiver.eventsFilter = eventsFilter
+	return nil
+}
+
+func newCurrent() *atomic.Pointer[Configuration] {
+	configuration := &Configuration{}
+	err := errors.Join(settings.Defaults(configuration), configuration.buildEventsFilter())
 	if err != nil {
-		slog.Error(fmt.Sprintf("Invalid max items value: %s", valueString))
-		os.Exit(1)
+		panic(err)
 	}
-	return value
+	var pointer atomic.Pointer[Configuration]
+	pointer.Store(configuration)
+	return &pointer
 }
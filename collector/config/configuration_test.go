@@ -0,0 +1,110 @@
+package config
+
+import (
+	"github-events-microservices/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	file := filepath.Join(t.TempDir(), "collector.yaml")
+	_ = os.WriteFile(file, []byte("github_token: first\nmax_items: 10\nmongo_db_port: 27017\n"), 0666)
+	args := []string{"--config", file}
+	_, err := Load(args)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	reloaded := Reloaded()
+
+	_ = os.WriteFile(file, []byte("github_token: second\nmax_items: 20\nmax_timeout_seconds: 30\nmongo_db_port: 27018\n"), 0666)
+	changes, err := Reload(args)
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	wantChanges := map[string]bool{"GITHUB_TOKEN": true, "MAX_ITEMS": true, "MAX_TIMEOUT_SECONDS": true, "MONGO_DB_PORT": false}
+	if len(changes) != len(wantChanges) {
+		t.Fatalf("Reload() changes = %v, want %v", changes, wantChanges)
+	}
+	for _, change := range changes {
+		reloadable, ok := wantChanges[change.Key]
+		if !ok || change.Reloadable != reloadable {
+			t.Errorf("unexpected change %v, reloadable: %v", change, change.Reloadable)
+		}
+		if change.Key == "GITHUB_TOKEN" && (change.Old == "first" || change.New == "second") {
+			t.Errorf("token change isn't redacted: %v", change)
+		}
+	}
+
+	configuration := Current()
+	if configuration.GitHubToken != "second" || configuration.MaxItems != 20 || configuration.MaxTimeout != 30*time.Second {
+		t.Errorf("reloadable values weren't applied: %+v", configuration)
+	}
+	if configuration.Mongo.Port != "27017" {
+		t.Errorf("Mongo.Port = %s, want the value loaded at startup", configuration.Mongo.Port)
+	}
+	select {
+	case <-reloaded:
+	default:
+		t.Errorf("Reloaded() channel wasn't closed")
+	}
+
+	// changes that require a restart are reported until the restart
+	changes, err = Reload(args)
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].Key != "MONGO_DB_PORT" {
+		t.Errorf("Reload() changes = %v, want MONGO_DB_PORT only", changes)
+	}
+}
+
+func TestReload_eventsFilter(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	file := filepath.Join(t.TempDir(), "collector.yaml")
+	_ = os.WriteFile(file, []byte("github_token: first\nevents_exclude: type:WatchEvent\n"), 0666)
+	args := []string{"--config", file}
+	_, err := Load(args)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	events := []model.Event{{ID: "1", Type: "WatchEvent"}, {ID: "2", Type: "PushEvent"}}
+	eventsFilter := Current().EventsFilter()
+	if kept := eventsFilter.Apply(events); len(kept) != 1 || kept[0].ID != "2" {
+		t.Errorf("expected the loaded filter to drop the WatchEvent, got: %+v", kept)
+	}
+
+	_ = os.WriteFile(file, []byte("github_token: first\nevents_exclude: type:PushEvent\n"), 0666)
+	_, err = Reload(args)
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if kept := Current().EventsFilter().Apply(events); len(kept) != 1 || kept[0].ID != "1" {
+		t.Errorf("expected the reloaded filter to drop the PushEvent, got: %+v", kept)
+	}
+	if kept := eventsFilter.Apply(events); len(kept) != 1 || kept[0].ID != "2" {
+		t.Errorf("expected the previous filter to be left unchanged, got: %+v", kept)
+	}
+}
+
+func TestReload_Invalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "collector.yaml")
+	_ = os.WriteFile(file, []byte("github_token: token\nmax_items: 10\n"), 0666)
+	args := []string{"--config", file}
+	_, err := Load(args)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	_ = os.WriteFile(file, []byte("github_token: token\nmax_items: 0\n"), 0666)
+	_, err = Reload(args)
+	if err == nil {
+		t.Fatalf("Reload() error = nil, want an error")
+	}
+	if Current().MaxItems != 10 {
+		t.Errorf("MaxItems = %d, want the previous value", Current().MaxItems)
+	}
+}
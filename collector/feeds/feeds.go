@@ -0,0 +1,105 @@
+package feeds
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/go-github/v57/github"
+	"strings"
+	"time"
+)
+
+const (
+	KindPublic  = "public"
+	KindOrg     = "org"
+	KindRepo    = "repo"
+	KindUser    = "user"
+	KindNetwork = "network"
+)
+
+// Feed is a GitHub events feed: the public feed, or the feed of an org, repo, user or repo network
+type Feed struct {
+	Kind string
+	// Name is the org or user login, or the owner/name of a repo
+	Name string
+}
+
+// Page is a page of an events feed, with the polling hints of the response
+type Page struct {
+	Events       []*github.Event
+	ETag         string
+	NotModified  bool
+	PollInterval time.Duration
+	NextPage     int
+}
+
+// String is the feed as configured, e.g. repo:golang/go
+func (receiver Feed) String() string {
+	if receiver.Kind == KindPublic {
+		return KindPublic
+	}
+	return receiver.Kind + ":" + receiver.Name
+}
+
+// Path is the events api path of the feed, relative to the api base url
+func (receiver Feed) Path() string {
+	switch receiver.Kind {
+	case KindOrg:
+		return fmt.Sprintf("orgs/%s/events", receiver.Name)
+	case KindRepo:
+		return fmt.Sprintf("repos/%s/events", receiver.Name)
+	case KindUser:
+		return fmt.Sprintf("users/%s/events", receiver.Name)
+	case KindNetwork:
+		return fmt.Sprintf("networks/%s/events", receiver.Name)
+	default:
+		return "events"
+	}
+}
+
+// Parse parses comma separated feeds, e.g. public,org:kubernetes,repo:golang/go,user:torvalds,network:golang/go.
+// Duplicated feeds are ignored.
+func Parse(value string) ([]Feed, error) {
+	var parsed []Feed
+	var errs []error
+	seen := make(map[Feed]bool)
+	for _, text := range strings.Split(value, ",") {
+		text = strings.TrimSpace(text)
+		if len(text) == 0 {
+			continue
+		}
+		feed, err := parseFeed(text)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !seen[feed] {
+			seen[feed] = true
+			parsed = append(parsed, feed)
+		}
+	}
+	if len(parsed) == 0 && len(errs) == 0 {
+		errs = append(errs, errors.New("at least one events feed is required"))
+	}
+	return parsed, errors.Join(errs...)
+}
+
+func parseFeed(text string) (Feed, error) {
+	if text == KindPublic {
+		return Feed{Kind: KindPublic}, nil
+	}
+	kind, name, _ := strings.Cut(text, ":")
+	// org and user names are case insensitive, so are the feeds
+	feed := Feed{Kind: kind, Name: strings.ToLower(name)}
+	switch kind {
+	case KindOrg, KindUser:
+		if len(name) > 0 && !strings.Contains(name, "/") {
+			return feed, nil
+		}
+	case KindRepo, KindNetwork:
+		owner, repo, ok := strings.Cut(name, "/")
+		if ok && len(owner) > 0 && len(repo) > 0 && !strings.Contains(repo, "/") {
+			return feed, nil
+		}
+	}
+	return feed, fmt.Errorf("invalid events feed: '%s'. Expected public, org:<org>, repo:<owner>/<repo>, user:<user> or network:<owner>/<repo>", text)
+}
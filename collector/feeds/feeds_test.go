@@ -0,0 +1,63 @@
+package feeds
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []Feed
+		wantErr bool
+	}{
+		{
+			name:  "all kinds",
+			value: "public, org:Kubernetes,repo:golang/go,user:torvalds,network:golang/go",
+			want: []Feed{
+				{Kind: KindPublic},
+				{Kind: KindOrg, Name: "kubernetes"},
+				{Kind: KindRepo, Name: "golang/go"},
+				{Kind: KindUser, Name: "torvalds"},
+				{Kind: KindNetwork, Name: "golang/go"},
+			},
+		},
+		{name: "duplicates", value: "org:golang,org:Golang", want: []Feed{{Kind: KindOrg, Name: "golang"}}},
+		{name: "empty", value: " ", wantErr: true},
+		{name: "unknown kind", value: "team:core", wantErr: true},
+		{name: "repo without owner", value: "repo:go", wantErr: true},
+		{name: "org with slash", value: "org:golang/go", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeed_Path(t *testing.T) {
+	tests := []struct {
+		feed Feed
+		want string
+	}{
+		{feed: Feed{Kind: KindPublic}, want: "events"},
+		{feed: Feed{Kind: KindOrg, Name: "golang"}, want: "orgs/golang/events"},
+		{feed: Feed{Kind: KindRepo, Name: "golang/go"}, want: "repos/golang/go/events"},
+		{feed: Feed{Kind: KindUser, Name: "torvalds"}, want: "users/torvalds/events"},
+		{feed: Feed{Kind: KindNetwork, Name: "golang/go"}, want: "networks/golang/go/events"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.feed.String(), func(t *testing.T) {
+			if got := tt.feed.Path(); got != tt.want {
+				t.Errorf("Path() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"github-events-microservices/collector/metrics"
+	"github-events-microservices/model"
+	"path"
+	"strings"
+)
+
+const (
+	FieldOrg   = "org"
+	FieldRepo  = "repo"
+	FieldType  = "type"
+	FieldActor = "actor"
+
+	botsRule  = "exclude bots"
+	botSuffix = "[bot]"
+)
+
+var fields = []string{FieldOrg, FieldRepo, FieldType, FieldActor}
+
+// Filter selects the collected events. Include rules of the same field match any of their patterns, and an event must
+// match the include rules of every field that has some. Events matching any exclude rule are dropped.
+type Filter struct {
+	include     map[string][]rule
+	exclude     []rule
+	excludeBots bool
+}
+
+// rule matches a field of the event against a glob pattern, e.g. repo:golang/*
+type rule struct {
+	text    string
+	field   string
+	pattern string
+}
+
+func (receiver rule) matches(event model.Event) bool {
+	value := fieldValue(event, receiver.field)
+	if receiver.field != FieldType {
+		// org, repo and user names are case insensitive
+		value = strings.ToLower(value)
+	}
+	matched, _ := path.Match(receiver.pattern, value)
+	return matched
+}
+
+// Apply returns the events to collect and counts the dropped ones per rule
+func (receiver *Filter) Apply(events []model.Event) []model.Event {
+	if receiver.empty() {
+		return events
+	}
+	kept := make([]model.Event, 0, len(events))
+	for _, event := range events {
+		droppedBy, ok := receiver.Match(event)
+		if !ok {
+			metrics.EventsFiltered.WithLabelValues(droppedBy).Inc()
+			continue
+		}
+		kept = append(kept, event)
+	}
+	return kept
+}
+
+// Match reports whether the event is collected, or else the rule that drops it
+func (receiver *Filter) Match(event model.Event) (string, bool) {
+	if receiver.excludeBots && strings.HasSuffix(strings.ToLower(event.ActorLogin), botSuffix) {
+		return botsRule, false
+	}
+	for _, excludeRule := range receiver.exclude {
+		if excludeRule.matches(event) {
+			return "exclude " + excludeRule.text, false
+		}
+	}
+	for _, field := range fields {
+		includeRules, ok := receiver.include[field]
+		if ok && !matchesAny(includeRules, event) {
+			return "include " + field, false
+		}
+	}
+	return "", true
+}
+
+func (receiver *Filter) empty() bool {
+	return len(receiver.include) == 0 && len(receiver.exclude) == 0 && !receiver.excludeBots
+}
+
+func matchesAny(rules []rule, event model.Event) bool {
+	for _, includeRule := range rules {
+		if includeRule.matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldValue(event model.Event, field string) string {
+	switch field {
+	case FieldOrg:
+		owner, _, _ := strings.Cut(event.RepoFullName, "/")
+		return owner
+	case FieldRepo:
+		return event.RepoFullName
+	case FieldType:
+		return event.Type
+	default:
+		return event.ActorLogin
+	}
+}
+
+// parseRules parses comma separated <field>:<glob> rules, e.g. org:kubernetes,type:PushEvent
+func parseRules(value string) ([]rule, error) {
+	var rules []rule
+	var errs []error
+	if len(strings.TrimSpace(value)) == 0 {
+		return rules, nil
+	}
+	for _, text := range strings.Split(value, ",") {
+		text = strings.TrimSpace(text)
+		field, pattern, ok := strings.Cut(text, ":")
+		if !ok || len(pattern) == 0 || !isField(field) {
+			errs = append(errs, fmt.Errorf("invalid event filter rule: '%s'. Expected <%s>:<pattern>", text, strings.Join(fields, "|")))
+			continue
+		}
+		if field != FieldType {
+			pattern = strings.ToLower(pattern)
+		}
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid event filter pattern: '%s': %w", text, err))
+			continue
+		}
+		rules = append(rules, rule{text: text, field: field, pattern: pattern})
+	}
+	return rules, errors.Join(errs...)
+}
+
+func isField(field string) bool {
+	for _, known := range fields {
+		if field == known {
+			return true
+		}
+	}
+	return false
+}
+
+// New parses the include and exclude rules. excludeBots drops the events of actors whose login ends with [bot].
+func New(include string, exclude string, excludeBots bool) (*Filter, error) {
+	includeRules, includeErr := parseRules(include)
+	excludeRules, excludeErr := parseRules(exclude)
+	err := errors.Join(includeErr, excludeErr)
+	if err != nil {
+		return nil, err
+	}
+
+	includeByField := make(map[string][]rule)
+	for _, includeRule := range includeRules {
+		includeByField[includeRule.field] = append(includeByField[includeRule.field], includeRule)
+	}
+	return &Filter{include: includeByField, exclude: excludeRules, excludeBots: excludeBots}, nil
+}
@@ -0,0 +1,78 @@
+package filter
+
+import (
+	"github-events-microservices/model"
+	"reflect"
+	"testing"
+)
+
+func TestFilter_Match(t *testing.T) {
+	pushEvent := model.Event{Type: "PushEvent", RepoFullName: "Kubernetes/kubectl", ActorLogin: "alice"}
+	botEvent := model.Event{Type: "PushEvent", RepoFullName: "golang/go", ActorLogin: "dependabot[bot]"}
+	watchEvent := model.Event{Type: "WatchEvent", RepoFullName: "golang/tools", ActorLogin: "bob"}
+	tests := []struct {
+		name        string
+		include     string
+		exclude     string
+		excludeBots bool
+		event       model.Event
+		want        string
+		wantOk      bool
+	}{
+		{name: "no rules", event: pushEvent, wantOk: true},
+		{name: "included org, case insensitive", include: "org:kubernetes", event: pushEvent, wantOk: true},
+		{name: "not included org", include: "org:golang", event: pushEvent, want: "include org"},
+		{name: "repo glob", include: "repo:golang/*", event: watchEvent, wantOk: true},
+		{name: "same field rules match any", include: "type:PushEvent,type:WatchEvent", event: watchEvent, wantOk: true},
+		{name: "all fields must match", include: "repo:golang/*,type:PushEvent", event: watchEvent, want: "include type"},
+		{name: "excluded type", exclude: "type:WatchEvent", event: watchEvent, want: "exclude type:WatchEvent"},
+		{name: "exclude wins over include", include: "org:golang", exclude: "actor:bob", event: watchEvent, want: "exclude actor:bob"},
+		{name: "bots", excludeBots: true, event: botEvent, want: "exclude bots"},
+		{name: "bots kept", event: botEvent, wantOk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := New(tt.include, tt.exclude, tt.excludeBots)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			got, ok := filter.Match(tt.event)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Match() got = %s, %v, want %s, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestFilter_Apply(t *testing.T) {
+	events := []model.Event{{ID: "1", Type: "PushEvent"}, {ID: "2", Type: "WatchEvent"}, {ID: "3", Type: "PushEvent"}}
+	filter, err := New("", "type:WatchEvent", false)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	want := []model.Event{events[0], events[2]}
+	got := filter.Apply(events)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply() got = %v, want %v", got, want)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		include string
+		exclude string
+	}{
+		{name: "unknown field", include: "branch:main"},
+		{name: "missing pattern", exclude: "repo:"},
+		{name: "invalid glob", include: "repo:golang/[go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.include, tt.exclude, false)
+			if err == nil {
+				t.Errorf("New() expected an error")
+			}
+		})
+	}
+}
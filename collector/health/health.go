@@ -0,0 +1,169 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOk          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// State tracks the progress of the collector pipeline, as reported by the health endpoints
+type State struct {
+	mu                 sync.RWMutex
+	startedAt          time.Time
+	lastFetch          time.Time
+	lastStore          time.Time
+	tokenValid         *bool
+	rateLimitLimit     int
+	rateLimitRemaining int
+	rateLimitReset     time.Time
+}
+
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+type Check struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status           string           `json:"status"`
+	Checks           map[string]Check `json:"checks"`
+	LastFetch        *time.Time       `json:"last_fetch"`
+	LastStore        *time.Time       `json:"last_store"`
+	GitHubTokenValid *bool            `json:"github_token_valid"`
+	RateLimit        RateLimit        `json:"rate_limit"`
+}
+
+// Pinger is a dependency whose availability is reported by the readiness endpoint
+type Pinger interface {
+	Ping() error
+}
+
+var CollectorState = NewState()
+
+func (receiver *State) RecordFetch() {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.lastFetch = time.Now()
+}
+
+func (receiver *State) RecordStore() {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.lastStore = time.Now()
+}
+
+func (receiver *State) RecordTokenValidity(valid bool) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.tokenValid = &valid
+}
+
+func (receiver *State) RecordRateLimit(limit int, remaining int, reset time.Time) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.rateLimitLimit = limit
+	receiver.rateLimitRemaining = remaining
+	receiver.rateLimitReset = reset
+}
+
+// Report checks the dependencies and fails when nothing was stored within staleAfter (since start up, before the first store)
+func (receiver *State) Report(dependencies map[string]Pinger, staleAfter time.Duration) Report {
+	// the state is copied under the lock, so a slow ping doesn't block the recording of the fetches and stores
+	receiver.mu.RLock()
+	report := Report{
+		Status:           StatusOk,
+		Checks:           make(map[string]Check),
+		LastFetch:        optionalTime(receiver.lastFetch),
+		LastStore:        optionalTime(receiver.lastStore),
+		GitHubTokenValid: receiver.tokenValid,
+		RateLimit: RateLimit{
+			Limit:     receiver.rateLimitLimit,
+			Remaining: receiver.rateLimitRemaining,
+			Reset:     receiver.rateLimitReset,
+		},
+	}
+	lastProgress := receiver.lastStore
+	if lastProgress.IsZero() {
+		lastProgress = receiver.startedAt
+	}
+	receiver.mu.RUnlock()
+
+	for name, dependency := range dependencies {
+		err := dependency.Ping()
+		if err != nil {
+			report.Checks[name] = Check{Status: StatusUnavailable, Error: err.Error()}
+			report.Status = StatusUnavailable
+		} else {
+			report.Checks[name] = Check{Status: StatusOk}
+		}
+	}
+
+	if time.Since(lastProgress) > staleAfter {
+		report.Checks["store"] = Check{Status: StatusUnavailable, Error: fmt.Sprintf("nothing was stored for %s", time.Since(lastProgress).Round(time.Second))}
+		report.Status = StatusUnavailable
+	} else {
+		report.Checks["store"] = Check{Status: StatusOk}
+	}
+
+	if report.GitHubTokenValid != nil && !*report.GitHubTokenValid {
+		report.Checks["github_token"] = Check{Status: StatusUnavailable, Error: "github token was rejected"}
+		report.Status = StatusUnavailable
+	}
+	return report
+}
+
+// LivenessHandler reports that the process is up
+func LivenessHandler(writer http.ResponseWriter, _ *http.Request) {
+	writeReport(writer, http.StatusOK, map[string]string{"status": StatusOk})
+}
+
+// ReadinessHandler reports the collector state and fails with 503 when a dependency is down or the pipeline is stale.
+// staleAfter is evaluated on every request, as it depends on reloadable intervals.
+func ReadinessHandler(state *State, dependencies map[string]Pinger, staleAfter func() time.Duration) http.HandlerFunc {
+	return func(writer http.ResponseWriter, _ *http.Request) {
+		report := state.Report(dependencies, staleAfter())
+		status := http.StatusOK
+		if report.Status != StatusOk {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(writer, status, report)
+	}
+}
+
+func writeReport(writer http.ResponseWriter, status int, report interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	bytes, err := json.Marshal(report)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to serialize health report: %s", err.Error()))
+		return
+	}
+	_, err = writer.Write(bytes)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to write health report: %s", err.Error()))
+	}
+}
+
+func optionalTime(value time.Time) *time.Time {
+	if value.IsZero() {
+		return nil
+	}
+	return &value
+}
+
+func NewState() *State {
+	return &State{startedAt: time.Now()}
+}
@@ -0,0 +1,98 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type pingerFunc func() error
+
+func (receiver pingerFunc) Ping() error {
+	return receiver()
+}
+
+func TestReadinessHandler(t *testing.T) {
+	healthyMongo := map[string]Pinger{"mongo": pingerFunc(func() error { return nil })}
+	unhealthyMongo := map[string]Pinger{"mongo": pingerFunc(func() error { return errors.New("connection refused") })}
+
+	tests := []struct {
+		name         string
+		state        func() *State
+		dependencies map[string]Pinger
+		wantCode     int
+	}{
+		{
+			name:         "ready within the start up grace period",
+			state:        NewState,
+			dependencies: healthyMongo,
+			wantCode:     http.StatusOK,
+		},
+		{
+			name: "ready after a recent store",
+			state: func() *State {
+				state := &State{startedAt: time.Now().Add(-time.Hour)}
+				state.RecordStore()
+				return state
+			},
+			dependencies: healthyMongo,
+			wantCode:     http.StatusOK,
+		},
+		{
+			name:         "not ready when nothing was stored",
+			state:        func() *State { return &State{startedAt: time.Now().Add(-time.Hour)} },
+			dependencies: healthyMongo,
+			wantCode:     http.StatusServiceUnavailable,
+		},
+		{
+			name:         "not ready when mongo is down",
+			state:        NewState,
+			dependencies: unhealthyMongo,
+			wantCode:     http.StatusServiceUnavailable,
+		},
+		{
+			name: "not ready when the token was rejected",
+			state: func() *State {
+				state := NewState()
+				state.RecordTokenValidity(false)
+				return state
+			},
+			dependencies: healthyMongo,
+			wantCode:     http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ReadinessHandler(tt.state(), tt.dependencies, func() time.Duration { return time.Minute })(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if recorder.Code != tt.wantCode {
+				t.Errorf("expected: %d, got: %d (%s)", tt.wantCode, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestState_Report_slowPing(t *testing.T) {
+	state := NewState()
+	recorded := make(chan struct{})
+	// a store recorded while mongo is pinged must not wait for the ping
+	slowMongo := map[string]Pinger{"mongo": pingerFunc(func() error {
+		go func() {
+			state.RecordStore()
+			close(recorded)
+		}()
+		select {
+		case <-recorded:
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("ping timed out")
+		}
+	})}
+
+	report := state.Report(slowMongo, time.Minute)
+	if report.Checks["mongo"].Status != StatusOk {
+		t.Errorf("expected the store to be recorded during the ping, got: %+v", report.Checks["mongo"])
+	}
+}
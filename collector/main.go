@@ -1,98 +1,468 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"github-events-microservices/collector/bots"
 	"github-events-microservices/collector/clients"
 	"github-events-microservices/collector/config"
+	"github-events-microservices/collector/health"
+	"github-events-microservices/collector/metrics"
+	"github-events-microservices/collector/queue"
+	"github-events-microservices/collector/rules"
+	"github-events-microservices/collector/sinks"
+	"github-events-microservices/collector/webhooks"
 	"github-events-microservices/logging"
 	"github-events-microservices/model"
+	"github-events-microservices/stores"
+	"github-events-microservices/tracing"
 	"github.com/google/go-github/v57/github"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
+const shutdownTimeout = 10 * time.Second
+
+var tracer = otel.Tracer("github-events-microservices/collector")
+
 func main() {
-	slog.SetDefault(logging.Create())
+	command, args := splitCommand(os.Args[1:])
+	loaded, err := config.Load(args)
+	if isConfigPrintCommand(command) {
+		printConfig(loaded, err)
+		return
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(2)
+	}
+
+	logger, err := logging.Create(config.Current().Logging)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to create logger: %s", err.Error()))
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+	if len(command) > 0 {
+		runCommand(command)
+		return
+	}
 	slog.Info("Github Events Collector started")
+	hangups := notifyHangups()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, "events-collector", config.Current().TracesExporter)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to setup tracing: %s", err.Error()))
+		os.Exit(1)
+	}
 
 	gitHubClient := newGitHubClient()
-	batchStore := clients.NewGithubStoreClient(config.CollectorConfiguration.MongoDbUrl, config.CollectorConfiguration.MongoDbPort)
-	eventsChannel := make(chan model.Event)
+	batchStore := clients.NewGithubStoreClient(config.Current().Mongo)
+	if config.Current().EnsureIndexes {
+		err = batchStore.EnsureIndexes()
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to ensure indexes: %s", err.Error()))
+		}
+	}
+	eventsQueue, err := queue.NewDiskQueue(config.Current().QueueDir, config.Current().QueueSegmentBytes, config.Current().QueueMaxBytes)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to open events queue: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	stores.DuplicatesOmittedHandler = func(collection string, count int) {
+		metrics.DuplicatesSkipped.WithLabelValues(collection).Add(float64(count))
+	}
+	eventSink, err := sinks.New(config.Current().Sink)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to create sink: %s", err.Error()))
+		os.Exit(1)
+	}
+	sinkQueue, err := newSinkQueue(eventSink)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to open sink queue: %s", err.Error()))
+		os.Exit(1)
+	}
+	reportQueueStats(eventsQueue)
+	adminServer := startAdminServer(batchStore)
+	webhookHandler, webhookServer := startWebhookServer(eventsQueue)
 
 	var wg sync.WaitGroup
-	wg.Add(2)
+	if retentionEnabled() {
+		job, err := newRetentionJob(batchStore)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to create retention job: %s", err.Error()))
+			os.Exit(1)
+		}
+		wg.Add(1)
+		go runRetention(ctx, job, batchStore, &wg)
+	}
+	if config.Current().RefreshAfter > 0 {
+		wg.Add(1)
+		go runRefresher(ctx, clients.NewRefresher(gitHubClient, batchStore), batchStore, &wg)
+	}
+	notifier := rules.NewNotifier(&http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)})
+	ruleEngine := rules.NewEngine(notifier)
+	var detector *bots.Detector
+	if config.Current().Bots.Enabled {
+		detector = bots.NewDetector(config.Current().Bots)
+	}
+	if eventSink != nil {
+		wg.Add(1)
+		go publishEvents(ctx, eventSink, sinkQueue, &wg)
+	}
+	wg.Add(5)
 
-	go fetchEvents(gitHubClient, eventsChannel, &wg)
-	go storeEvents(gitHubClient, batchStore, eventsChannel, &wg)
+	go runNotifier(ctx, notifier, &wg)
+	go runRules(ctx, ruleEngine, batchStore.RulesStore(), &wg)
+	go fetchEvents(ctx, clients.NewFeedScheduler(gitHubClient), eventsQueue, webhookHandler, &wg)
+	go storeEvents(ctx, gitHubClient, batchStore, sinkQueue, ruleEngine, detector, eventsQueue, &wg)
+	go watchConfiguration(ctx, hangups, args, loaded.File, &wg)
 
+	<-ctx.Done()
+	slog.Info("shutdown signal received, stopping collector")
 	wg.Wait()
-	close(eventsChannel)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = errors.Join(adminServer.Shutdown(shutdownCtx), shutdownServer(shutdownCtx, webhookServer), eventsQueue.Close(), closeSink(eventSink, sinkQueue), batchStore.Close(), shutdownTracing(shutdownCtx))
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to close collector: %s", err.Error()))
+		os.Exit(1)
+	}
+	slog.Info("Github Events Collector stopped")
 }
 
 func newGitHubClient() *clients.GitHubPublicEventsClient {
-	return clients.NewGitHubClient(github.NewClient(nil).WithAuthToken(config.CollectorConfiguration.GitHubToken))
+	httpClient := &http.Client{Transport: clients.NewTokenTransport(otelhttp.NewTransport(http.DefaultTransport))}
+	return clients.NewGitHubClient(github.NewClient(httpClient))
 }
 
-func fetchEvents(gitHubClient *clients.GitHubPublicEventsClient, eventsChannel chan model.Event, wg *sync.WaitGroup) {
+// fetchEvents polls the feeds into the events queue, but the events delivered by the webhooks, if any
+func fetchEvents(ctx context.Context, feedScheduler *clients.FeedScheduler, eventsQueue *queue.DiskQueue, webhookHandler *webhooks.Handler, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for {
 		slog.Info("fetching events")
-		events, err := fetch(gitHubClient)
+		events, err := fetch(ctx, feedScheduler)
 		if err != nil {
 			slog.Error(fmt.Sprintf("Failed to fetch github events: %s", err.Error()))
+		} else {
+			health.CollectorState.RecordFetch()
+		}
+		events = filterEvents(events)
+		if webhookHandler != nil {
+			events = webhookHandler.Undelivered(events)
+		}
+		err = eventsQueue.Append(events)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to enqueue %d events: %s", len(events), err.Error()))
 		}
-		for _, event := range events {
-			eventsChannel <- event
+		reportQueueStats(eventsQueue)
+
+		if !waitForNextFetch(ctx, time.Now()) {
+			slog.Info("stopped fetching events")
+			return
 		}
-		time.Sleep(config.CollectorConfiguration.FetchInterval)
 	}
 }
 
-func fetch(gitHubClient *clients.GitHubPublicEventsClient) ([]model.Event, error) {
-	events, err := gitHubClient.ListEvents()
-	if err != nil {
-		return nil, err
+// waitForNextFetch waits for the fetch interval, which may be changed by a reload while waiting. It returns false on shutdown.
+func waitForNextFetch(ctx context.Context, lastFetch time.Time) bool {
+	for {
+		timer := time.NewTimer(time.Until(lastFetch.Add(config.Current().FetchInterval)))
+		select {
+		case <-timer.C:
+			return true
+		case <-config.Reloaded():
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+}
+
+// filterEvents applies the current event filters, which may be changed by a reload
+func filterEvents(events []model.Event) []model.Event {
+	filtered := config.Current().EventsFilter().Apply(events)
+	if len(filtered) < len(events) {
+		slog.Debug(fmt.Sprintf("filtered out %d of %d events", len(events)-len(filtered), len(events)))
 	}
-	return events, nil
+	return filtered
+}
+
+func fetch(ctx context.Context, feedScheduler *clients.FeedScheduler) ([]model.Event, error) {
+	ctx, span := tracer.Start(ctx, "fetch")
+	defer span.End()
+
+	return feedScheduler.FetchWithContext(ctx)
 }
 
-func storeEvents(gitHubClient *clients.GitHubPublicEventsClient, batchStore *clients.GithubStoreClient, eventsChannel chan model.Event, wg *sync.WaitGroup) {
+func storeEvents(ctx context.Context, gitHubClient *clients.GitHubPublicEventsClient, batchStore *clients.GithubStoreClient, sinkQueue *queue.DiskQueue, ruleEngine *rules.Engine, detector *bots.Detector, eventsQueue *queue.DiskQueue, wg *sync.WaitGroup) {
 	defer wg.Done()
-	ticker := time.NewTicker(config.CollectorConfiguration.MaxTimeout)
+	ticker := time.NewTicker(config.Current().MaxTimeout)
 	defer ticker.Stop()
 
-	events := make([]model.Event, 0)
-
 	//use a time/size bounded queue to store events/repos/users in batches
 	for {
 		select {
+		case <-ctx.Done():
+			// the batch interrupted by the shutdown is still queued, the pending batches are flushed until the timeout
+			slog.Info(fmt.Sprintf("flushing %d pending items before shutdown", eventsQueue.Pending()))
+			flushCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			for eventsQueue.Pending() > 0 && flushCtx.Err() == nil && saveBatch(flushCtx, gitHubClient, batchStore, sinkQueue, ruleEngine, detector, eventsQueue) {
+			}
+			cancel()
+			return
 		case <-ticker.C:
 			slog.Debug("reached max timeout")
-			if len(events) > 0 {
-				slog.Debug(fmt.Sprintf("saving %d items", len(events)))
-				repos, err := gitHubClient.FetchRepos(events)
-				if err != nil {
-					slog.Error(fmt.Sprintf("failed to fetch repos: %s, %v", err.Error(), repos))
-				}
-				batchStore.Save(events, repos)
-				events = make([]model.Event, 0)
+			if eventsQueue.Pending() > 0 {
+				saveBatch(ctx, gitHubClient, batchStore, sinkQueue, ruleEngine, detector, eventsQueue)
 			} else {
 				slog.Debug("zero items in batch. Skipping saving")
 			}
-		case event := <-eventsChannel:
-			events = append(events, event)
-			if len(events) >= config.CollectorConfiguration.MaxItems {
-				slog.Debug("reached max items")
-				slog.Debug(fmt.Sprintf("saving %d items", len(events)))
-				repos, err := gitHubClient.FetchRepos(events)
-				if err != nil {
-					slog.Error(fmt.Sprintf("failed to fetch repos: %s, %v", err.Error(), repos))
-				}
-				batchStore.Save(events, repos)
-				ticker.Reset(config.CollectorConfiguration.MaxTimeout)
-				events = make([]model.Event, 0)
-			}
+		case <-eventsQueue.Notify():
+			saveFullBatches(ctx, gitHubClient, batchStore, sinkQueue, ruleEngine, detector, eventsQueue, ticker)
+		case <-config.Reloaded():
+			// the batch size and timeout may have changed
+			ticker.Reset(config.Current().MaxTimeout)
+			saveFullBatches(ctx, gitHubClient, batchStore, sinkQueue, ruleEngine, detector, eventsQueue, ticker)
+		}
+	}
+}
+
+func saveFullBatches(ctx context.Context, gitHubClient *clients.GitHubPublicEventsClient, batchStore *clients.GithubStoreClient, sinkQueue *queue.DiskQueue, ruleEngine *rules.Engine, detector *bots.Detector, eventsQueue *queue.DiskQueue, ticker *time.Ticker) {
+	for eventsQueue.Pending() >= config.Current().MaxItems {
+		slog.Debug("reached max items")
+		if !saveBatch(ctx, gitHubClient, batchStore, sinkQueue, ruleEngine, detector, eventsQueue) {
+			break
 		}
+		ticker.Reset(config.Current().MaxTimeout)
+	}
+}
+
+// saveBatch stores the oldest pending batch, queues it for the sink, if any, and acknowledges it. Failed batches stay
+// queued for replay, like the batch interrupted when ctx is done.
+func saveBatch(ctx context.Context, gitHubClient *clients.GitHubPublicEventsClient, batchStore *clients.GithubStoreClient, sinkQueue *queue.DiskQueue, ruleEngine *rules.Engine, detector *bots.Detector, eventsQueue *queue.DiskQueue) bool {
+	batch, err := eventsQueue.Peek(config.Current().MaxItems)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to read pending batch: %s", err.Error()))
+		return false
+	}
+	if len(batch.Events) == 0 {
+		return false
+	}
+
+	batchId := newBatchId()
+	ctx, span := tracer.Start(logging.WithBatchId(ctx, batchId), "batch", trace.WithAttributes(
+		attribute.String("batch.id", batchId),
+		attribute.Int("batch.size", len(batch.Events))))
+	defer span.End()
+
+	slog.DebugContext(ctx, fmt.Sprintf("saving %d items", len(batch.Events)))
+	start := time.Now()
+	repos, err := gitHubClient.FetchReposWithContext(ctx, batch.Events)
+	if err != nil {
+		slog.ErrorContext(ctx, fmt.Sprintf("failed to fetch repos: %s, %v", err.Error(), repos))
+	}
+	err = batchStore.SaveWithContext(ctx, batch.Events, repos)
+	metrics.BatchLatency.Observe(time.Since(start).Seconds())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		slog.WarnContext(ctx, fmt.Sprintf("batch of %d items kept for replay: %s", len(batch.Events), err.Error()))
+		metrics.BatchesFailed.Inc()
+		return false
+	}
+	metrics.BatchSize.Observe(float64(len(batch.Events)))
+	health.CollectorState.RecordStore()
+	if detector != nil {
+		saveBotScores(ctx, batchStore, detector.Observe(batch.Events))
+	}
+	batchStore.RecordWriteVersion()
+	// replayed events are only counted once by the rules
+	ruleEngine.Evaluate(batch.Events, repos)
+	if sinkQueue != nil {
+		queueForSink(ctx, sinkQueue, batch.Events)
+	}
+
+	err = eventsQueue.Ack(batch)
+	if err != nil {
+		slog.ErrorContext(ctx, fmt.Sprintf("failed to acknowledge batch: %s", err.Error()))
+		return false
+	}
+	reportQueueStats(eventsQueue)
+	return true
+}
+
+// newSinkQueue opens the queue of the events to publish to the sink, it returns nil when no sink is configured
+func newSinkQueue(eventSink sinks.Sink) (*queue.DiskQueue, error) {
+	if eventSink == nil {
+		return nil, nil
+	}
+	return queue.NewDiskQueue(config.Current().Sink.QueueDir, config.Current().QueueSegmentBytes, config.Current().Sink.QueueMaxBytes)
+}
+
+// queueForSink queues the events of a stored batch for the sink. The events are only dropped without being published
+// when the sink queue is full, so a broker outage doesn't stop the events from being stored.
+func queueForSink(ctx context.Context, sinkQueue *queue.DiskQueue, events []model.Event) {
+	err := sinkQueue.Append(events)
+	if err != nil {
+		slog.ErrorContext(ctx, fmt.Sprintf("failed to queue %d events for the sink, they won't be published: %s", len(events), err.Error()))
+		metrics.SinkDropped.WithLabelValues(config.Current().Sink.Type).Add(float64(len(events)))
+	}
+	metrics.SinkQueueDepth.Set(float64(sinkQueue.Pending()))
+}
+
+// publishEvents publishes the queued events to the sink, independently of the store. Failed publications stay queued
+// and are retried every MAX_TIMEOUT, the queued events are published after a restart.
+func publishEvents(ctx context.Context, eventSink sinks.Sink, sinkQueue *queue.DiskQueue, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ticker := time.NewTicker(config.Current().MaxTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			publishBatches(eventSink, sinkQueue)
+		case <-sinkQueue.Notify():
+			publishBatches(eventSink, sinkQueue)
+		}
+	}
+}
+
+// publishBatches publishes the queued events until the queue is empty or a publication fails
+func publishBatches(eventSink sinks.Sink, sinkQueue *queue.DiskQueue) {
+	for sinkQueue.Pending() > 0 {
+		batch, err := sinkQueue.Peek(config.Current().MaxItems)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to read the sink queue: %s", err.Error()))
+			return
+		}
+		// the events of a failed publication are published again, the sink drops the duplicates by event id
+		err = eventSink.Publish(context.Background(), batch.Events)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("%d events kept for the sink: %s", len(batch.Events), err.Error()))
+			return
+		}
+		err = sinkQueue.Ack(batch)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to acknowledge published events: %s", err.Error()))
+			return
+		}
+		metrics.SinkQueueDepth.Set(float64(sinkQueue.Pending()))
+	}
+}
+
+// saveBotScores stores the changed bot scores of the actors of a batch. Scores failing to be stored are only logged,
+// they are stored again once they change.
+func saveBotScores(ctx context.Context, batchStore *clients.GithubStoreClient, scores []model.BotScore) {
+	for _, score := range scores {
+		metrics.BotScores.WithLabelValues(strconv.FormatBool(score.Suspected)).Inc()
+		if score.Suspected {
+			slog.InfoContext(ctx, fmt.Sprintf("suspected bot %s, scored %d: %s", score.Login, score.Score, strings.Join(score.Reasons, ", ")))
+		}
+	}
+	err := batchStore.SaveBotScoresWithContext(ctx, scores)
+	if err != nil {
+		slog.WarnContext(ctx, fmt.Sprintf("failed to save %d bot scores: %s", len(scores), err.Error()))
+	}
+}
+
+// readinessStaleAfter is the time without any stored batch after which the collector is considered not ready
+func readinessStaleAfter() time.Duration {
+	interval := config.Current().FetchInterval
+	if config.Current().MaxTimeout > interval {
+		interval = config.Current().MaxTimeout
+	}
+	return time.Duration(config.Current().ReadinessStaleIntervals) * interval
+}
+
+func newBatchId() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func reportQueueStats(eventsQueue *queue.DiskQueue) {
+	stats := eventsQueue.Stats()
+	metrics.QueueDepth.Set(float64(stats.Depth))
+	metrics.QueueBytes.Set(float64(stats.Bytes))
+	slog.Debug(fmt.Sprintf("queue depth: %d items, %d bytes in %d segments", stats.Depth, stats.Bytes, stats.Segments))
+}
+
+func startAdminServer(batchStore *clients.GithubStoreClient) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", health.LivenessHandler)
+	mux.HandleFunc("/readyz", health.ReadinessHandler(health.CollectorState, map[string]health.Pinger{"mongo": batchStore}, readinessStaleAfter))
+	mux.HandleFunc("/loglevel", logging.LevelHandler)
+
+	server := &http.Server{Addr: ":" + config.Current().AdminPort, Handler: mux}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(fmt.Sprintf("collector admin server failed. reason: %s", err.Error()))
+		}
+	}()
+	return server
+}
+
+// startWebhookServer receives GitHub webhook deliveries on WEBHOOK_PORT, if set, into the events queue
+func startWebhookServer(eventsQueue *queue.DiskQueue) (*webhooks.Handler, *http.Server) {
+	if len(config.Current().WebhookPort) == 0 {
+		return nil, nil
+	}
+	handler := webhooks.NewHandler(
+		func() string { return config.Current().WebhookSecret },
+		func(events []model.Event) error {
+			err := eventsQueue.Append(filterEvents(events))
+			reportQueueStats(eventsQueue)
+			return err
+		})
+	mux := http.NewServeMux()
+	mux.Handle(config.Current().WebhookPath, otelhttp.NewHandler(handler, "webhook"))
+
+	server := &http.Server{Addr: ":" + config.Current().WebhookPort, Handler: mux}
+	go func() {
+		slog.Info(fmt.Sprintf("receiving webhooks on :%s%s", config.Current().WebhookPort, config.Current().WebhookPath))
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(fmt.Sprintf("collector webhook server failed. reason: %s", err.Error()))
+		}
+	}()
+	return handler, server
+}
+
+func closeSink(eventSink sinks.Sink, sinkQueue *queue.DiskQueue) error {
+	if eventSink == nil {
+		return nil
+	}
+	return errors.Join(sinkQueue.Close(), eventSink.Close())
+}
+
+func shutdownServer(ctx context.Context, server *http.Server) error {
+	if server == nil {
+		return nil
 	}
+	return server.Shutdown(ctx)
 }
@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "events_collector"
+
+var (
+	EventsFetched = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_fetched_total",
+		Help:      "Number of events fetched from the GitHub public events api.",
+	})
+	FetchErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fetch_errors_total",
+		Help:      "Number of failed GitHub public events requests.",
+	})
+	FeedPolls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "feed_polls_total",
+		Help:      "Number of events feed polls, per feed and result (ok, not_modified or error).",
+	}, []string{"feed", "result"})
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Number of received webhook deliveries, per event and result.",
+	}, []string{"event", "result"})
+	EventsFiltered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_filtered_total",
+		Help:      "Number of fetched events dropped by the event filters, per rule.",
+	}, []string{"rule"})
+	DuplicatesSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "duplicates_skipped_total",
+		Help:      "Number of already stored items omitted while saving a batch.",
+	}, []string{"collection"})
+	BatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_size",
+		Help:      "Number of events in a stored batch.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+	BatchLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_duration_seconds",
+		Help:      "Time it takes to enrich and store a batch.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	BatchesFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "batches_failed_total",
+		Help:      "Number of batches that failed to be stored and were kept for replay.",
+	})
+	StoreWriteLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_write_duration_seconds",
+		Help:      "Time it takes to write a batch into a collection.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"collection"})
+	DeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dead_lettered_total",
+		Help:      "Number of items moved to the dead letters collection.",
+	}, []string{"collection"})
+	ReposResolved = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_repos_resolved_total",
+		Help:      "Number of repos resolved through the GitHub GraphQL api.",
+	})
+	ReposFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_repos_failed_total",
+		Help:      "Number of repos that could not be resolved through the GitHub GraphQL api.",
+	})
+	RateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "github_rate_limit_remaining",
+		Help:      "Remaining GitHub api requests in the current rate limit window.",
+	}, []string{"api"})
+	QueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Number of fetched events waiting in the write-ahead queue.",
+	})
+	QueueBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_bytes",
+		Help:      "Size of the write-ahead queue segments on disk.",
+	})
+	SinkPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sink_published_total",
+		Help:      "Number of events published to a sink.",
+	}, []string{"sink"})
+	SinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sink_errors_total",
+		Help:      "Number of batches that failed to be published to a sink.",
+	}, []string{"sink"})
+	SinkDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sink_dropped_total",
+		Help:      "Number of stored events dropped without being published, as the sink queue was full.",
+	}, []string{"sink"})
+	SinkQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sink_queue_depth",
+		Help:      "Number of stored events waiting to be published to the sink.",
+	})
+	SinkPublishLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sink_publish_duration_seconds",
+		Help:      "Time it takes to publish a batch to a sink, until acknowledged.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"sink"})
+	RetentionDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retention_deleted_total",
+		Help:      "Number of items deleted by the retention job, per collection.",
+	}, []string{"collection"})
+	Refreshed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refreshed_total",
+		Help:      "Number of repos and users refreshed from GitHub, per collection and result (updated or not_found).",
+	}, []string{"collection", "result"})
+	RulesLoaded = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rules_loaded",
+		Help:      "Number of enabled alert rules evaluated against the stored events.",
+	})
+	RulesFired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rules_fired_total",
+		Help:      "Number of alerts fired, per rule id.",
+	}, []string{"rule"})
+	BotScores = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bot_scores_total",
+		Help:      "Number of stored actor bot scores, per suspicion (true or false).",
+	}, []string{"suspected"})
+	AlertDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alert_deliveries_total",
+		Help:      "Number of alert webhook deliveries, per result (delivered, failed or dropped).",
+	}, []string{"result"})
+)
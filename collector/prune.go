@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github-events-microservices/collector/clients"
+	"github-events-microservices/collector/config"
+	"github-events-microservices/collector/metrics"
+	"github-events-microservices/collector/retention"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+func retentionPolicies() []retention.Policy {
+	configuration := config.Current()
+	return []retention.Policy{
+		{Collection: configuration.EventsCollection, Field: "created_at", MaxAge: configuration.EventsRetention},
+		{Collection: configuration.ReposCollection, Field: "last_updated_at", MaxAge: configuration.ReposRetention},
+		{Collection: configuration.UsersCollection, Field: "last_updated_at", MaxAge: configuration.UsersRetention},
+	}
+}
+
+func retentionEnabled() bool {
+	for _, policy := range retentionPolicies() {
+		if policy.MaxAge > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func newRetentionJob(batchStore *clients.GithubStoreClient) (*retention.Job, error) {
+	return retention.NewJob(retentionPolicies(), batchStore.ExpiringStores(), config.Current().RetentionArchiveDir, config.Current().RetentionDryRun)
+}
+
+// runRetention prunes the expired items every retention interval
+func runRetention(ctx context.Context, job *retention.Job, batchStore *clients.GithubStoreClient, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ticker := time.NewTicker(config.Current().RetentionInterval)
+	defer ticker.Stop()
+	for {
+		prune(job, batchStore)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func prune(job *retention.Job, batchStore *clients.GithubStoreClient) {
+	dryRun := ""
+	if config.Current().RetentionDryRun {
+		dryRun = " (dry run)"
+	}
+	results, err := job.Run()
+	deleted := int64(0)
+	for _, result := range results {
+		deleted += result.Deleted
+		slog.Info(fmt.Sprintf("retention%s: %s: %d items older than %s expired, %d deleted, archive: '%s'",
+			dryRun, result.Collection, result.Expired, result.Cutoff.Format(time.RFC3339), result.Deleted, result.ArchiveFile))
+		metrics.RetentionDeleted.WithLabelValues(result.Collection).Add(float64(result.Deleted))
+	}
+	if deleted > 0 {
+		batchStore.RecordWriteVersion()
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("retention failed: %s", err.Error()))
+	}
+}
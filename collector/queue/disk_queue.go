@@ -0,0 +1,474 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github-events-microservices/model"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentSuffix = ".seg"
+	ackFileName   = "ack"
+	headerSize    = 8
+)
+
+var ErrQueueFull = errors.New("queue size cap reached")
+
+// DiskQueue is a write-ahead buffer of events. Events are appended to checksummed segment files
+// and stay on disk until the batch containing them is acknowledged, so they can be replayed after
+// a crash or a store outage.
+type DiskQueue struct {
+	mu              sync.Mutex
+	dir             string
+	segmentMaxBytes int64
+	maxBytes        int64
+	segments        []*segment
+	writer          *os.File
+	ack             Position
+	pending         int
+	notify          chan struct{}
+}
+
+type segment struct {
+	id   int64
+	path string
+	size int64
+}
+
+// Position points at a record inside a segment.
+type Position struct {
+	Segment int64 `json:"segment"`
+	Offset  int64 `json:"offset"`
+}
+
+// Batch is a run of unacknowledged events, starting at the current ack position.
+type Batch struct {
+	Events []model.Event
+	next   Position
+}
+
+type Stats struct {
+	Depth    int
+	Bytes    int64
+	Segments int
+}
+
+func (receiver *DiskQueue) Append(events []model.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	records := make([][]byte, len(events))
+	var size int64
+	for i, event := range events {
+		record, err := encodeRecord(event)
+		if err != nil {
+			return err
+		}
+		records[i] = record
+		size += int64(len(record))
+	}
+	if receiver.maxBytes > 0 && receiver.pendingBytes()+size > receiver.maxBytes {
+		return ErrQueueFull
+	}
+
+	for _, record := range records {
+		last := receiver.segments[len(receiver.segments)-1]
+		if last.size > 0 && last.size+int64(len(record)) > receiver.segmentMaxBytes {
+			err := receiver.roll()
+			if err != nil {
+				return err
+			}
+			last = receiver.segments[len(receiver.segments)-1]
+		}
+		_, err := receiver.writer.Write(record)
+		if err != nil {
+			// drop the partially written record, so the segment size matches the file
+			return errors.Join(err, receiver.writer.Truncate(last.size))
+		}
+		last.size += int64(len(record))
+		receiver.pending++
+	}
+
+	err := receiver.writer.Sync()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case receiver.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Peek reads up to max unacknowledged events without consuming them.
+func (receiver *DiskQueue) Peek(max int) (Batch, error) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	batch := Batch{Events: make([]model.Event, 0), next: receiver.ack}
+	for _, seg := range receiver.segments {
+		if seg.id < batch.next.Segment {
+			continue
+		}
+		offset := int64(0)
+		if seg.id == batch.next.Segment {
+			offset = batch.next.Offset
+		}
+		events, end, err := readSegment(seg.path, offset, seg.size, max-len(batch.Events))
+		if err != nil {
+			return Batch{}, err
+		}
+		batch.Events = append(batch.Events, events...)
+		batch.next = Position{Segment: seg.id, Offset: end}
+		if len(batch.Events) >= max {
+			break
+		}
+	}
+	return batch, nil
+}
+
+// Ack marks the events of the batch as stored and removes fully consumed segments.
+func (receiver *DiskQueue) Ack(batch Batch) error {
+	if len(batch.Events) == 0 {
+		return nil
+	}
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	next := batch.next
+	for i, seg := range receiver.segments {
+		if seg.id == next.Segment && next.Offset >= seg.size && i < len(receiver.segments)-1 {
+			next = Position{Segment: receiver.segments[i+1].id, Offset: 0}
+		}
+	}
+
+	err := receiver.writeAck(next)
+	if err != nil {
+		return err
+	}
+	receiver.ack = next
+	receiver.pending -= len(batch.Events)
+
+	remaining := make([]*segment, 0, len(receiver.segments))
+	for _, seg := range receiver.segments {
+		if seg.id < next.Segment {
+			err := os.Remove(seg.path)
+			if err != nil {
+				slog.Warn(fmt.Sprintf("failed to remove consumed segment %s: %s", seg.path, err.Error()))
+			}
+			continue
+		}
+		remaining = append(remaining, seg)
+	}
+	receiver.segments = remaining
+	return nil
+}
+
+// Notify is signaled whenever new events are appended.
+func (receiver *DiskQueue) Notify() <-chan struct{} {
+	return receiver.notify
+}
+
+func (receiver *DiskQueue) Pending() int {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return receiver.pending
+}
+
+func (receiver *DiskQueue) Stats() Stats {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return Stats{
+		Depth:    receiver.pending,
+		Bytes:    receiver.totalBytes(),
+		Segments: len(receiver.segments),
+	}
+}
+
+func (receiver *DiskQueue) Close() error {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return receiver.writer.Close()
+}
+
+func (receiver *DiskQueue) totalBytes() int64 {
+	var total int64
+	for _, seg := range receiver.segments {
+		total += seg.size
+	}
+	return total
+}
+
+// pendingBytes is the size of the unacknowledged records, the acknowledged ones of the head segment stay on disk until
+// the segment is consumed
+func (receiver *DiskQueue) pendingBytes() int64 {
+	total := receiver.totalBytes()
+	if receiver.segments[0].id == receiver.ack.Segment {
+		total -= receiver.ack.Offset
+	}
+	return total
+}
+
+func (receiver *DiskQueue) roll() error {
+	last := receiver.segments[len(receiver.segments)-1]
+	err := receiver.writer.Close()
+	if err != nil {
+		return err
+	}
+	seg := &segment{id: last.id + 1, path: segmentPath(receiver.dir, last.id+1)}
+	writer, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	receiver.segments = append(receiver.segments, seg)
+	receiver.writer = writer
+	return nil
+}
+
+func (receiver *DiskQueue) writeAck(position Position) error {
+	bytes, err := json.Marshal(position)
+	if err != nil {
+		return err
+	}
+	tmpPath := filepath.Join(receiver.dir, ackFileName+".tmp")
+	err = os.WriteFile(tmpPath, bytes, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(receiver.dir, ackFileName))
+}
+
+func encodeRecord(event model.Event) ([]byte, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, headerSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[headerSize:], payload)
+	return record, nil
+}
+
+// readSegment decodes up to max records starting at offset and returns the offset following the last one.
+func readSegment(path string, offset int64, size int64, max int) ([]model.Event, int64, error) {
+	events := make([]model.Event, 0)
+	if max <= 0 || offset >= size {
+		return events, offset, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, offset, err
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, offset, err
+	}
+	reader := bufio.NewReader(io.LimitReader(file, size-offset))
+	for len(events) < max {
+		payload, err := readRecord(reader, size-offset)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, offset, fmt.Errorf("failed to read segment %s at offset %d: %w", path, offset, err)
+		}
+		var event model.Event
+		err = json.Unmarshal(payload, &event)
+		if err != nil {
+			return nil, offset, err
+		}
+		events = append(events, event)
+		offset += int64(headerSize + len(payload))
+	}
+	return events, offset, nil
+}
+
+// readRecord reads the next record of a reader of remaining bytes. A length longer than the remaining bytes is
+// rejected before its payload is allocated, like a checksum mismatch, as a corrupted header may have any length.
+func readRecord(reader io.Reader, remaining int64) ([]byte, error) {
+	header := make([]byte, headerSize)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated record header")
+		}
+		return nil, err
+	}
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	if length > remaining-headerSize {
+		return nil, fmt.Errorf("invalid record length %d, only %d bytes remain", length, remaining-headerSize)
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return nil, errors.New("truncated record payload")
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return payload, nil
+}
+
+// scanSegment counts the valid records of a segment from offset on, and returns the end of the last valid record.
+func scanSegment(path string, offset int64) (int, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	reader := bufio.NewReader(file)
+	recordsAfterOffset := 0
+	var validEnd int64
+	for {
+		payload, err := readRecord(reader, info.Size()-validEnd)
+		if err != nil {
+			if err != io.EOF {
+				slog.Warn(fmt.Sprintf("segment %s is corrupted at offset %d: %s", path, validEnd, err.Error()))
+			}
+			break
+		}
+		if validEnd >= offset {
+			recordsAfterOffset++
+		}
+		validEnd += int64(headerSize + len(payload))
+	}
+	return recordsAfterOffset, validEnd, nil
+}
+
+func segmentPath(dir string, id int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", id, segmentSuffix))
+}
+
+func readAck(dir string) (Position, error) {
+	var position Position
+	bytes, err := os.ReadFile(filepath.Join(dir, ackFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return position, nil
+	} else if err != nil {
+		return position, err
+	}
+	err = json.Unmarshal(bytes, &position)
+	return position, err
+}
+
+func listSegmentIds(dir string) ([]int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(entry.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("ignoring unknown file in queue dir: %s", entry.Name()))
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// NewDiskQueue opens the queue in dir, replaying any segments left from a previous run.
+// A torn record at the tail of the last segment is truncated.
+func NewDiskQueue(dir string, segmentMaxBytes int64, maxBytes int64) (*DiskQueue, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	ack, err := readAck(dir)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := listSegmentIds(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := &DiskQueue{
+		dir:             dir,
+		segmentMaxBytes: segmentMaxBytes,
+		maxBytes:        maxBytes,
+		segments:        make([]*segment, 0),
+		notify:          make(chan struct{}, 1),
+	}
+
+	for i, id := range ids {
+		path := segmentPath(dir, id)
+		if id < ack.Segment {
+			err := os.Remove(path)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		offset := int64(0)
+		if id == ack.Segment {
+			offset = ack.Offset
+		}
+		pending, validEnd, err := scanSegment(path, offset)
+		if err != nil {
+			return nil, err
+		}
+		if i == len(ids)-1 {
+			err := os.Truncate(path, validEnd)
+			if err != nil {
+				return nil, err
+			}
+		}
+		queue.segments = append(queue.segments, &segment{id: id, path: path, size: validEnd})
+		queue.pending += pending
+	}
+
+	if len(queue.segments) == 0 {
+		id := ack.Segment
+		queue.segments = append(queue.segments, &segment{id: id, path: segmentPath(dir, id)})
+		if ack.Offset != 0 {
+			ack = Position{Segment: id, Offset: 0}
+			queue.ack = ack
+			err := queue.writeAck(ack)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	queue.ack = ack
+
+	last := queue.segments[len(queue.segments)-1]
+	queue.writer, err = os.OpenFile(last.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if queue.pending > 0 {
+		slog.Info(fmt.Sprintf("replaying %d unacknowledged events from %s", queue.pending, dir))
+		queue.notify <- struct{}{}
+	}
+	return queue, nil
+}
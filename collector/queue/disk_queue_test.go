@@ -0,0 +1,207 @@
+package queue
+
+import (
+	"errors"
+	"github-events-microservices/model"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDiskQueue_AppendPeekAck(t *testing.T) {
+	dir := t.TempDir()
+	diskQueue, err := NewDiskQueue(dir, 256, 0)
+	if err != nil {
+		t.Fatalf("NewDiskQueue() error = %v", err)
+	}
+	defer diskQueue.Close()
+
+	events := createEvents(5)
+	err = diskQueue.Append(events)
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if diskQueue.Stats().Segments < 2 {
+		t.Errorf("expected events to span several segments, got %d", diskQueue.Stats().Segments)
+	}
+
+	batch, err := diskQueue.Peek(3)
+	if err != nil {
+		t.Fatalf("Peek() error = %v", err)
+	}
+	if !reflect.DeepEqual(batch.Events, events[:3]) {
+		t.Errorf("Peek() got = %v, want %v", batch.Events, events[:3])
+	}
+
+	err = diskQueue.Ack(batch)
+	if err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if diskQueue.Pending() != 2 {
+		t.Errorf("Pending() got = %d, want 2", diskQueue.Pending())
+	}
+
+	batch, err = diskQueue.Peek(10)
+	if err != nil {
+		t.Fatalf("Peek() error = %v", err)
+	}
+	if !reflect.DeepEqual(batch.Events, events[3:]) {
+		t.Errorf("Peek() got = %v, want %v", batch.Events, events[3:])
+	}
+}
+
+func TestDiskQueue_ReplayAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	diskQueue, err := NewDiskQueue(dir, 256, 0)
+	if err != nil {
+		t.Fatalf("NewDiskQueue() error = %v", err)
+	}
+
+	events := createEvents(4)
+	_ = diskQueue.Append(events)
+	batch, _ := diskQueue.Peek(1)
+	_ = diskQueue.Ack(batch)
+	_ = diskQueue.Close()
+
+	reopened, err := NewDiskQueue(dir, 256, 0)
+	if err != nil {
+		t.Fatalf("NewDiskQueue() error = %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Pending() != 3 {
+		t.Errorf("Pending() got = %d, want 3", reopened.Pending())
+	}
+	select {
+	case <-reopened.Notify():
+	default:
+		t.Errorf("expected replay notification")
+	}
+	batch, err = reopened.Peek(10)
+	if err != nil {
+		t.Fatalf("Peek() error = %v", err)
+	}
+	if !reflect.DeepEqual(batch.Events, events[1:]) {
+		t.Errorf("Peek() got = %v, want %v", batch.Events, events[1:])
+	}
+}
+
+func TestDiskQueue_TruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	diskQueue, err := NewDiskQueue(dir, 1024*1024, 0)
+	if err != nil {
+		t.Fatalf("NewDiskQueue() error = %v", err)
+	}
+	events := createEvents(2)
+	_ = diskQueue.Append(events)
+	_ = diskQueue.Close()
+
+	file, err := os.OpenFile(segmentPath(dir, 0), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("failed to open segment: %v", err)
+	}
+	_, _ = file.Write([]byte{0, 0, 0, 42, 1, 2})
+	_ = file.Close()
+
+	reopened, err := NewDiskQueue(dir, 1024*1024, 0)
+	if err != nil {
+		t.Fatalf("NewDiskQueue() error = %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Pending() != 2 {
+		t.Errorf("Pending() got = %d, want 2", reopened.Pending())
+	}
+	_ = reopened.Append(createEvents(1))
+	batch, err := reopened.Peek(10)
+	if err != nil {
+		t.Fatalf("Peek() error = %v", err)
+	}
+	if len(batch.Events) != 3 {
+		t.Errorf("Peek() got %d events, want 3", len(batch.Events))
+	}
+}
+
+func TestDiskQueue_RejectsCorruptedLength(t *testing.T) {
+	dir := t.TempDir()
+	diskQueue, err := NewDiskQueue(dir, 1024*1024, 0)
+	if err != nil {
+		t.Fatalf("NewDiskQueue() error = %v", err)
+	}
+	events := createEvents(2)
+	_ = diskQueue.Append(events)
+	_ = diskQueue.Close()
+
+	// the length of the second record claims 4 GiB
+	first, _ := encodeRecord(events[0])
+	file, err := os.OpenFile(segmentPath(dir, 0), os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open segment: %v", err)
+	}
+	_, _ = file.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, int64(len(first)))
+	_ = file.Close()
+
+	reopened, err := NewDiskQueue(dir, 1024*1024, 0)
+	if err != nil {
+		t.Fatalf("NewDiskQueue() error = %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Pending() != 1 {
+		t.Errorf("Pending() got = %d, want 1", reopened.Pending())
+	}
+	if stats := reopened.Stats(); stats.Bytes != int64(len(first)) {
+		t.Errorf("Stats() got %d bytes, want the %d bytes of the first record", stats.Bytes, len(first))
+	}
+}
+
+func TestDiskQueue_SizeCap(t *testing.T) {
+	diskQueue, err := NewDiskQueue(t.TempDir(), 1024, 200)
+	if err != nil {
+		t.Fatalf("NewDiskQueue() error = %v", err)
+	}
+	defer diskQueue.Close()
+
+	err = diskQueue.Append(createEvents(10))
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Append() error = %v, want %v", err, ErrQueueFull)
+	}
+	if diskQueue.Pending() != 0 {
+		t.Errorf("Pending() got = %d, want 0", diskQueue.Pending())
+	}
+}
+
+func TestDiskQueue_SizeCapCountsPendingRecords(t *testing.T) {
+	// segments as large as the queue only roll once full, so their acknowledged records stay on disk until then
+	diskQueue, err := NewDiskQueue(t.TempDir(), 1024, 1024)
+	if err != nil {
+		t.Fatalf("NewDiskQueue() error = %v", err)
+	}
+	defer diskQueue.Close()
+
+	for i := 0; i < 10; i++ {
+		err = diskQueue.Append(createEvents(2))
+		if err != nil {
+			t.Fatalf("Append() #%d error = %v", i, err)
+		}
+		batch, err := diskQueue.Peek(10)
+		if err != nil {
+			t.Fatalf("Peek() error = %v", err)
+		}
+		err = diskQueue.Ack(batch)
+		if err != nil {
+			t.Fatalf("Ack() error = %v", err)
+		}
+	}
+	if diskQueue.Pending() != 0 {
+		t.Errorf("Pending() got = %d, want 0", diskQueue.Pending())
+	}
+}
+
+func createEvents(count int) []model.Event {
+	events := make([]model.Event, count)
+	for i := range events {
+		events[i] = model.Event{ID: string(rune('a' + i)), Type: "PushEvent", RepoFullName: "a/b", ActorId: int64(i)}
+	}
+	return events
+}
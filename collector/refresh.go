@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github-events-microservices/collector/clients"
+	"github-events-microservices/collector/config"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// runRefresher refreshes the stale repos and users every refresh interval, which may be changed by a reload
+func runRefresher(ctx context.Context, refresher *clients.Refresher, batchStore *clients.GithubStoreClient, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		refresh(ctx, refresher, batchStore)
+		timer := time.NewTimer(config.Current().RefreshInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func refresh(ctx context.Context, refresher *clients.Refresher, batchStore *clients.GithubStoreClient) {
+	results, err := refresher.RefreshWithContext(ctx)
+	refreshed := 0
+	for _, result := range results {
+		refreshed += result.Updated + result.NotFound
+		slog.Info(fmt.Sprintf("refreshed %d %s, %d not found", result.Updated, result.Collection, result.NotFound))
+	}
+	if refreshed > 0 {
+		batchStore.RecordWriteVersion()
+	}
+	if clients.IsRateLimitReserve(err) {
+		slog.Warn(fmt.Sprintf("refresh paused: %s", err.Error()))
+	} else if err != nil {
+		slog.Error(fmt.Sprintf("refresh failed: %s", err.Error()))
+	}
+}
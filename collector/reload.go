@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github-events-microservices/collector/config"
+	"github-events-microservices/logging"
+	"github-events-microservices/settings"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const configWatchInterval = 5 * time.Second
+
+// notifyHangups registers the SIGHUP channel of watchConfiguration. It must be called early in the startup, as an
+// unhandled SIGHUP terminates the process.
+func notifyHangups() chan os.Signal {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	return hangups
+}
+
+// watchConfiguration reloads the configuration on SIGHUP, including the one received during the startup, and whenever
+// the configuration file is modified
+func watchConfiguration(ctx context.Context, hangups chan os.Signal, args []string, configFile string, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer signal.Stop(hangups)
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	lastModified := modificationTime(configFile)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			slog.Info("SIGHUP received, reloading configuration")
+			reloadConfiguration(args)
+		case <-ticker.C:
+			if len(configFile) == 0 {
+				continue
+			}
+			modified := modificationTime(configFile)
+			if !modified.Equal(lastModified) {
+				lastModified = modified
+				slog.Info(fmt.Sprintf("%s was modified, reloading configuration", configFile))
+				reloadConfiguration(args)
+			}
+		}
+	}
+}
+
+// reloadConfiguration applies the changed reloadable values and logs every change. Invalid configurations are ignored.
+func reloadConfiguration(args []string) {
+	changes, err := config.Reload(args)
+	if err != nil {
+		slog.Error(fmt.Sprintf("configuration wasn't reloaded: %s", err.Error()))
+		return
+	}
+	if len(changes) == 0 {
+		slog.Info("configuration reloaded, nothing changed")
+		return
+	}
+
+	var applied, ignored []string
+	levelsChanged := false
+	for _, change := range changes {
+		if !change.Reloadable {
+			ignored = append(ignored, change.String())
+			continue
+		}
+		applied = append(applied, change.String())
+		levelsChanged = levelsChanged || isLogLevelChange(change)
+	}
+	if len(applied) > 0 {
+		slog.Info(fmt.Sprintf("configuration reloaded: %s", strings.Join(applied, ", ")))
+	}
+	if len(ignored) > 0 {
+		slog.Warn(fmt.Sprintf("configuration changes that require a restart were ignored: %s", strings.Join(ignored, ", ")))
+	}
+	if levelsChanged {
+		err = logging.SetLevels(config.Current().Logging)
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to apply log levels: %s", err.Error()))
+		}
+	}
+}
+
+func isLogLevelChange(change settings.Change) bool {
+	return change.Key == "LOG_LEVEL" || change.Key == "LOG_PACKAGE_LEVELS"
+}
+
+func modificationTime(path string) time.Time {
+	if len(path) == 0 {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,155 @@
+package retention
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github-events-microservices/stores"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	archiveTimeFormat = "20060102T150405Z"
+	deleteBatchSize   = 1000
+)
+
+// Policy expires the items of a collection whose date field is older than MaxAge. A zero MaxAge keeps the items forever.
+type Policy struct {
+	Collection string
+	Field      string
+	MaxAge     time.Duration
+}
+
+// Result reports what a run did, or would do on a dry run, for a collection
+type Result struct {
+	Collection  string
+	Cutoff      time.Time
+	Expired     int64
+	Deleted     int64
+	ArchiveFile string
+}
+
+// Job prunes the expired items of every policy. When archiveDir is set, expired items are first written to a
+// gzip compressed NDJSON file, in mongo relaxed extended JSON, and only the archived items are deleted.
+type Job struct {
+	policies   []Policy
+	stores     map[string]stores.ExpiringStore
+	archiveDir string
+	dryRun     bool
+	now        func() time.Time
+}
+
+// Run applies every policy and returns the results of the policies that succeeded
+func (receiver Job) Run() ([]Result, error) {
+	var results []Result
+	var errs []error
+	for _, policy := range receiver.policies {
+		if policy.MaxAge <= 0 {
+			continue
+		}
+		store, ok := receiver.stores[policy.Collection]
+		if !ok {
+			errs = append(errs, fmt.Errorf("no store of collection '%s'", policy.Collection))
+			continue
+		}
+		result, err := receiver.apply(policy, store)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to prune '%s': %w", policy.Collection, err))
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
+
+func (receiver Job) apply(policy Policy, store stores.ExpiringStore) (Result, error) {
+	result := Result{Collection: policy.Collection, Cutoff: receiver.now().Add(-policy.MaxAge)}
+	expired, err := store.CountOlderThan(policy.Field, result.Cutoff)
+	if err != nil {
+		return result, err
+	}
+	result.Expired = expired
+	if receiver.dryRun || expired == 0 {
+		return result, nil
+	}
+
+	if len(receiver.archiveDir) == 0 {
+		result.Deleted, err = store.DeleteOlderThan(policy.Field, result.Cutoff)
+		return result, err
+	}
+
+	archiveFile := filepath.Join(receiver.archiveDir, fmt.Sprintf("%s-%s.ndjson.gz", policy.Collection, receiver.now().UTC().Format(archiveTimeFormat)))
+	ids, err := archive(store, policy, result.Cutoff, archiveFile)
+	if err != nil {
+		return result, err
+	}
+	result.ArchiveFile = archiveFile
+
+	// items expiring during the archiving stay until the next run, only the archived items are deleted
+	for start := 0; start < len(ids); start += deleteBatchSize {
+		end := min(start+deleteBatchSize, len(ids))
+		err = store.DeleteAllById(ids[start:end])
+		if err != nil {
+			return result, err
+		}
+		result.Deleted += int64(end - start)
+	}
+	return result, nil
+}
+
+// archive writes the expired items to path and returns their ids. The file is synced before returning, and removed on failure.
+func archive(store stores.ExpiringStore, policy Policy, cutoff time.Time, path string) (ids []interface{}, err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(path)
+		}
+	}()
+
+	writer := gzip.NewWriter(file)
+	err = store.ExportOlderThan(policy.Field, cutoff, func(id interface{}, document []byte) error {
+		ids = append(ids, id)
+		_, err := writer.Write(append(document, '\n'))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	err = file.Sync()
+	if err != nil {
+		return nil, err
+	}
+	err = file.Close()
+	if err != nil {
+		return nil, err
+	}
+	slog.Info(fmt.Sprintf("archived %d items of %s to %s", len(ids), policy.Collection, path))
+	return ids, nil
+}
+
+func NewJob(policies []Policy, expiringStores map[string]stores.ExpiringStore, archiveDir string, dryRun bool) (*Job, error) {
+	if len(archiveDir) > 0 && !dryRun {
+		err := os.MkdirAll(archiveDir, 0755)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create archive dir: %w", err)
+		}
+	}
+	return &Job{
+		policies:   policies,
+		stores:     expiringStores,
+		archiveDir: archiveDir,
+		dryRun:     dryRun,
+		now:        time.Now,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"github-events-microservices/stores"
+	mock_stores "github-events-microservices/stores/mocks"
+	"github.com/golang/mock/gomock"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+func newTestJob(store stores.ExpiringStore, archiveDir string, dryRun bool) *Job {
+	return &Job{
+		policies: []Policy{
+			{Collection: "events", Field: "created_at", MaxAge: 30 * 24 * time.Hour},
+			{Collection: "users", Field: "last_updated_at", MaxAge: 0},
+		},
+		stores:     map[string]stores.ExpiringStore{"events": store},
+		archiveDir: archiveDir,
+		dryRun:     dryRun,
+		now:        func() time.Time { return now },
+	}
+}
+
+func TestJob_Run(t *testing.T) {
+	cutoff := now.Add(-30 * 24 * time.Hour)
+	tests := []struct {
+		name    string
+		dryRun  bool
+		prepare func(store *mock_stores.MockExpiringStore)
+		want    Result
+	}{
+		{
+			name:   "dry run only counts",
+			dryRun: true,
+			prepare: func(store *mock_stores.MockExpiringStore) {
+				store.EXPECT().CountOlderThan("created_at", cutoff).Return(int64(3), nil)
+			},
+			want: Result{Collection: "events", Cutoff: cutoff, Expired: 3},
+		},
+		{
+			name: "nothing expired",
+			prepare: func(store *mock_stores.MockExpiringStore) {
+				store.EXPECT().CountOlderThan("created_at", cutoff).Return(int64(0), nil)
+			},
+			want: Result{Collection: "events", Cutoff: cutoff},
+		},
+		{
+			name: "deletes without archive",
+			prepare: func(store *mock_stores.MockExpiringStore) {
+				store.EXPECT().CountOlderThan("created_at", cutoff).Return(int64(3), nil)
+				store.EXPECT().DeleteOlderThan("created_at", cutoff).Return(int64(3), nil)
+			},
+			want: Result{Collection: "events", Cutoff: cutoff, Expired: 3, Deleted: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mock_stores.NewMockExpiringStore(ctrl)
+			tt.prepare(store)
+
+			results, err := newTestJob(store, "", tt.dryRun).Run()
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if !reflect.DeepEqual(results, []Result{tt.want}) {
+				t.Errorf("Run() got = %v, want %v", results, []Result{tt.want})
+			}
+		})
+	}
+}
+
+func TestJob_RunArchive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mock_stores.NewMockExpiringStore(ctrl)
+	cutoff := now.Add(-30 * 24 * time.Hour)
+	documents := []string{`{"_id":"1"}`, `{"_id":"2"}`}
+
+	store.EXPECT().CountOlderThan("created_at", cutoff).Return(int64(2), nil)
+	store.EXPECT().ExportOlderThan("created_at", cutoff, gomock.Any()).DoAndReturn(
+		func(field string, cutoff time.Time, export func(id interface{}, document []byte) error) error {
+			for i, document := range documents {
+				err := export(string(rune('1'+i)), []byte(document))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	store.EXPECT().DeleteAllById([]interface{}{"1", "2"}).Return(nil)
+
+	dir := t.TempDir()
+	results, err := newTestJob(store, dir, false).Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	archiveFile := filepath.Join(dir, "events-20240510T120000Z.ndjson.gz")
+	want := []Result{{Collection: "events", Cutoff: cutoff, Expired: 2, Deleted: 2, ArchiveFile: archiveFile}}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("Run() got = %v, want %v", results, want)
+	}
+
+	file, err := os.Open(archiveFile)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if !reflect.DeepEqual(lines, documents) {
+		t.Errorf("archive got = %v, want %v", lines, documents)
+	}
+}
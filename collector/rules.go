@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github-events-microservices/collector/config"
+	"github-events-microservices/collector/metrics"
+	"github-events-microservices/collector/rules"
+	"github-events-microservices/model"
+	"github-events-microservices/stores"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// runRules loads the alert rules every rules reload interval, which may be changed by a reload
+func runRules(ctx context.Context, ruleEngine *rules.Engine, rulesStore stores.ReadStore, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		loadRules(ruleEngine, rulesStore)
+		timer := time.NewTimer(config.Current().RulesReloadInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// loadRules keeps the current rules when they can't be read
+func loadRules(ruleEngine *rules.Engine, rulesStore stores.ReadStore) {
+	loaded := make([]model.Rule, 0)
+	err := rulesStore.All(&loaded)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to load rules: %s", err.Error()))
+		return
+	}
+	count := ruleEngine.SetRules(loaded)
+	metrics.RulesLoaded.Set(float64(count))
+	slog.Debug(fmt.Sprintf("loaded %d enabled rules of %d", count, len(loaded)))
+}
+
+func runNotifier(ctx context.Context, notifier *rules.Notifier, wg *sync.WaitGroup) {
+	defer wg.Done()
+	notifier.Run(ctx)
+}
@@ -0,0 +1,274 @@
+package rules
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github-events-microservices/collector/metrics"
+	"github-events-microservices/model"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxAlertEvents bounds the ids of the matching events sent along with an alert
+const maxAlertEvents = 20
+
+// Alert is the payload of the webhook of a fired rule
+type Alert struct {
+	ID       string `json:"id"`
+	RuleId   string `json:"rule_id"`
+	RuleName string `json:"rule_name"`
+	// Group is the repo or actor the rule fired for, when the rule groups the events
+	Group         string    `json:"group,omitempty"`
+	Count         int       `json:"count,omitempty"`
+	StarDelta     int       `json:"star_delta,omitempty"`
+	WindowSeconds int       `json:"window_seconds"`
+	FiredAt       time.Time `json:"fired_at"`
+	// EventIds are the ids of the latest matching events
+	EventIds []string `json:"event_ids,omitempty"`
+
+	webhook model.RuleWebhook
+}
+
+// Engine evaluates the enabled rules over the stored events and repos, and passes the fired alerts to the notifier.
+// A rule fires once its window reaches the condition, and the window restarts from scratch.
+type Engine struct {
+	mu       sync.Mutex
+	rules    map[string]*ruleState
+	notifier *Notifier
+	now      func() time.Time
+}
+
+type ruleState struct {
+	rule   model.Rule
+	groups map[string]*window
+}
+
+// window holds the entries of a group counted since the rule last fired, oldest first.
+// seen keeps the ids of the events within the window, so replayed batches aren't counted twice.
+type window struct {
+	entries []entry
+	seen    map[string]time.Time
+}
+
+type entry struct {
+	id    string
+	at    time.Time
+	stars int
+}
+
+// SetRules replaces the evaluated rules with the enabled valid ones and returns their number.
+// Rules whose condition didn't change keep their windows.
+func (receiver *Engine) SetRules(rules []model.Rule) int {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	next := make(map[string]*ruleState)
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		err := rule.Validate()
+		if err != nil {
+			slog.Warn(fmt.Sprintf("skipping invalid rule %s: %s", rule.ID, err.Error()))
+			continue
+		}
+		rule.Condition.Repo = strings.ToLower(rule.Condition.Repo)
+		rule.Condition.Actor = strings.ToLower(rule.Condition.Actor)
+		state, ok := receiver.rules[rule.ID]
+		if !ok || state.rule.Condition != rule.Condition {
+			state = &ruleState{groups: make(map[string]*window)}
+		}
+		state.rule = rule
+		next[rule.ID] = state
+	}
+	receiver.rules = next
+	return len(next)
+}
+
+// Evaluate adds the events and the star counts of the repos to the windows of the rules, and notifies the fired alerts
+func (receiver *Engine) Evaluate(events []model.Event, repos []model.Repo) []Alert {
+	receiver.mu.Lock()
+	now := receiver.now()
+	alerts := make([]Alert, 0)
+	for _, id := range receiver.ruleIds() {
+		state := receiver.rules[id]
+		cutoff := now.Add(-state.rule.Condition.Window())
+		if state.rule.Condition.IsStarDelta() {
+			state.addRepos(repos, now)
+		} else {
+			state.addEvents(events, cutoff)
+		}
+		alerts = append(alerts, state.evaluate(now, cutoff)...)
+	}
+	receiver.mu.Unlock()
+
+	for _, alert := range alerts {
+		metrics.RulesFired.WithLabelValues(alert.RuleId).Inc()
+		slog.Info(fmt.Sprintf("rule %s (%s) fired for '%s'", alert.RuleId, alert.RuleName, alert.Group))
+	}
+	if receiver.notifier != nil {
+		receiver.notifier.Notify(alerts)
+	}
+	return alerts
+}
+
+func (receiver *Engine) ruleIds() []string {
+	ids := make([]string, 0, len(receiver.rules))
+	for id := range receiver.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (receiver *ruleState) addEvents(events []model.Event, cutoff time.Time) {
+	condition := receiver.rule.Condition
+	for _, event := range events {
+		if !event.CreatedAt.After(cutoff) ||
+			(len(condition.EventType) > 0 && event.Type != condition.EventType) ||
+			!matches(condition.Repo, event.RepoFullName) ||
+			!matches(condition.Actor, event.ActorLogin) {
+			continue
+		}
+		group := ""
+		switch condition.GroupBy {
+		case model.GroupByRepo:
+			group = strings.ToLower(event.RepoFullName)
+		case model.GroupByActor:
+			group = strings.ToLower(event.ActorLogin)
+		}
+		receiver.group(group).addEvent(event)
+	}
+}
+
+// addRepos records the star count of the matching repos, stars are compared per repo
+func (receiver *ruleState) addRepos(repos []model.Repo, now time.Time) {
+	for _, repo := range repos {
+		fullName := repo.Owner + "/" + repo.Name
+		if !matches(receiver.rule.Condition.Repo, fullName) {
+			continue
+		}
+		receiver.group(strings.ToLower(fullName)).addStars(repo.Stars, now)
+	}
+}
+
+func (receiver *ruleState) group(key string) *window {
+	group, ok := receiver.groups[key]
+	if !ok {
+		group = &window{seen: make(map[string]time.Time)}
+		receiver.groups[key] = group
+	}
+	return group
+}
+
+// evaluate expires the entries out of the window and returns the alerts of the groups meeting the condition
+func (receiver *ruleState) evaluate(now time.Time, cutoff time.Time) []Alert {
+	condition := receiver.rule.Condition
+	keys := make([]string, 0, len(receiver.groups))
+	for key, group := range receiver.groups {
+		group.expire(cutoff)
+		if len(group.entries) == 0 && len(group.seen) == 0 {
+			delete(receiver.groups, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	alerts := make([]Alert, 0)
+	for _, key := range keys {
+		group := receiver.groups[key]
+		alert := receiver.newAlert(key, now)
+		if condition.IsStarDelta() {
+			if len(group.entries) < 2 {
+				continue
+			}
+			last := group.entries[len(group.entries)-1]
+			alert.StarDelta = last.stars - group.entries[0].stars
+			if alert.StarDelta < condition.MinStarDelta {
+				continue
+			}
+			// the stars grow from the last count on
+			group.entries = []entry{last}
+		} else {
+			if len(group.entries) < condition.MinCount {
+				continue
+			}
+			alert.Count = len(group.entries)
+			for i := max(0, len(group.entries)-maxAlertEvents); i < len(group.entries); i++ {
+				alert.EventIds = append(alert.EventIds, group.entries[i].id)
+			}
+			group.entries = nil
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+func (receiver *ruleState) newAlert(group string, now time.Time) Alert {
+	return Alert{
+		ID:            newAlertId(),
+		RuleId:        receiver.rule.ID,
+		RuleName:      receiver.rule.Name,
+		Group:         group,
+		WindowSeconds: receiver.rule.Condition.WindowSeconds,
+		FiredAt:       now.UTC(),
+		webhook:       receiver.rule.Webhook,
+	}
+}
+
+func (receiver *window) addEvent(event model.Event) {
+	_, ok := receiver.seen[event.ID]
+	if ok {
+		return
+	}
+	receiver.seen[event.ID] = event.CreatedAt
+	receiver.entries = append(receiver.entries, entry{id: event.ID, at: event.CreatedAt})
+}
+
+// addStars records a star count when it changed since the last one
+func (receiver *window) addStars(stars int, now time.Time) {
+	if len(receiver.entries) > 0 && receiver.entries[len(receiver.entries)-1].stars == stars {
+		return
+	}
+	receiver.entries = append(receiver.entries, entry{at: now, stars: stars})
+}
+
+func (receiver *window) expire(cutoff time.Time) {
+	kept := receiver.entries[:0]
+	for _, current := range receiver.entries {
+		if current.at.After(cutoff) {
+			kept = append(kept, current)
+		}
+	}
+	receiver.entries = kept
+	for id, at := range receiver.seen {
+		if !at.After(cutoff) {
+			delete(receiver.seen, id)
+		}
+	}
+}
+
+// matches reports whether a case-insensitive value matches a lowercase glob pattern, an empty pattern matches any value
+func matches(pattern string, value string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	matched, _ := path.Match(pattern, strings.ToLower(value))
+	return matched
+}
+
+func newAlertId() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// NewEngine creates an engine without rules. Fired alerts are delivered by the notifier, if any.
+func NewEngine(notifier *Notifier) *Engine {
+	return &Engine{rules: make(map[string]*ruleState), notifier: notifier, now: time.Now}
+}
@@ -0,0 +1,163 @@
+package rules
+
+import (
+	"fmt"
+	"github-events-microservices/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+func newRule(id string, condition model.RuleCondition) model.Rule {
+	return model.Rule{
+		ID:        id,
+		Name:      "rule " + id,
+		Enabled:   true,
+		Condition: condition,
+		Webhook:   model.RuleWebhook{Url: "https://example.com/hook", Secret: "secret"},
+	}
+}
+
+func watchEvents(repo string, count int, at time.Time) []model.Event {
+	events := make([]model.Event, count)
+	for i := range events {
+		events[i] = model.Event{ID: fmt.Sprintf("%s-%d", repo, i), Type: "WatchEvent", RepoFullName: repo, ActorLogin: "octo", CreatedAt: at}
+	}
+	return events
+}
+
+type firing struct {
+	Rule      string
+	Group     string
+	Count     int
+	StarDelta int
+}
+
+func firings(alerts []Alert) []firing {
+	fired := make([]firing, 0, len(alerts))
+	for _, alert := range alerts {
+		fired = append(fired, firing{Rule: alert.RuleId, Group: alert.Group, Count: alert.Count, StarDelta: alert.StarDelta})
+	}
+	return fired
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	starsPerHour := model.RuleCondition{EventType: "WatchEvent", Repo: "Golang/*", GroupBy: model.GroupByRepo, WindowSeconds: 3600, MinCount: 3}
+	actorPushes := model.RuleCondition{EventType: "PushEvent", Actor: "torvalds", WindowSeconds: 600, MinCount: 1}
+	starDelta := model.RuleCondition{Repo: "golang/go", WindowSeconds: 3600, MinStarDelta: 10}
+
+	type batch struct {
+		after  time.Duration
+		events []model.Event
+		repos  []model.Repo
+	}
+	tests := []struct {
+		name    string
+		rules   []model.Rule
+		batches []batch
+		want    []firing
+	}{
+		{
+			name:  "fires per repo once the count is reached",
+			rules: []model.Rule{newRule("stars", starsPerHour)},
+			batches: []batch{
+				{events: append(watchEvents("golang/go", 2, now), watchEvents("golang/tools", 2, now)...)},
+				{after: time.Minute, events: append(watchEvents("golang/go", 3, now.Add(time.Minute))[2:], watchEvents("rust-lang/rust", 5, now)...)},
+			},
+			want: []firing{{Rule: "stars", Group: "golang/go", Count: 3}},
+		},
+		{
+			name:  "replayed events are counted once",
+			rules: []model.Rule{newRule("stars", starsPerHour)},
+			batches: []batch{
+				{events: watchEvents("golang/go", 2, now)},
+				{after: time.Minute, events: watchEvents("golang/go", 2, now)},
+			},
+			want: []firing{},
+		},
+		{
+			name:  "events out of the window expire",
+			rules: []model.Rule{newRule("stars", starsPerHour)},
+			batches: []batch{
+				{events: watchEvents("golang/go", 2, now)},
+				{after: 2 * time.Hour, events: watchEvents("golang/go", 3, now.Add(2*time.Hour))[2:]},
+			},
+			want: []firing{},
+		},
+		{
+			name:  "the window restarts after firing",
+			rules: []model.Rule{newRule("push", actorPushes)},
+			batches: []batch{
+				{events: []model.Event{{ID: "1", Type: "PushEvent", ActorLogin: "Torvalds", RepoFullName: "torvalds/linux", CreatedAt: now}}},
+				{after: time.Minute},
+				{after: 2 * time.Minute, events: []model.Event{{ID: "2", Type: "PushEvent", ActorLogin: "torvalds", RepoFullName: "a/b", CreatedAt: now.Add(2 * time.Minute)}}},
+			},
+			want: []firing{{Rule: "push", Count: 1}, {Rule: "push", Count: 1}},
+		},
+		{
+			name:  "fires on the star delta of a repo",
+			rules: []model.Rule{newRule("delta", starDelta)},
+			batches: []batch{
+				{repos: []model.Repo{{ID: "R_1", Owner: "golang", Name: "go", Stars: 100}, {ID: "R_2", Owner: "golang", Name: "tools", Stars: 1}}},
+				{after: time.Minute, repos: []model.Repo{{ID: "R_1", Owner: "golang", Name: "go", Stars: 105}}},
+				{after: 2 * time.Minute, repos: []model.Repo{{ID: "R_1", Owner: "golang", Name: "go", Stars: 112}, {ID: "R_2", Owner: "golang", Name: "tools", Stars: 100}}},
+				{after: 3 * time.Minute, repos: []model.Repo{{ID: "R_1", Owner: "golang", Name: "go", Stars: 115}}},
+			},
+			want: []firing{{Rule: "delta", Group: "golang/go", StarDelta: 12}},
+		},
+		{
+			name: "disabled and invalid rules are skipped",
+			rules: []model.Rule{
+				{ID: "disabled", Condition: actorPushes, Webhook: model.RuleWebhook{Url: "https://example.com", Secret: "secret"}},
+				newRule("invalid", model.RuleCondition{WindowSeconds: 60}),
+			},
+			batches: []batch{{events: []model.Event{{ID: "1", Type: "PushEvent", ActorLogin: "torvalds", CreatedAt: now}}}},
+			want:    []firing{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine(nil)
+			engine.SetRules(tt.rules)
+			got := make([]firing, 0)
+			for _, batch := range tt.batches {
+				engine.now = func() time.Time { return now.Add(batch.after) }
+				got = append(got, firings(engine.Evaluate(batch.events, batch.repos))...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEngine_SetRules(t *testing.T) {
+	condition := model.RuleCondition{EventType: "PushEvent", WindowSeconds: 600, MinCount: 2}
+	event := func(id string) []model.Event {
+		return []model.Event{{ID: id, Type: "PushEvent", CreatedAt: now}}
+	}
+	engine := NewEngine(nil)
+	engine.now = func() time.Time { return now }
+	engine.SetRules([]model.Rule{newRule("push", condition)})
+	engine.Evaluate(event("1"), nil)
+
+	// a renamed rule keeps its window
+	renamed := newRule("push", condition)
+	renamed.Name = "renamed"
+	engine.SetRules([]model.Rule{renamed})
+	alerts := engine.Evaluate(event("2"), nil)
+	if len(alerts) != 1 || alerts[0].RuleName != "renamed" {
+		t.Fatalf("expected an alert of the renamed rule, got: %v", alerts)
+	}
+
+	// a changed condition restarts the window
+	engine.Evaluate(event("3"), nil)
+	condition.MinCount = 3
+	engine.SetRules([]model.Rule{newRule("push", condition)})
+	alerts = engine.Evaluate(append(event("4"), event("5")...), nil)
+	if len(alerts) != 0 {
+		t.Errorf("expected no alert, got: %v", alerts)
+	}
+}
@@ -0,0 +1,140 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github-events-microservices/collector/config"
+	"github-events-microservices/collector/metrics"
+	"github-events-microservices/collector/webhooks"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Signature-256"
+	AlertIdHeader   = "X-Alert-Id"
+	RuleIdHeader    = "X-Rule-Id"
+
+	// workers is the number of concurrent deliveries, so a slow webhook doesn't hold back the others
+	workers = 4
+	// maxPendingAlerts bounds the alerts waiting for a worker, newer alerts are dropped when full
+	maxPendingAlerts = 1000
+	maxAlertBackoff  = time.Minute
+	// maxResponseBytes bounds the response body read to reuse the connection
+	maxResponseBytes = 64 << 10
+)
+
+// Notifier delivers the alerts to the webhooks of their rules. Payloads are signed with the secret of the webhook
+// like GitHub signs its deliveries, and retried with exponential backoff on network errors, 408, 429 and 5xx responses.
+// Retries keep the alert id, so receivers can deduplicate them.
+type Notifier struct {
+	client  *http.Client
+	pending chan Alert
+}
+
+// Notify queues the alerts for delivery without blocking
+func (receiver *Notifier) Notify(alerts []Alert) {
+	for _, alert := range alerts {
+		select {
+		case receiver.pending <- alert:
+		default:
+			slog.Warn(fmt.Sprintf("dropped alert %s of rule %s: too many pending alerts", alert.ID, alert.RuleId))
+			metrics.AlertDeliveries.WithLabelValues("dropped").Inc()
+		}
+	}
+}
+
+// Run delivers the queued alerts until ctx is done. Pending alerts are dropped on shutdown.
+func (receiver *Notifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case alert := <-receiver.pending:
+					receiver.deliver(ctx, alert)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (receiver *Notifier) deliver(ctx context.Context, alert Alert) {
+	err := receiver.send(ctx, alert)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to deliver alert %s of rule %s: %s", alert.ID, alert.RuleId, err.Error()))
+		metrics.AlertDeliveries.WithLabelValues("failed").Inc()
+		return
+	}
+	slog.Debug(fmt.Sprintf("delivered alert %s of rule %s", alert.ID, alert.RuleId))
+	metrics.AlertDeliveries.WithLabelValues("delivered").Inc()
+}
+
+// send posts the alert, up to ALERT_MAX_ATTEMPTS times
+func (receiver *Notifier) send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	configuration := config.Current()
+	backoff := configuration.AlertInitialBackoff
+	for attempt := 1; ; attempt++ {
+		retryable, err := receiver.post(ctx, alert, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= configuration.AlertMaxAttempts {
+			return fmt.Errorf("attempt %d: %w", attempt, err)
+		}
+		slog.Debug(fmt.Sprintf("retrying alert %s in %s: %s", alert.ID, backoff, err.Error()))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("attempt %d: %w", attempt, err)
+		}
+		backoff = min(2*backoff, maxAlertBackoff)
+	}
+}
+
+// post sends the alert once and reports whether a failure is worth retrying
+func (receiver *Notifier) post(ctx context.Context, alert Alert, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.Current().AlertTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, alert.webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "github-events-collector")
+	request.Header.Set(AlertIdHeader, alert.ID)
+	request.Header.Set(RuleIdHeader, alert.RuleId)
+	request.Header.Set(SignatureHeader, webhooks.Sign(alert.webhook.Secret, body))
+
+	response, err := receiver.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseBytes))
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusRequestTimeout
+	return retryable, fmt.Errorf("webhook responded %s", response.Status)
+}
+
+func NewNotifier(client *http.Client) *Notifier {
+	return &Notifier{client: client, pending: make(chan Alert, maxPendingAlerts)}
+}
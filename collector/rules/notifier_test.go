@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"github-events-microservices/collector/config"
+	"github-events-microservices/collector/webhooks"
+	"github-events-microservices/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestNotifier_send(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "test")
+	t.Setenv("ALERT_MAX_ATTEMPTS", "3")
+	t.Setenv("ALERT_INITIAL_BACKOFF_MS", "0")
+	_, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "delivered", statuses: []int{http.StatusNoContent}, wantAttempts: 1},
+		{name: "retried on server errors", statuses: []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK}, wantAttempts: 3},
+		{name: "gives up after the max attempts", statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}, wantAttempts: 3, wantErr: true},
+		{name: "client errors are not retried", statuses: []int{http.StatusNotFound}, wantAttempts: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var alertIds []string
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				body, _ := io.ReadAll(request.Body)
+				if request.Header.Get(SignatureHeader) != webhooks.Sign("secret", body) {
+					t.Errorf("invalid signature: %s", request.Header.Get(SignatureHeader))
+				}
+				var alert Alert
+				err := json.Unmarshal(body, &alert)
+				if err != nil || alert.ID != request.Header.Get(AlertIdHeader) || alert.RuleId != "stars" {
+					t.Errorf("unexpected payload: %s", body)
+				}
+				alertIds = append(alertIds, alert.ID)
+				writer.WriteHeader(tt.statuses[min(len(alertIds), len(tt.statuses))-1])
+			}))
+			defer server.Close()
+
+			alert := Alert{ID: "alert-1", RuleId: "stars", Count: 3, webhook: model.RuleWebhook{Url: server.URL, Secret: "secret"}}
+			err := NewNotifier(server.Client()).send(context.Background(), alert)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+			if len(alertIds) != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got: %d", tt.wantAttempts, len(alertIds))
+			}
+			for _, id := range alertIds {
+				if id != alert.ID {
+					t.Errorf("expected retries to keep the alert id, got: %s", id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package sinks
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBroker is an in-process broker. Like a broker with idempotent producers,
+// it keeps a single message per key. Failures can be injected with Fail.
+type MemoryBroker struct {
+	mu       sync.Mutex
+	messages []Message
+	keys     map[string]bool
+	fail     error
+}
+
+func (receiver *MemoryBroker) PublishAll(ctx context.Context, messages []Message) error {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if receiver.fail != nil {
+		return receiver.fail
+	}
+	for _, message := range messages {
+		if receiver.keys[message.Key] {
+			continue
+		}
+		receiver.keys[message.Key] = true
+		receiver.messages = append(receiver.messages, message)
+	}
+	return ctx.Err()
+}
+
+// Messages returns the published messages, in order
+func (receiver *MemoryBroker) Messages() []Message {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return append([]Message(nil), receiver.messages...)
+}
+
+// Fail makes the next publications fail with err, until called with nil
+func (receiver *MemoryBroker) Fail(err error) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.fail = err
+}
+
+func (receiver *MemoryBroker) Close() error {
+	return nil
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{keys: make(map[string]bool)}
+}
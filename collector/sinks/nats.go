@@ -0,0 +1,67 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"log/slog"
+	"strings"
+)
+
+// NatsPublisher publishes to a NATS JetStream stream. The message key is sent as the Nats-Msg-Id header,
+// so the stream drops the messages published again within its duplicates window.
+type NatsPublisher struct {
+	connection *nats.Conn
+	jetStream  nats.JetStreamContext
+}
+
+func (receiver NatsPublisher) PublishAll(ctx context.Context, messages []Message) error {
+	futures := make([]nats.PubAckFuture, 0, len(messages))
+	for _, message := range messages {
+		future, err := receiver.jetStream.PublishMsgAsync(&nats.Msg{Subject: message.Topic, Data: message.Data}, nats.MsgId(message.Key))
+		if err != nil {
+			return err
+		}
+		futures = append(futures, future)
+	}
+
+	var errs []error
+	for _, future := range futures {
+		select {
+		case <-future.Ok():
+		case err := <-future.Err():
+			errs = append(errs, fmt.Errorf("%s: %w", future.Msg().Header.Get(nats.MsgIdHdr), err))
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for acknowledgements: %w", ctx.Err())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (receiver NatsPublisher) Close() error {
+	return receiver.connection.Drain()
+}
+
+// NewNatsPublisher connects to url and creates the stream of the subjects, comma separated, if it doesn't exist
+func NewNatsPublisher(url string, stream string, subjects string) (*NatsPublisher, error) {
+	connection, err := nats.Connect(url, nats.Name("events-collector"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	jetStream, err := connection.JetStream()
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+	_, err = jetStream.StreamInfo(stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		slog.Info(fmt.Sprintf("creating nats stream %s", stream))
+		_, err = jetStream.AddStream(&nats.StreamConfig{Name: stream, Subjects: strings.Split(subjects, ",")})
+	}
+	if err != nil {
+		connection.Close()
+		return nil, fmt.Errorf("failed to ensure nats stream %s: %w", stream, err)
+	}
+	return &NatsPublisher{connection: connection, jetStream: jetStream}, nil
+}
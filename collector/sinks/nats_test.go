@@ -0,0 +1,53 @@
+package sinks
+
+import (
+	"context"
+	"github-events-microservices/model"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"testing"
+	"time"
+)
+
+func TestNatsPublisher_PublishAll(t *testing.T) {
+	natsServer, err := server.NewServer(&server.Options{Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create nats server: %v", err)
+	}
+	natsServer.Start()
+	defer natsServer.Shutdown()
+	if !natsServer.ReadyForConnections(5 * time.Second) {
+		t.Fatalf("nats server isn't ready")
+	}
+
+	publisher, err := NewNatsPublisher(natsServer.ClientURL(), "GITHUB_EVENTS", "github.events.>")
+	if err != nil {
+		t.Fatalf("NewNatsPublisher() error = %v", err)
+	}
+	defer publisher.Close()
+	router, _ := NewRouter("github.events.{type}.{owner}.{repo}")
+	sink := NewBrokerSink(TypeNats, publisher, router, 5*time.Second)
+
+	events := []model.Event{{ID: "1", Type: "PushEvent", RepoFullName: "golang/go"}, {ID: "2", Type: "ForkEvent", RepoFullName: "golang/tools"}}
+	for i := 0; i < 2; i++ {
+		err = sink.Publish(context.Background(), events)
+		if err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+
+	info, err := publisher.jetStream.StreamInfo("GITHUB_EVENTS")
+	if err != nil {
+		t.Fatalf("StreamInfo() error = %v", err)
+	}
+	if info.State.Msgs != 2 {
+		t.Errorf("stream has %d messages, want 2 as the republished events are duplicates", info.State.Msgs)
+	}
+	message, err := publisher.jetStream.GetLastMsg("GITHUB_EVENTS", "github.events.ForkEvent.golang.tools")
+	if err != nil {
+		t.Fatalf("GetLastMsg() error = %v", err)
+	}
+	if message.Header.Get(nats.MsgIdHdr) != "2" {
+		t.Errorf("message id got = %s, want 2", message.Header.Get(nats.MsgIdHdr))
+	}
+}
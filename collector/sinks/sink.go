@@ -0,0 +1,175 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github-events-microservices/collector/metrics"
+	"github-events-microservices/model"
+	"strings"
+	"time"
+)
+
+const (
+	TypeNone = "none"
+	TypeNats = "nats"
+)
+
+// Options configures the sink publishing the stored batches to a message broker
+type Options struct {
+	Type         string        `config:"SINK_TYPE" default:"none" oneof:"none|nats"`
+	Topic        string        `config:"SINK_TOPIC" default:"github.events.{type}" required:"true"`
+	Timeout      time.Duration `config:"SINK_TIMEOUT_SECONDS" default:"10" unit:"seconds" min:"1"`
+	NatsUrl      string        `config:"SINK_NATS_URL" default:"nats://localhost:4222"`
+	NatsStream   string        `config:"SINK_NATS_STREAM" default:"GITHUB_EVENTS"`
+	NatsSubjects string        `config:"SINK_NATS_SUBJECTS" default:"github.events.>"`
+	// QueueDir is the write-ahead queue of the stored events waiting to be published, so a broker outage doesn't stop
+	// the events from being stored
+	QueueDir      string `config:"SINK_QUEUE_DIR" default:"./sink-queue" required:"true"`
+	QueueMaxBytes int64  `config:"SINK_QUEUE_MAX_SIZE_MB" default:"256" unit:"mb" min:"1"`
+}
+
+func (receiver Options) Validate() error {
+	_, err := NewRouter(receiver.Topic)
+	return err
+}
+
+// Sink publishes events to downstream consumers
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, events []model.Event) error
+	Close() error
+}
+
+// Message is a published event. Key, the event id, is the idempotency key of the message.
+type Message struct {
+	Topic string
+	Key   string
+	Data  []byte
+}
+
+// Publisher publishes messages to a broker. PublishAll returns once every message is acknowledged, or fails.
+type Publisher interface {
+	PublishAll(ctx context.Context, messages []Message) error
+	Close() error
+}
+
+// eventPayload is the published representation of an event
+type eventPayload struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	CreatedAt      time.Time `json:"created_at"`
+	Public         bool      `json:"public"`
+	RepoFullName   string    `json:"repo_full_name"`
+	RepoUrl        string    `json:"repo_url"`
+	ActorLogin     string    `json:"actor_login"`
+	ActorId        int64     `json:"actor_id"`
+	ActorUrl       string    `json:"actor_url"`
+	ActorAvatarUrl string    `json:"actor_avatar_url"`
+}
+
+// Router routes events to the topic template, e.g. github.events.{type}.{owner}.{repo}.
+// The {type}, {owner}, {repo} and {actor} placeholders are replaced by the values of the event, with dots replaced by underscores.
+type Router struct {
+	template string
+}
+
+var placeholders = []string{"{type}", "{owner}", "{repo}", "{actor}"}
+
+func (receiver Router) Topic(event model.Event) string {
+	owner, repo, _ := strings.Cut(event.RepoFullName, "/")
+	replacer := strings.NewReplacer(
+		"{type}", token(event.Type),
+		"{owner}", token(owner),
+		"{repo}", token(repo),
+		"{actor}", token(event.ActorLogin))
+	return replacer.Replace(receiver.template)
+}
+
+// token keeps a value in a single topic token
+func token(value string) string {
+	if len(value) == 0 {
+		return "_"
+	}
+	return strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_").Replace(value)
+}
+
+func NewRouter(template string) (Router, error) {
+	remaining := template
+	for _, placeholder := range placeholders {
+		remaining = strings.ReplaceAll(remaining, placeholder, "")
+	}
+	if len(strings.TrimSpace(template)) == 0 || strings.ContainsAny(remaining, "{}") {
+		return Router{}, fmt.Errorf("invalid sink topic: '%s'. Supported placeholders are %s", template, strings.Join(placeholders, ", "))
+	}
+	return Router{template: template}, nil
+}
+
+// BrokerSink publishes every event to its routed topic, with the event id as idempotency key
+type BrokerSink struct {
+	name      string
+	publisher Publisher
+	router    Router
+	timeout   time.Duration
+}
+
+func (receiver BrokerSink) Name() string {
+	return receiver.name
+}
+
+// Publish publishes the events and waits for the broker acknowledgements. Failed batches must be published again,
+// so events may be delivered more than once, consumers can drop duplicates by key.
+func (receiver BrokerSink) Publish(ctx context.Context, events []model.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	messages := make([]Message, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(eventPayload(event))
+		if err != nil {
+			return err
+		}
+		messages = append(messages, Message{Topic: receiver.router.Topic(event), Key: event.ID, Data: data})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, receiver.timeout)
+	defer cancel()
+	start := time.Now()
+	err := receiver.publisher.PublishAll(ctx, messages)
+	metrics.SinkPublishLatency.WithLabelValues(receiver.name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.SinkErrors.WithLabelValues(receiver.name).Inc()
+		return fmt.Errorf("failed to publish %d events to %s: %w", len(events), receiver.name, err)
+	}
+	metrics.SinkPublished.WithLabelValues(receiver.name).Add(float64(len(messages)))
+	return nil
+}
+
+func (receiver BrokerSink) Close() error {
+	return receiver.publisher.Close()
+}
+
+func NewBrokerSink(name string, publisher Publisher, router Router, timeout time.Duration) *BrokerSink {
+	return &BrokerSink{name: name, publisher: publisher, router: router, timeout: timeout}
+}
+
+// New connects the configured sink. It returns nil when no sink is configured.
+func New(options Options) (Sink, error) {
+	router, err := NewRouter(options.Topic)
+	if err != nil {
+		return nil, err
+	}
+	switch options.Type {
+	case TypeNats:
+		publisher, err := NewNatsPublisher(options.NatsUrl, options.NatsStream, options.NatsSubjects)
+		if err != nil {
+			return nil, err
+		}
+		return NewBrokerSink(TypeNats, publisher, router, options.Timeout), nil
+	case TypeNone, "":
+		return nil, nil
+	default:
+		return nil, errors.New("unsupported sink type: " + options.Type)
+	}
+}
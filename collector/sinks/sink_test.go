@@ -0,0 +1,89 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github-events-microservices/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRouter_Topic(t *testing.T) {
+	event := model.Event{Type: "PushEvent", RepoFullName: "golang/go.dev", ActorLogin: "gopher"}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{template: "github.events.{type}", want: "github.events.PushEvent"},
+		{template: "github.events.{type}.{owner}.{repo}", want: "github.events.PushEvent.golang.go_dev"},
+		{template: "github.actors.{actor}", want: "github.actors.gopher"},
+		{template: "github.events", want: "github.events"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			router, err := NewRouter(tt.template)
+			if err != nil {
+				t.Fatalf("NewRouter() error = %v", err)
+			}
+			if got := router.Topic(event); got != tt.want {
+				t.Errorf("Topic() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRouter_Invalid(t *testing.T) {
+	for _, template := range []string{"", "github.events.{org}", "github.{type"} {
+		_, err := NewRouter(template)
+		if err == nil {
+			t.Errorf("NewRouter(%s) expected an error", template)
+		}
+	}
+}
+
+func TestBrokerSink_Publish(t *testing.T) {
+	broker := NewMemoryBroker()
+	router, _ := NewRouter("github.events.{type}")
+	sink := NewBrokerSink("memory", broker, router, time.Second)
+	createdAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	events := []model.Event{
+		{ID: "1", Type: "PushEvent", CreatedAt: createdAt, RepoFullName: "golang/go"},
+		{ID: "2", Type: "WatchEvent", CreatedAt: createdAt, RepoFullName: "golang/go"},
+	}
+
+	broker.Fail(errors.New("broker unavailable"))
+	err := sink.Publish(context.Background(), events)
+	if err == nil {
+		t.Fatalf("Publish() expected an error")
+	}
+
+	// a failed batch is published again, with the events already published skipped by key
+	broker.Fail(nil)
+	for i := 0; i < 2; i++ {
+		err = sink.Publish(context.Background(), events)
+		if err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	messages := broker.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("published %d messages, want 2", len(messages))
+	}
+	if messages[0].Topic != "github.events.PushEvent" || messages[0].Key != "1" || messages[1].Topic != "github.events.WatchEvent" {
+		t.Errorf("unexpected messages %v", messages)
+	}
+	var payload map[string]interface{}
+	err = json.Unmarshal(messages[0].Data, &payload)
+	if err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	want := map[string]interface{}{
+		"id": "1", "type": "PushEvent", "created_at": "2024-05-10T12:00:00Z", "public": false,
+		"repo_full_name": "golang/go", "repo_url": "", "actor_login": "", "actor_id": float64(0), "actor_url": "", "actor_avatar_url": "",
+	}
+	if !reflect.DeepEqual(payload, want) {
+		t.Errorf("payload got = %v, want %v", payload, want)
+	}
+}
@@ -0,0 +1,385 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github-events-microservices/collector/metrics"
+	"github-events-microservices/model"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	signatureHeader = "X-Hub-Signature-256"
+	eventHeader     = "X-GitHub-Event"
+	deliveryHeader  = "X-GitHub-Delivery"
+	signaturePrefix = "sha256="
+
+	// maxPayloadBytes is the maximum size of a webhook payload sent by GitHub
+	maxPayloadBytes = 25 << 20
+	// maxDeliveries bounds the recent delivery ids kept to deduplicate redeliveries
+	maxDeliveries = 10000
+	// maxDeliveredEvents bounds the delivered events kept to drop the same events once polled
+	maxDeliveredEvents = 10000
+	// deliveredSkew is the largest difference between the time of a delivered event, read from its payload, and the
+	// created_at of the same polled event
+	deliveredSkew = time.Minute
+	// deliveredRule is the filter rule label of the polled events dropped as delivered by the webhooks
+	deliveredRule = "webhooks"
+)
+
+// eventTypes maps the supported webhook events to the type of the matching events api event
+var eventTypes = map[string]string{
+	"push":         "PushEvent",
+	"pull_request": "PullRequestEvent",
+	"issues":       "IssuesEvent",
+	"release":      "ReleaseEvent",
+	"star":         "WatchEvent",
+	"fork":         "ForkEvent",
+}
+
+// eventActions lists the actions of the supported events that have one, which the events api also emits. Other
+// actions, like edits, labels or release drafts, are ignored, so an event isn't stored once per change of its subject.
+var eventActions = map[string][]string{
+	"pull_request": {"opened", "closed", "reopened"},
+	"issues":       {"opened", "closed", "reopened"},
+	"release":      {"published"},
+	"star":         {"created"},
+}
+
+// payload holds the fields of a webhook delivery common to the supported events, and the time of each of them
+type payload struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string    `json:"full_name"`
+		Url      string    `json:"url"`
+		Private  bool      `json:"private"`
+		PushedAt timestamp `json:"pushed_at"`
+	} `json:"repository"`
+	Sender struct {
+		Login     string `json:"login"`
+		ID        int64  `json:"id"`
+		Url       string `json:"url"`
+		AvatarUrl string `json:"avatar_url"`
+	} `json:"sender"`
+	StarredAt   timestamp `json:"starred_at"`
+	PullRequest struct {
+		UpdatedAt timestamp `json:"updated_at"`
+	} `json:"pull_request"`
+	Issue struct {
+		UpdatedAt timestamp `json:"updated_at"`
+	} `json:"issue"`
+	Release struct {
+		PublishedAt timestamp `json:"published_at"`
+	} `json:"release"`
+	Forkee struct {
+		CreatedAt timestamp `json:"created_at"`
+	} `json:"forkee"`
+}
+
+// createdAt returns the time of the action of a delivery, zero when the payload doesn't have it
+func (receiver payload) createdAt(eventName string) time.Time {
+	switch eventName {
+	case "push":
+		return time.Time(receiver.Repository.PushedAt)
+	case "pull_request":
+		return time.Time(receiver.PullRequest.UpdatedAt)
+	case "issues":
+		return time.Time(receiver.Issue.UpdatedAt)
+	case "release":
+		return time.Time(receiver.Release.PublishedAt)
+	case "star":
+		return time.Time(receiver.StarredAt)
+	case "fork":
+		return time.Time(receiver.Forkee.CreatedAt)
+	default:
+		return time.Time{}
+	}
+}
+
+// timestamp is a time of a payload, RFC 3339 or, like the pushed_at of push deliveries, seconds since the epoch
+type timestamp time.Time
+
+func (receiver *timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var seconds int64
+	if json.Unmarshal(data, &seconds) == nil {
+		*receiver = timestamp(time.Unix(seconds, 0))
+		return nil
+	}
+	var value time.Time
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	*receiver = timestamp(value)
+	return nil
+}
+
+// Handler receives GitHub webhook deliveries, verifies their signature and passes them as events to enqueue.
+// Deliveries are identified by their X-GitHub-Delivery id, which is also the id of the event, so redeliveries are dropped.
+// The feeds don't share these ids, so the handler also records the events it delivers, and Undelivered drops the same
+// events once polled.
+type Handler struct {
+	secret     func() string
+	enqueue    func([]model.Event) error
+	now        func() time.Time
+	deliveries *recentSet
+	delivered  *deliveredEvents
+}
+
+func (receiver Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	eventName := request.Header.Get(eventHeader)
+	body, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxPayloadBytes))
+	if err != nil {
+		receiver.reject(writer, eventName, "invalid_payload", http.StatusBadRequest, fmt.Sprintf("failed to read payload: %s", err.Error()))
+		return
+	}
+	if !verify(receiver.secret(), body, request.Header.Get(signatureHeader)) {
+		receiver.reject(writer, eventName, "invalid_signature", http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	deliveryId := request.Header.Get(deliveryHeader)
+	if len(deliveryId) == 0 {
+		receiver.reject(writer, eventName, "invalid_payload", http.StatusBadRequest, fmt.Sprintf("missing %s header", deliveryHeader))
+		return
+	}
+	if receiver.deliveries.contains(deliveryId) {
+		metrics.WebhookDeliveries.WithLabelValues(eventName, "duplicate").Inc()
+		writer.WriteHeader(http.StatusOK)
+		return
+	}
+
+	event, ok, err := receiver.toEvent(eventName, deliveryId, body)
+	if err != nil {
+		receiver.reject(writer, eventName, "invalid_payload", http.StatusBadRequest, fmt.Sprintf("invalid %s payload: %s", eventName, err.Error()))
+		return
+	}
+	if !ok {
+		metrics.WebhookDeliveries.WithLabelValues(eventName, "ignored").Inc()
+		writer.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	err = receiver.enqueue([]model.Event{event})
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to enqueue webhook delivery %s: %s", deliveryId, err.Error()))
+		metrics.WebhookDeliveries.WithLabelValues(eventName, "error").Inc()
+		// GitHub doesn't retry failed deliveries automatically, but they can be redelivered
+		http.Error(writer, "failed to enqueue the delivery", http.StatusServiceUnavailable)
+		return
+	}
+	receiver.deliveries.add(deliveryId)
+	receiver.delivered.add(event)
+	metrics.WebhookDeliveries.WithLabelValues(eventName, "accepted").Inc()
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+// toEvent maps a delivery of a supported event and action of a public repo to an event. Other events, like ping, other
+// actions, like star deletions, and the deliveries of private repos, which aren't served as public events, are ignored.
+func (receiver Handler) toEvent(eventName string, deliveryId string, body []byte) (model.Event, bool, error) {
+	eventType, ok := eventTypes[eventName]
+	if !ok {
+		return model.Event{}, false, nil
+	}
+	var delivery payload
+	err := json.Unmarshal(body, &delivery)
+	if err != nil {
+		return model.Event{}, false, err
+	}
+	if actions, ok := eventActions[eventName]; ok && !slices.Contains(actions, delivery.Action) {
+		return model.Event{}, false, nil
+	}
+	if delivery.Repository.Private {
+		return model.Event{}, false, nil
+	}
+	if len(delivery.Repository.FullName) == 0 || len(delivery.Sender.Login) == 0 {
+		return model.Event{}, false, fmt.Errorf("missing repository or sender")
+	}
+	createdAt := delivery.createdAt(eventName)
+	if createdAt.IsZero() {
+		createdAt = receiver.now()
+	}
+	return model.Event{
+		ID:             deliveryId,
+		Type:           eventType,
+		CreatedAt:      createdAt.UTC(),
+		Public:         true,
+		RepoFullName:   delivery.Repository.FullName,
+		RepoUrl:        delivery.Repository.Url,
+		ActorLogin:     delivery.Sender.Login,
+		ActorId:        delivery.Sender.ID,
+		ActorUrl:       delivery.Sender.Url,
+		ActorAvatarUrl: delivery.Sender.AvatarUrl,
+	}, true, nil
+}
+
+// Undelivered returns the polled events but the ones already delivered by the webhooks, which are stored from their
+// deliveries. A polled event is the same as a delivered one when they have the same type, repo and actor, and their
+// times are at most deliveredSkew apart. Every delivered event drops one polled event at most.
+func (receiver Handler) Undelivered(events []model.Event) []model.Event {
+	undelivered := make([]model.Event, 0, len(events))
+	for _, event := range events {
+		if receiver.delivered.take(event) {
+			metrics.EventsFiltered.WithLabelValues(deliveredRule).Inc()
+			continue
+		}
+		undelivered = append(undelivered, event)
+	}
+	return undelivered
+}
+
+func (receiver Handler) reject(writer http.ResponseWriter, eventName string, result string, status int, message string) {
+	slog.Warn(fmt.Sprintf("rejected webhook delivery: %s", message))
+	metrics.WebhookDeliveries.WithLabelValues(eventName, result).Inc()
+	http.Error(writer, message, status)
+}
+
+// verify checks the HMAC SHA-256 signature of the payload, in constant time
+func verify(secret string, body []byte, signature string) bool {
+	if len(secret) == 0 || !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Sign returns the X-Hub-Signature-256 header value of a payload
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// recentSet is a bounded set of recent ids, the oldest ids are evicted first
+type recentSet struct {
+	mu    sync.Mutex
+	ids   map[string]bool
+	order []string
+	max   int
+}
+
+func (receiver *recentSet) contains(id string) bool {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return receiver.ids[id]
+}
+
+func (receiver *recentSet) add(id string) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if receiver.ids[id] {
+		return
+	}
+	if len(receiver.order) >= receiver.max {
+		delete(receiver.ids, receiver.order[0])
+		receiver.order = receiver.order[1:]
+	}
+	receiver.ids[id] = true
+	receiver.order = append(receiver.order, id)
+}
+
+// deliveredEvents is a bounded set of the times of recent delivered events, by type, repo and actor. The oldest
+// deliveries are evicted first.
+type deliveredEvents struct {
+	mu    sync.Mutex
+	times map[string][]time.Time
+	order []deliveredEvent
+	max   int
+}
+
+type deliveredEvent struct {
+	key       string
+	createdAt time.Time
+}
+
+// deliveredKey identifies the events of a type, repo and actor, repo names and logins are case insensitive
+func deliveredKey(event model.Event) string {
+	return event.Type + " " + strings.ToLower(event.RepoFullName) + " " + strings.ToLower(event.ActorLogin)
+}
+
+func (receiver *deliveredEvents) add(event model.Event) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if len(receiver.order) >= receiver.max {
+		oldest := receiver.order[0]
+		receiver.order = receiver.order[1:]
+		receiver.remove(oldest.key, oldest.createdAt)
+	}
+	key := deliveredKey(event)
+	receiver.times[key] = append(receiver.times[key], event.CreatedAt)
+	receiver.order = append(receiver.order, deliveredEvent{key: key, createdAt: event.CreatedAt})
+}
+
+// take removes the delivered event closest in time to a polled event, and reports whether there was one within
+// deliveredSkew
+func (receiver *deliveredEvents) take(event model.Event) bool {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	key := deliveredKey(event)
+	closest := -1
+	for i, createdAt := range receiver.times[key] {
+		if skew(createdAt, event.CreatedAt) <= deliveredSkew && (closest < 0 || skew(createdAt, event.CreatedAt) < skew(receiver.times[key][closest], event.CreatedAt)) {
+			closest = i
+		}
+	}
+	if closest < 0 {
+		return false
+	}
+	receiver.remove(key, receiver.times[key][closest])
+	return true
+}
+
+// remove removes a time of the delivered events of a key, if it's still there
+func (receiver *deliveredEvents) remove(key string, createdAt time.Time) {
+	times := receiver.times[key]
+	for i := range times {
+		if times[i].Equal(createdAt) {
+			times = append(times[:i], times[i+1:]...)
+			break
+		}
+	}
+	if len(times) == 0 {
+		delete(receiver.times, key)
+	} else {
+		receiver.times[key] = times
+	}
+}
+
+func skew(a time.Time, b time.Time) time.Duration {
+	if a.After(b) {
+		return a.Sub(b)
+	}
+	return b.Sub(a)
+}
+
+// NewHandler creates a webhook handler. The secret is read on every delivery, so it can be rotated by a reload.
+func NewHandler(secret func() string, enqueue func([]model.Event) error) *Handler {
+	return &Handler{
+		secret:     secret,
+		enqueue:    enqueue,
+		now:        time.Now,
+		deliveries: &recentSet{ids: make(map[string]bool), max: maxDeliveries},
+		delivered:  &deliveredEvents{times: make(map[string][]time.Time), max: maxDeliveredEvents},
+	}
+}
@@ -0,0 +1,238 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github-events-microservices/model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testSecret = "It's a Secret to Everybody"
+
+var now = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	codertocat := model.Event{
+		Public:         true,
+		RepoFullName:   "Codertocat/Hello-World",
+		RepoUrl:        "https://api.github.com/repos/Codertocat/Hello-World",
+		ActorLogin:     "Codertocat",
+		ActorId:        21031067,
+		ActorUrl:       "https://api.github.com/users/Codertocat",
+		ActorAvatarUrl: "https://avatars1.githubusercontent.com/u/21031067?v=4",
+	}
+	tests := []struct {
+		name       string
+		event      string
+		payload    string
+		wantStatus int
+		wantType   string
+		wantActor  string
+		// wantCreatedAt is the time of the action in the payload
+		wantCreatedAt string
+	}{
+		{name: "push", event: "push", payload: "push.json", wantStatus: http.StatusAccepted, wantType: "PushEvent", wantCreatedAt: "2019-05-15T15:20:57Z"},
+		{name: "pull request", event: "pull_request", payload: "pull_request.json", wantStatus: http.StatusAccepted, wantType: "PullRequestEvent", wantCreatedAt: "2019-05-15T15:20:33Z"},
+		{name: "issues", event: "issues", payload: "issues.json", wantStatus: http.StatusAccepted, wantType: "IssuesEvent", wantCreatedAt: "2019-05-15T15:20:18Z"},
+		{name: "release", event: "release", payload: "release.json", wantStatus: http.StatusAccepted, wantType: "ReleaseEvent", wantCreatedAt: "2019-05-15T15:20:53Z"},
+		{name: "star", event: "star", payload: "star.json", wantStatus: http.StatusAccepted, wantType: "WatchEvent", wantCreatedAt: "2019-05-15T15:20:40Z"},
+		{name: "fork", event: "fork", payload: "fork.json", wantStatus: http.StatusAccepted, wantType: "ForkEvent", wantActor: "Octocoders", wantCreatedAt: "2019-05-15T15:19:25Z"},
+		{name: "star deleted is ignored", event: "star", payload: "star_deleted.json", wantStatus: http.StatusAccepted},
+		{name: "issue edited is ignored", event: "issues", payload: "issues_edited.json", wantStatus: http.StatusAccepted},
+		{name: "release created is ignored", event: "release", payload: "release_created.json", wantStatus: http.StatusAccepted},
+		{name: "private repo is ignored", event: "push", payload: "push_private.json", wantStatus: http.StatusAccepted},
+		{name: "ping is ignored", event: "ping", payload: "ping.json", wantStatus: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var enqueued []model.Event
+			handler := newTestHandler(func(events []model.Event) error {
+				enqueued = append(enqueued, events...)
+				return nil
+			})
+			body := readPayload(t, tt.payload)
+
+			recorder := deliver(handler, tt.event, "delivery-1", body, Sign(testSecret, body))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("ServeHTTP() status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if len(tt.wantType) == 0 {
+				if len(enqueued) > 0 {
+					t.Errorf("ServeHTTP() enqueued = %v, want none", enqueued)
+				}
+				return
+			}
+			want := codertocat
+			want.ID = "delivery-1"
+			want.Type = tt.wantType
+			want.CreatedAt, _ = time.Parse(time.RFC3339, tt.wantCreatedAt)
+			if len(tt.wantActor) > 0 {
+				want.ActorLogin = tt.wantActor
+				want.ActorId = 38302899
+				want.ActorUrl = "https://api.github.com/users/Octocoders"
+				want.ActorAvatarUrl = "https://avatars1.githubusercontent.com/u/38302899?v=4"
+			}
+			if !reflect.DeepEqual(enqueued, []model.Event{want}) {
+				t.Errorf("ServeHTTP() enqueued = %v, want %v", enqueued, want)
+			}
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_Rejected(t *testing.T) {
+	body := readPayload(t, "push.json")
+	tests := []struct {
+		name       string
+		method     string
+		delivery   string
+		signature  string
+		wantStatus int
+	}{
+		{name: "missing signature", delivery: "1", wantStatus: http.StatusUnauthorized},
+		{name: "wrong secret", delivery: "1", signature: Sign("other", body), wantStatus: http.StatusUnauthorized},
+		{name: "sha1 signature", delivery: "1", signature: "sha1=" + Sign(testSecret, body)[7:], wantStatus: http.StatusUnauthorized},
+		{name: "missing delivery", signature: Sign(testSecret, body), wantStatus: http.StatusBadRequest},
+		{name: "not a post", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestHandler(func(events []model.Event) error {
+				t.Errorf("unexpected enqueue of %v", events)
+				return nil
+			})
+			request := httptest.NewRequest(http.MethodPost, "/webhooks/github", bytes.NewReader(body))
+			if len(tt.method) > 0 {
+				request.Method = tt.method
+			}
+			request.Header.Set(eventHeader, "push")
+			request.Header.Set(deliveryHeader, tt.delivery)
+			request.Header.Set(signatureHeader, tt.signature)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_Redelivery(t *testing.T) {
+	enqueued := 0
+	failing := true
+	handler := newTestHandler(func(events []model.Event) error {
+		if failing {
+			return errors.New("queue is full")
+		}
+		enqueued += len(events)
+		return nil
+	})
+	body := readPayload(t, "push.json")
+	signature := Sign(testSecret, body)
+
+	// failed deliveries can be redelivered
+	if recorder := deliver(handler, "push", "delivery-1", body, signature); recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ServeHTTP() status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+	failing = false
+	for i := 0; i < 2; i++ {
+		if recorder := deliver(handler, "push", "delivery-1", body, signature); recorder.Code >= 300 {
+			t.Fatalf("ServeHTTP() status = %d", recorder.Code)
+		}
+	}
+	if enqueued != 1 {
+		t.Errorf("enqueued %d events, want 1", enqueued)
+	}
+}
+
+func TestHandler_Undelivered(t *testing.T) {
+	handler := newTestHandler(func([]model.Event) error { return nil })
+	pushedAt := time.Date(2019, 5, 15, 15, 20, 57, 0, time.UTC)
+	polled := []model.Event{
+		{ID: "1", Type: "PushEvent", RepoFullName: "codertocat/hello-world", ActorLogin: "codertocat", CreatedAt: pushedAt.Add(2 * time.Second)},
+		{ID: "2", Type: "PushEvent", RepoFullName: "Codertocat/Hello-World", ActorLogin: "Codertocat", CreatedAt: pushedAt.Add(time.Hour)},
+		{ID: "3", Type: "PushEvent", RepoFullName: "Codertocat/Hello-World", ActorLogin: "octocat", CreatedAt: pushedAt},
+		{ID: "4", Type: "WatchEvent", RepoFullName: "Codertocat/Hello-World", ActorLogin: "Codertocat", CreatedAt: pushedAt},
+		{ID: "5", Type: "PushEvent", RepoFullName: "Codertocat/Hello-World", ActorLogin: "Codertocat", CreatedAt: pushedAt},
+	}
+	if undelivered := handler.Undelivered(polled); !reflect.DeepEqual(undelivered, polled) {
+		t.Fatalf("Undelivered() = %v, want all the events before any delivery", undelivered)
+	}
+
+	body := readPayload(t, "push.json")
+	deliver(handler, "push", "delivery-1", body, Sign(testSecret, body))
+	if undelivered := handler.Undelivered(polled); !reflect.DeepEqual(undelivered, polled[1:]) {
+		t.Errorf("Undelivered() = %v, want the events but the delivered push", undelivered)
+	}
+	if undelivered := handler.Undelivered(polled); !reflect.DeepEqual(undelivered, polled) {
+		t.Errorf("Undelivered() = %v, want all the events once the delivered push was polled", undelivered)
+	}
+}
+
+func TestDeliveredEvents_Evicts(t *testing.T) {
+	delivered := &deliveredEvents{times: make(map[string][]time.Time), max: 2}
+	events := []model.Event{
+		{Type: "PushEvent", RepoFullName: "a/b", ActorLogin: "a", CreatedAt: now},
+		{Type: "PushEvent", RepoFullName: "a/b", ActorLogin: "a", CreatedAt: now.Add(time.Hour)},
+		{Type: "PushEvent", RepoFullName: "a/c", ActorLogin: "a", CreatedAt: now},
+	}
+	for _, event := range events {
+		delivered.add(event)
+	}
+	if delivered.take(events[0]) || !delivered.take(events[1]) || !delivered.take(events[2]) {
+		t.Errorf("deliveredEvents = %v, want the last 2 events", delivered.times)
+	}
+}
+
+func TestTimestamp_UnmarshalJSON(t *testing.T) {
+	var delivery payload
+	err := json.Unmarshal([]byte(`{"repository": {"pushed_at": 1557933657}, "pull_request": {"updated_at": null}}`), &delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2019, 5, 15, 15, 20, 57, 0, time.UTC)
+	if !delivery.createdAt("push").Equal(want) || !delivery.createdAt("pull_request").IsZero() {
+		t.Errorf("createdAt() = %s and %s, want %s and none", delivery.createdAt("push"), delivery.createdAt("pull_request"), want)
+	}
+}
+
+func TestRecentSet_Evicts(t *testing.T) {
+	recent := &recentSet{ids: make(map[string]bool), max: 2}
+	recent.add("1")
+	recent.add("2")
+	recent.add("3")
+	if recent.contains("1") || !recent.contains("2") || !recent.contains("3") {
+		t.Errorf("recentSet = %v, want 2 and 3", recent.order)
+	}
+}
+
+func newTestHandler(enqueue func([]model.Event) error) *Handler {
+	handler := NewHandler(func() string { return testSecret }, enqueue)
+	handler.now = func() time.Time { return now }
+	return handler
+}
+
+func deliver(handler *Handler, event string, delivery string, body []byte, signature string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/webhooks/github", bytes.NewReader(body))
+	request.Header.Set(eventHeader, event)
+	request.Header.Set(deliveryHeader, delivery)
+	request.Header.Set(signatureHeader, signature)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func readPayload(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read payload: %v", err)
+	}
+	return body
+}
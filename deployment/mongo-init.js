@@ -1,15 +1,4 @@
-let error = true
-
-let res = [
-    db.events.drop(),
-    db.events.createIndex({ 'event.created_at': -1 }),
-    db.repos.createIndex({ 'last_updated_at': -1 }),
-    db.users.createIndex({ 'last_updated_at': -1 }),
-]
-
-printjson(res)
-
-if (error) {
-    print('Error, exiting')
-    quit(1)
-}
\ No newline at end of file
+// indexes are created by the collector and the api at startup, see ENSURE_INDEXES
+db.createCollection('events')
+db.createCollection('repos')
+db.createCollection('users')
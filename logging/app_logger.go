@@ -1,16 +1,60 @@
 package logging
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
-func Create() *slog.Logger {
-	opts := &slog.HandlerOptions{
-		Level:     slog.LevelDebug,
+const (
+	FormatText = "text"
+	FormatJson = "json"
+
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+	OutputBoth   = "both"
+)
+
+type Options struct {
+	Level         string `config:"LOG_LEVEL" default:"info" reload:"true"`
+	Format        string `config:"LOG_FORMAT" default:"text" oneof:"text|json"`
+	Output        string `config:"LOG_OUTPUT" default:"both" oneof:"stdout|file|both"`
+	File          string `config:"LOG_FILE" default:"./log.log"`
+	MaxSizeMb     int    `config:"LOG_MAX_SIZE_MB" default:"100" min:"0"`
+	MaxAgeDays    int    `config:"LOG_MAX_AGE_DAYS" default:"7" min:"0"`
+	PackageLevels string `config:"LOG_PACKAGE_LEVELS" reload:"true"` // comma separated overrides, e.g. "clients=debug,stores=warn"
+}
+
+// Validate reports the invalid levels
+func (receiver Options) Validate() error {
+	_, levelErr := ParseLevel(receiver.Level)
+	_, packageLevelsErr := parsePackageLevels(receiver.PackageLevels)
+	return errors.Join(levelErr, packageLevelsErr)
+}
+
+// Create builds the application logger. The logger levels can be changed at runtime through LevelHandler.
+func Create(options Options) (*slog.Logger, error) {
+	level, err := ParseLevel(options.Level)
+	if err != nil {
+		return nil, err
+	}
+	packageLevels, err := parsePackageLevels(options.PackageLevels)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := createWriter(options)
+	if err != nil {
+		return nil, err
+	}
+
+	handlerOptions := &slog.HandlerOptions{
+		// levels are filtered by the package levels handler
+		Level:     slog.Level(-8),
 		AddSource: true,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
 			if a.Key == slog.SourceKey {
@@ -23,12 +67,81 @@ func Create() *slog.Logger {
 		},
 	}
 
-	openLogfile, err := os.OpenFile("./log.log", os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0666)
+	var handler slog.Handler
+	switch options.Format {
+	case FormatJson:
+		handler = slog.NewJSONHandler(writer, handlerOptions)
+	case FormatText, "":
+		handler = slog.NewTextHandler(writer, handlerOptions)
+	default:
+		return nil, fmt.Errorf("unknown log format: '%s'. Supported formats are: %s, %s", options.Format, FormatText, FormatJson)
+	}
+
+	AppLevels.set(level, packageLevels)
+	return slog.New(newLevelsHandler(handler, AppLevels)), nil
+}
+
+// SetLevels replaces the global and package levels, including the ones changed through LevelHandler
+func SetLevels(options Options) error {
+	level, err := ParseLevel(options.Level)
+	if err != nil {
+		return err
+	}
+	packageLevels, err := parsePackageLevels(options.PackageLevels)
+	if err != nil {
+		return err
+	}
+	AppLevels.set(level, packageLevels)
+	return nil
+}
+
+func createWriter(options Options) (io.Writer, error) {
+	switch options.Output {
+	case OutputStdout:
+		return os.Stdout, nil
+	case OutputFile:
+		return openLogFile(options)
+	case OutputBoth, "":
+		file, err := openLogFile(options)
+		if err != nil {
+			return nil, err
+		}
+		return io.MultiWriter(file, os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unknown log output: '%s'. Supported outputs are: %s, %s, %s", options.Output, OutputStdout, OutputFile, OutputBoth)
+	}
+}
+
+func openLogFile(options Options) (io.Writer, error) {
+	maxSize := int64(options.MaxSizeMb) * 1024 * 1024
+	maxAge := time.Duration(options.MaxAgeDays) * 24 * time.Hour
+	return NewRotatingFile(options.File, maxSize, maxAge)
+}
+
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(strings.TrimSpace(value)))
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		os.Exit(1)
+		return level, fmt.Errorf("invalid log level: '%s'", value)
 	}
+	return level, nil
+}
 
-	handler := slog.NewTextHandler(io.MultiWriter(openLogfile, os.Stdout), opts)
-	return slog.New(handler)
+func parsePackageLevels(value string) (map[string]slog.Level, error) {
+	packageLevels := make(map[string]slog.Level)
+	if len(strings.TrimSpace(value)) == 0 {
+		return packageLevels, nil
+	}
+	for _, override := range strings.Split(value, ",") {
+		packageAndLevel := strings.Split(override, "=")
+		if len(packageAndLevel) != 2 || len(strings.TrimSpace(packageAndLevel[0])) == 0 {
+			return nil, fmt.Errorf("invalid package log level: '%s'. Expected <package>=<level>", override)
+		}
+		level, err := ParseLevel(packageAndLevel[1])
+		if err != nil {
+			return nil, err
+		}
+		packageLevels[strings.TrimSpace(packageAndLevel[0])] = level
+	}
+	return packageLevels, nil
 }
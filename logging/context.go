@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type attrsKey struct{}
+
+const (
+	RequestIdKey = "request_id"
+	BatchIdKey   = "batch_id"
+)
+
+// WithAttrs returns a context whose records, logged with the slog *Context functions, get the given attributes
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing := attrsFromContext(ctx)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return WithAttrs(ctx, slog.String(RequestIdKey, requestId))
+}
+
+func WithBatchId(ctx context.Context, batchId string) context.Context {
+	return WithAttrs(ctx, slog.String(BatchIdKey, batchId))
+}
+
+func attrsFromContext(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
@@ -0,0 +1,166 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Levels holds the global log level and the per-package overrides
+type Levels struct {
+	mu       sync.RWMutex
+	global   slog.Level
+	packages map[string]slog.Level
+	cache    sync.Map // pc -> package path
+}
+
+type levelsReport struct {
+	Level    string            `json:"level"`
+	Packages map[string]string `json:"packages"`
+}
+
+var AppLevels = &Levels{global: slog.LevelInfo, packages: make(map[string]slog.Level)}
+
+func (receiver *Levels) set(global slog.Level, packages map[string]slog.Level) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.global = global
+	receiver.packages = packages
+}
+
+func (receiver *Levels) SetLevel(level slog.Level) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.global = level
+}
+
+func (receiver *Levels) SetPackageLevel(packageName string, level slog.Level) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.packages[packageName] = level
+}
+
+// minLevel is the lowest level that may be logged by any package
+func (receiver *Levels) minLevel() slog.Level {
+	receiver.mu.RLock()
+	defer receiver.mu.RUnlock()
+	level := receiver.global
+	for _, packageLevel := range receiver.packages {
+		if packageLevel < level {
+			level = packageLevel
+		}
+	}
+	return level
+}
+
+// levelOf returns the level of the package the record was logged from.
+// An override matches either the full package path or its last element.
+func (receiver *Levels) levelOf(pc uintptr) slog.Level {
+	receiver.mu.RLock()
+	defer receiver.mu.RUnlock()
+	if len(receiver.packages) == 0 || pc == 0 {
+		return receiver.global
+	}
+	packagePath := receiver.packagePath(pc)
+	for packageName, level := range receiver.packages {
+		if packagePath == packageName || strings.HasSuffix(packagePath, "/"+packageName) {
+			return level
+		}
+	}
+	return receiver.global
+}
+
+func (receiver *Levels) packagePath(pc uintptr) string {
+	cached, ok := receiver.cache.Load(pc)
+	if ok {
+		return cached.(string)
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	// e.g. github-events-microservices/collector/clients.GithubStoreClient.write
+	function := frame.Function
+	lastSlash := strings.LastIndex(function, "/")
+	packagePath := function
+	if dot := strings.Index(function[lastSlash+1:], "."); dot >= 0 {
+		packagePath = function[:lastSlash+1+dot]
+	}
+	receiver.cache.Store(pc, packagePath)
+	return packagePath
+}
+
+func (receiver *Levels) report() levelsReport {
+	receiver.mu.RLock()
+	defer receiver.mu.RUnlock()
+	report := levelsReport{Level: receiver.global.String(), Packages: make(map[string]string)}
+	for packageName, level := range receiver.packages {
+		report.Packages[packageName] = level.String()
+	}
+	return report
+}
+
+// levelsHandler filters records by the level of the package they were logged from and
+// adds the attributes stored in the record context
+type levelsHandler struct {
+	handler slog.Handler
+	levels  *Levels
+}
+
+func (receiver levelsHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= receiver.levels.minLevel()
+}
+
+func (receiver levelsHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level < receiver.levels.levelOf(record.PC) {
+		return nil
+	}
+	record.AddAttrs(attrsFromContext(ctx)...)
+	return receiver.handler.Handle(ctx, record)
+}
+
+func (receiver levelsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelsHandler{handler: receiver.handler.WithAttrs(attrs), levels: receiver.levels}
+}
+
+func (receiver levelsHandler) WithGroup(name string) slog.Handler {
+	return levelsHandler{handler: receiver.handler.WithGroup(name), levels: receiver.levels}
+}
+
+func newLevelsHandler(handler slog.Handler, levels *Levels) slog.Handler {
+	return levelsHandler{handler: handler, levels: levels}
+}
+
+// LevelHandler reports the log levels on GET and changes them on PUT/POST, e.g. `?level=debug&package=clients`.
+// Without a package, the global level is changed.
+func LevelHandler(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		level, err := ParseLevel(request.URL.Query().Get("level"))
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte(fmt.Sprintf("{\"error\":%q}", err.Error())))
+			return
+		}
+		packageName := request.URL.Query().Get("package")
+		if len(packageName) == 0 {
+			AppLevels.SetLevel(level)
+		} else {
+			AppLevels.SetPackageLevel(packageName, level)
+		}
+		slog.Info(fmt.Sprintf("log level changed: package: '%s', level: %s", packageName, level))
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	bytes, err := json.Marshal(AppLevels.report())
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, _ = writer.Write(bytes)
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLevelsHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		global   slog.Level
+		packages map[string]slog.Level
+		level    slog.Level
+		logged   bool
+	}{
+		{name: "above global level", global: slog.LevelInfo, packages: map[string]slog.Level{}, level: slog.LevelWarn, logged: true},
+		{name: "below global level", global: slog.LevelInfo, packages: map[string]slog.Level{}, level: slog.LevelDebug, logged: false},
+		{name: "package override by name", global: slog.LevelInfo, packages: map[string]slog.Level{"logging": slog.LevelDebug}, level: slog.LevelDebug, logged: true},
+		{name: "package override by path", global: slog.LevelInfo, packages: map[string]slog.Level{"github-events-microservices/logging": slog.LevelError}, level: slog.LevelWarn, logged: false},
+		{name: "other package override", global: slog.LevelInfo, packages: map[string]slog.Level{"clients": slog.LevelDebug}, level: slog.LevelDebug, logged: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			levels := &Levels{}
+			levels.set(tt.global, tt.packages)
+			var buffer bytes.Buffer
+			logger := slog.New(newLevelsHandler(slog.NewTextHandler(&buffer, &slog.HandlerOptions{Level: slog.Level(-8)}), levels))
+
+			logger.Log(context.Background(), tt.level, "message")
+
+			if logged := buffer.Len() > 0; logged != tt.logged {
+				t.Errorf("logged = %v, want %v", logged, tt.logged)
+			}
+		})
+	}
+}
+
+func TestLevelsHandler_ContextAttrs(t *testing.T) {
+	levels := &Levels{}
+	levels.set(slog.LevelInfo, map[string]slog.Level{})
+	var buffer bytes.Buffer
+	logger := slog.New(newLevelsHandler(slog.NewTextHandler(&buffer, nil), levels))
+
+	ctx := WithBatchId(WithRequestId(context.Background(), "request-1"), "batch-1")
+	logger.InfoContext(ctx, "message")
+
+	for _, want := range []string{"request_id=request-1", "batch_id=batch-1"} {
+		if !strings.Contains(buffer.String(), want) {
+			t.Errorf("record %q doesn't contain %q", buffer.String(), want)
+		}
+	}
+}
+
+func TestParsePackageLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]slog.Level
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: map[string]slog.Level{}},
+		{name: "overrides", value: "clients=debug, stores=WARN", want: map[string]slog.Level{"clients": slog.LevelDebug, "stores": slog.LevelWarn}},
+		{name: "missing level", value: "clients", wantErr: true},
+		{name: "invalid level", value: "clients=verbose", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePackageLevels(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePackageLevels() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parsePackageLevels() = %v, want %v", got, tt.want)
+			}
+			for packageName, level := range tt.want {
+				if got[packageName] != level {
+					t.Errorf("parsePackageLevels()[%s] = %v, want %v", packageName, got[packageName], level)
+				}
+			}
+		})
+	}
+}
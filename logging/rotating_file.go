@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile appends to a log file and rotates it once it exceeds maxSize bytes.
+// Rotated files are renamed to <name>.<timestamp><ext> and removed after maxAge.
+// A zero maxSize or maxAge disables rotation or pruning.
+type RotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	file    *os.File
+	size    int64
+}
+
+func (receiver *RotatingFile) Write(bytes []byte) (int, error) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	if receiver.maxSize > 0 && receiver.size > 0 && receiver.size+int64(len(bytes)) > receiver.maxSize {
+		err := receiver.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	written, err := receiver.file.Write(bytes)
+	receiver.size += int64(written)
+	return written, err
+}
+
+func (receiver *RotatingFile) Close() error {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return receiver.file.Close()
+}
+
+func (receiver *RotatingFile) rotate() error {
+	err := receiver.file.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(receiver.path, receiver.backupPath(time.Now()))
+	if err != nil {
+		return err
+	}
+	err = receiver.open()
+	if err != nil {
+		return err
+	}
+	receiver.prune()
+	return nil
+}
+
+func (receiver *RotatingFile) open() error {
+	file, err := os.OpenFile(receiver.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	receiver.file = file
+	receiver.size = info.Size()
+	return nil
+}
+
+func (receiver *RotatingFile) backupPath(now time.Time) string {
+	extension := filepath.Ext(receiver.path)
+	base := strings.TrimSuffix(receiver.path, extension)
+	return fmt.Sprintf("%s.%s%s", base, now.Format(backupTimeFormat), extension)
+}
+
+// prune removes the backups older than maxAge
+func (receiver *RotatingFile) prune() {
+	if receiver.maxAge <= 0 {
+		return
+	}
+	extension := filepath.Ext(receiver.path)
+	base := strings.TrimSuffix(receiver.path, extension)
+	backups, err := filepath.Glob(base + ".*" + extension)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-receiver.maxAge)
+	for _, backup := range backups {
+		if backup == receiver.path {
+			continue
+		}
+		info, err := os.Stat(backup)
+		if err == nil && info.ModTime().Before(cutoff) {
+			_ = os.Remove(backup)
+		}
+	}
+}
+
+func NewRotatingFile(path string, maxSize int64, maxAge time.Duration) (*RotatingFile, error) {
+	file := &RotatingFile{path: path, maxSize: maxSize, maxAge: maxAge}
+	err := file.open()
+	if err != nil {
+		return nil, err
+	}
+	file.prune()
+	return file, nil
+}
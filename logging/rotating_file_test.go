@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_AppendsOnReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.log")
+	for i := 0; i < 2; i++ {
+		file, err := NewRotatingFile(path, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = file.Write([]byte("line\n"))
+		_ = file.Close()
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "line\nline\n" {
+		t.Errorf("content = %q, want both lines", string(content))
+	}
+}
+
+func TestRotatingFile_RotatesBySizeAndAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.log")
+	expired := filepath.Join(dir, "log.20000101T000000.000.log")
+	_ = os.WriteFile(expired, []byte("old\n"), 0666)
+	_ = os.Chtimes(expired, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
+
+	file, err := NewRotatingFile(path, 10, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	_, _ = file.Write([]byte("12345678\n"))
+	_, _ = file.Write([]byte("abcdefgh\n"))
+
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("expired backup wasn't removed")
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "log.*.log"))
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v, want 1", backups)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "abcdefgh\n" {
+		t.Errorf("content = %q, want the last line only", string(content))
+	}
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+const (
+	// BotReviewBot and BotReviewHuman override the bot detection of a user
+	BotReviewBot   = "bot"
+	BotReviewHuman = "human"
+)
+
+// BotScore is the bot detection of an actor, stored on its user. Its fields are always written, so a cleared
+// suspicion is stored too.
+type BotScore struct {
+	UserId    int64     `bson:"-"`
+	Login     string    `bson:"-"`
+	Score     int       `bson:"bot_score"`
+	Suspected bool      `bson:"bot_suspected"`
+	Reasons   []string  `bson:"bot_reasons"`
+	ScoredAt  time.Time `bson:"bot_scored_at"`
+}
+
+// IsBot reports whether a user is a bot: as reviewed, or else as suspected by the bot detection
+func (receiver User) IsBot() bool {
+	if len(receiver.BotReview) > 0 {
+		return receiver.BotReview == BotReviewBot
+	}
+	return receiver.BotSuspected
+}
@@ -24,6 +24,7 @@ type Repo struct {
 	Url           string    `bson:"url"`
 	Stars         int       `bson:"stars"`
 	LastUpdatedAt time.Time `bson:"last_updated_at"`
+	RefreshedAt   time.Time `bson:"refreshed_at,omitempty"`
 }
 
 type User struct {
@@ -32,4 +33,28 @@ type User struct {
 	Url           string    `bson:"url"`
 	AvatarUrl     string    `bson:"avatar_url"`
 	LastUpdatedAt time.Time `bson:"last_updated_at"`
+	// the profile fields are only set by the enrichment refresh
+	Name        string    `bson:"name,omitempty"`
+	Company     string    `bson:"company,omitempty"`
+	Location    string    `bson:"location,omitempty"`
+	Followers   int       `bson:"followers,omitempty"`
+	RefreshedAt time.Time `bson:"refreshed_at,omitempty"`
+	// the bot fields are only set by the bot detection of the collector, and the review ones by the api
+	BotScore      int       `bson:"bot_score,omitempty"`
+	BotSuspected  bool      `bson:"bot_suspected,omitempty"`
+	BotReasons    []string  `bson:"bot_reasons,omitempty"`
+	BotScoredAt   time.Time `bson:"bot_scored_at,omitempty"`
+	BotReview     string    `bson:"bot_review,omitempty"`
+	BotReviewedBy string    `bson:"bot_reviewed_by,omitempty"`
+	BotReviewedAt time.Time `bson:"bot_reviewed_at,omitempty"`
+}
+
+type DeadLetter struct {
+	ID         string      `bson:"_id"`
+	Collection string      `bson:"collection"`
+	ItemId     interface{} `bson:"item_id"`
+	Item       interface{} `bson:"item"`
+	Error      string      `bson:"error"`
+	Attempts   int         `bson:"attempts"`
+	FailedAt   time.Time   `bson:"failed_at"`
 }
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	GroupByRepo  = "repo"
+	GroupByActor = "actor"
+
+	// MaxRuleWindow is the longest sliding window of a rule, the events of a window are kept in memory by the collector
+	MaxRuleWindow = 24 * time.Hour
+)
+
+// Rule fires a webhook when the events matching its condition within a sliding window reach a count,
+// or when the stars of a matching repo grow by a delta
+type Rule struct {
+	ID        string        `bson:"_id" json:"id"`
+	Name      string        `bson:"name" json:"name"`
+	Enabled   bool          `bson:"enabled" json:"enabled"`
+	Condition RuleCondition `bson:"condition" json:"condition"`
+	Webhook   RuleWebhook   `bson:"webhook" json:"webhook"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+type RuleCondition struct {
+	// EventType, Repo and Actor select the matching events, Repo and Actor are case-insensitive glob patterns.
+	// Empty values match any event.
+	EventType string `bson:"event_type,omitempty" json:"event_type,omitempty"`
+	Repo      string `bson:"repo,omitempty" json:"repo,omitempty"`
+	Actor     string `bson:"actor,omitempty" json:"actor,omitempty"`
+	// GroupBy counts the events of every repo or actor apart, or all together when empty
+	GroupBy       string `bson:"group_by,omitempty" json:"group_by,omitempty"`
+	WindowSeconds int    `bson:"window_seconds" json:"window_seconds"`
+	// MinCount is the number of matching events within the window that fires the rule
+	MinCount int `bson:"min_count,omitempty" json:"min_count,omitempty"`
+	// MinStarDelta, when set, fires the rule when the stars of a matching repo grow by at least as much within the window
+	MinStarDelta int `bson:"min_star_delta,omitempty" json:"min_star_delta,omitempty"`
+}
+
+type RuleWebhook struct {
+	Url string `bson:"url" json:"url"`
+	// Secret signs the payloads, it is never returned by the api
+	Secret string `bson:"secret" json:"secret,omitempty"`
+}
+
+// Window is the sliding window of the condition
+func (receiver RuleCondition) Window() time.Duration {
+	return time.Duration(receiver.WindowSeconds) * time.Second
+}
+
+// IsStarDelta reports whether the condition is on the star delta of repos rather than on an event count
+func (receiver RuleCondition) IsStarDelta() bool {
+	return receiver.MinStarDelta > 0
+}
+
+// Validate checks the rule as submitted, the secret of the webhook included
+func (receiver Rule) Validate() error {
+	var errs []error
+	if len(strings.TrimSpace(receiver.Name)) == 0 {
+		errs = append(errs, errors.New("name is required"))
+	}
+	errs = append(errs, receiver.Condition.validate(), receiver.Webhook.validate())
+	return errors.Join(errs...)
+}
+
+func (receiver RuleCondition) validate() error {
+	var errs []error
+	if receiver.WindowSeconds <= 0 || receiver.Window() > MaxRuleWindow {
+		errs = append(errs, fmt.Errorf("window_seconds must be between 1 and %d", int(MaxRuleWindow.Seconds())))
+	}
+	_, err := path.Match(receiver.Repo, "")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid repo pattern '%s': %w", receiver.Repo, err))
+	}
+	_, err = path.Match(receiver.Actor, "")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid actor pattern '%s': %w", receiver.Actor, err))
+	}
+	if receiver.GroupBy != "" && receiver.GroupBy != GroupByRepo && receiver.GroupBy != GroupByActor {
+		errs = append(errs, fmt.Errorf("invalid group_by '%s'. Expected %s or %s", receiver.GroupBy, GroupByRepo, GroupByActor))
+	}
+	if receiver.MinStarDelta < 0 {
+		errs = append(errs, errors.New("min_star_delta must not be negative"))
+	}
+	if receiver.IsStarDelta() {
+		// stars are a property of repos, so the condition can't select events
+		if receiver.MinCount != 0 || len(receiver.EventType) > 0 || len(receiver.Actor) > 0 || (receiver.GroupBy != "" && receiver.GroupBy != GroupByRepo) {
+			errs = append(errs, errors.New("a min_star_delta condition only accepts a repo pattern"))
+		}
+	} else if receiver.MinCount < 1 {
+		errs = append(errs, errors.New("min_count or min_star_delta must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func (receiver RuleWebhook) validate() error {
+	var errs []error
+	webhookUrl, err := url.Parse(receiver.Url)
+	if err != nil || (webhookUrl.Scheme != "http" && webhookUrl.Scheme != "https") || len(webhookUrl.Host) == 0 {
+		errs = append(errs, fmt.Errorf("invalid webhook url '%s'. Expected an http or https url", receiver.Url))
+	}
+	if len(receiver.Secret) == 0 {
+		errs = append(errs, errors.New("webhook secret is required"))
+	}
+	return errors.Join(errs...)
+}
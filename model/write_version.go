@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// WriteVersionId is the id of the write version document of the meta collection
+const WriteVersionId = "write_version"
+
+// WriteVersion changes whenever the collector writes or deletes events, repos or users, so the api can tell when
+// its cached responses are stale
+type WriteVersion struct {
+	ID        string    `bson:"_id"`
+	Version   int64     `bson:"version"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// NewWriteVersion returns the version of a write at a time, versions grow with time
+func NewWriteVersion(at time.Time) WriteVersion {
+	return WriteVersion{ID: WriteVersionId, Version: at.UnixNano(), UpdatedAt: at.UTC()}
+}
@@ -0,0 +1,90 @@
+package settings
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// readFile reads a flat configuration file, keyed by the lower case env variables. Values are read as they are
+// written, e.g. an unquoted date stays a date rather than a time.
+func readFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		values, err = readYaml(content)
+	case ".toml":
+		values, err = readToml(content)
+	default:
+		return nil, fmt.Errorf("unsupported configuration file: '%s'. Supported formats are: .yaml, .yml, .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// readYaml reads the scalars of a yaml file as their source text
+func readYaml(content []byte) (map[string]string, error) {
+	parsed := make(map[string]yaml.Node)
+	err := yaml.Unmarshal(content, &parsed)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for key, node := range parsed {
+		switch {
+		case node.Kind != yaml.ScalarNode:
+			return nil, fmt.Errorf("unsupported value of '%s': only flat keys are supported", key)
+		case node.Tag == "!!null":
+			values[key] = ""
+		default:
+			values[key] = node.Value
+		}
+	}
+	return values, nil
+}
+
+// readToml reads the values of a toml file, with its dates and times formatted like they are written
+func readToml(content []byte) (map[string]string, error) {
+	parsed := make(map[string]interface{})
+	err := toml.Unmarshal(content, &parsed)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for key, value := range parsed {
+		switch value := value.(type) {
+		case map[string]interface{}, []interface{}, []map[string]interface{}:
+			return nil, fmt.Errorf("unsupported value of '%s': only flat keys are supported", key)
+		case time.Time:
+			values[key] = formatTomlTime(value)
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
+
+// formatTomlTime formats a toml date or time. The decoder marks local dates and times with the names of their zones.
+func formatTomlTime(value time.Time) string {
+	switch value.Location().String() {
+	case "date-local":
+		return value.Format(time.DateOnly)
+	case "datetime-local":
+		return value.Format("2006-01-02T15:04:05.999999999")
+	case "time-local":
+		return value.Format("15:04:05.999999999")
+	default:
+		return value.Format(time.RFC3339Nano)
+	}
+}
@@ -0,0 +1,113 @@
+package settings
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const redacted = "******"
+
+// Loaded records the value of every configuration key and where it came from
+type Loaded struct {
+	File    string
+	Entries []Entry
+}
+
+type Entry struct {
+	Key        string
+	Value      string
+	Source     string
+	Secret     bool
+	Reloadable bool
+}
+
+// Change is a changed configuration value, with secrets redacted
+type Change struct {
+	Key        string
+	Old        string
+	New        string
+	Reloadable bool
+}
+
+func (receiver Change) String() string {
+	return fmt.Sprintf("%s: '%s' -> '%s'", receiver.Key, receiver.Old, receiver.New)
+}
+
+// Print writes the configuration as KEY=value lines, with secrets and url credentials redacted
+func (receiver *Loaded) Print(writer io.Writer) error {
+	width := 0
+	for _, entry := range receiver.Entries {
+		line := len(entry.Key) + 1 + len(entry.Redacted())
+		if line > width {
+			width = line
+		}
+	}
+	for _, entry := range receiver.Entries {
+		line := fmt.Sprintf("%s=%s", entry.Key, entry.Redacted())
+		_, err := fmt.Fprintf(writer, "%-*s  # %s\n", width, line, entry.Source)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Diff lists the values that differ in next
+func (receiver *Loaded) Diff(next *Loaded) []Change {
+	previous := make(map[string]Entry)
+	for _, entry := range receiver.Entries {
+		previous[entry.Key] = entry
+	}
+	var changes []Change
+	for _, entry := range next.Entries {
+		old := previous[entry.Key]
+		if old.Value == entry.Value {
+			continue
+		}
+		oldValue, newValue := old.Redacted(), entry.Redacted()
+		if entry.Secret {
+			oldValue, newValue = "<redacted>", "<changed>"
+		}
+		changes = append(changes, Change{Key: entry.Key, Old: oldValue, New: newValue, Reloadable: entry.Reloadable})
+	}
+	return changes
+}
+
+// Reloaded returns the receiver entries, with the reloadable ones taken from next
+func (receiver *Loaded) Reloaded(next *Loaded) *Loaded {
+	nextEntries := make(map[string]Entry)
+	for _, entry := range next.Entries {
+		nextEntries[entry.Key] = entry
+	}
+	reloaded := &Loaded{File: next.File}
+	for _, entry := range receiver.Entries {
+		nextEntry, ok := nextEntries[entry.Key]
+		if ok && nextEntry.Reloadable {
+			entry = nextEntry
+		}
+		reloaded.Entries = append(reloaded.Entries, entry)
+	}
+	return reloaded
+}
+
+// Redacted is the value to display. Secrets are masked, as well as passwords of urls.
+func (receiver Entry) Redacted() string {
+	if len(receiver.Value) == 0 {
+		return receiver.Value
+	}
+	if receiver.Secret {
+		return redacted
+	}
+	if strings.Contains(receiver.Value, "://") {
+		parsed, err := url.Parse(receiver.Value)
+		if err == nil && parsed.User != nil {
+			if _, ok := parsed.User.Password(); ok {
+				parsed.User = url.UserPassword(parsed.User.Username(), redacted)
+				return strings.Replace(parsed.String(), url.QueryEscape(redacted), redacted, 1)
+			}
+		}
+	}
+	return receiver.Value
+}
@@ -0,0 +1,356 @@
+package settings
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+
+	// ConfigFileKey is the env variable, or the lower case flag, pointing to the configuration file
+	ConfigFileKey = "CONFIG_FILE"
+)
+
+// Field tags supported by Load:
+//
+//	config:"MAX_ITEMS"  the env variable. The file key is its lower case form and the flag is --max-items
+//	default:"100"       the default value
+//	unit:"seconds"      the unit of integer values of a time.Duration (days, minutes, seconds, ms) or int64 (mb) field
+//	required:"true"     the value can't be empty
+//	min:"1"             the minimal value of an int or time.Duration field, in the field unit
+//	oneof:"a|b"         the allowed values of a string field
+//	secret:"true"       the value is redacted when printed
+//	reload:"true"       the value can be changed without a restart, see CopyReloadable
+//
+// Nested structs without a config tag are loaded recursively.
+type field struct {
+	key          string
+	value        reflect.Value
+	defaultValue string
+	unit         string
+	required     bool
+	min          string
+	oneOf        []string
+	secret       bool
+	reload       bool
+}
+
+var units = map[string]int64{
+	"":        1,
+	"days":    int64(24 * time.Hour),
+	"minutes": int64(time.Minute),
+	"seconds": int64(time.Second),
+	"ms":      int64(time.Millisecond),
+	"mb":      1024 * 1024,
+}
+
+// Load populates target, a pointer to a tagged struct, from the defaults, the configuration file, the env variables
+// and the command line flags in args, each source overriding the previous one. The file is set by --config or CONFIG_FILE
+// and may be a YAML (.yaml, .yml) or TOML (.toml) file.
+// All invalid values are reported together. The returned Loaded is usable for printing even when err isn't nil.
+func Load(target interface{}, args []string) (*Loaded, error) {
+	fields, err := collect(target)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]Entry)
+	for _, field := range fields {
+		values[field.key] = Entry{Key: field.key, Value: field.defaultValue, Source: SourceDefault, Secret: field.secret, Reloadable: field.reload}
+	}
+
+	var errs []error
+	flagValues, configFile, err := parseFlags(fields, args)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(configFile) == 0 {
+		configFile = os.Getenv(ConfigFileKey)
+	}
+	if len(configFile) > 0 {
+		fileValues, err := readFile(configFile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for key, value := range fileValues {
+			entry, ok := values[strings.ToUpper(key)]
+			if !ok {
+				errs = append(errs, fmt.Errorf("unknown key '%s' in %s", key, configFile))
+				continue
+			}
+			entry.Value, entry.Source = value, SourceFile
+			values[entry.Key] = entry
+		}
+	}
+	for _, field := range fields {
+		entry := values[field.key]
+		value, ok := os.LookupEnv(field.key)
+		if ok && len(value) > 0 {
+			entry.Value, entry.Source = value, SourceEnv
+		}
+		value, ok = flagValues[field.key]
+		if ok {
+			entry.Value, entry.Source = value, SourceFlag
+		}
+		values[field.key] = entry
+	}
+
+	loaded := &Loaded{File: configFile}
+	for _, field := range fields {
+		entry := values[field.key]
+		loaded.Entries = append(loaded.Entries, entry)
+		err := field.set(entry.Value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", field.key, entry.Source, err))
+		}
+	}
+	validator, ok := target.(Validator)
+	if ok {
+		errs = append(errs, unwrap(validator.Validate())...)
+	}
+	if len(errs) > 0 {
+		return loaded, &ValidationError{Errors: errs}
+	}
+	return loaded, nil
+}
+
+// Validator is implemented by configurations with checks beyond the field tags, e.g. across fields.
+// Load reports its errors along with the invalid fields.
+type Validator interface {
+	Validate() error
+}
+
+// Defaults populates target with the default values of its fields only. Required fields without a default are left empty.
+func Defaults(target interface{}) error {
+	fields, err := collect(target)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, field := range fields {
+		if len(field.defaultValue) == 0 {
+			continue
+		}
+		err := field.set(field.defaultValue)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", field.key, SourceDefault, err))
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// CopyReloadable copies the fields tagged reload:"true" from source into target, both pointers to the same struct type
+func CopyReloadable(target interface{}, source interface{}) error {
+	if reflect.TypeOf(target) != reflect.TypeOf(source) {
+		return fmt.Errorf("can't copy %T into %T", source, target)
+	}
+	targetFields, err := collect(target)
+	if err != nil {
+		return err
+	}
+	sourceFields, err := collect(source)
+	if err != nil {
+		return err
+	}
+	for i, field := range targetFields {
+		if field.reload {
+			field.value.Set(sourceFields[i].value)
+		}
+	}
+	return nil
+}
+
+// ValidationError aggregates all the invalid configuration values
+type ValidationError struct {
+	Errors []error
+}
+
+func (receiver *ValidationError) Error() string {
+	messages := make([]string, 0, len(receiver.Errors))
+	for _, err := range receiver.Errors {
+		messages = append(messages, "  "+err.Error())
+	}
+	return fmt.Sprintf("invalid configuration:\n%s", strings.Join(messages, "\n"))
+}
+
+func (receiver *ValidationError) Unwrap() []error {
+	return receiver.Errors
+}
+
+// unwrap flattens joined and validation errors
+func unwrap(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, wrapped := range joined.Unwrap() {
+		errs = append(errs, unwrap(wrapped)...)
+	}
+	return errs
+}
+
+func collect(target interface{}) ([]field, error) {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("configuration target must be a pointer to a struct, got %T", target)
+	}
+	return collectStruct(value.Elem())
+}
+
+func collectStruct(value reflect.Value) ([]field, error) {
+	var fields []field
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		key := structField.Tag.Get("config")
+		if len(key) == 0 {
+			if structField.Type.Kind() == reflect.Struct && structField.IsExported() {
+				nested, err := collectStruct(value.Field(i))
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, nested...)
+			}
+			continue
+		}
+		unit := structField.Tag.Get("unit")
+		if _, ok := units[unit]; !ok {
+			return nil, fmt.Errorf("unknown unit '%s' of %s", unit, key)
+		}
+		var oneOf []string
+		if values := structField.Tag.Get("oneof"); len(values) > 0 {
+			oneOf = strings.Split(values, "|")
+		}
+		fields = append(fields, field{
+			key:          key,
+			value:        value.Field(i),
+			defaultValue: structField.Tag.Get("default"),
+			unit:         unit,
+			required:     structField.Tag.Get("required") == "true",
+			min:          structField.Tag.Get("min"),
+			oneOf:        oneOf,
+			secret:       structField.Tag.Get("secret") == "true",
+			reload:       structField.Tag.Get("reload") == "true",
+		})
+	}
+	return fields, nil
+}
+
+func (receiver field) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if len(raw) == 0 {
+		if receiver.required {
+			return errors.New("a value is required")
+		}
+		receiver.value.Set(reflect.Zero(receiver.value.Type()))
+		return nil
+	}
+
+	switch receiver.value.Kind() {
+	case reflect.String:
+		if len(receiver.oneOf) > 0 && !contains(receiver.oneOf, raw) {
+			return fmt.Errorf("'%s' isn't one of: %s", raw, strings.Join(receiver.oneOf, ", "))
+		}
+		receiver.value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		parsed, err := receiver.parseInt(raw)
+		if err != nil {
+			return err
+		}
+		if len(receiver.min) > 0 {
+			min, err := receiver.parseInt(receiver.min)
+			if err != nil {
+				return fmt.Errorf("invalid min '%s': %w", receiver.min, err)
+			}
+			if parsed < min {
+				return fmt.Errorf("'%s' is lower than the minimum: %s", raw, receiver.min)
+			}
+		}
+		receiver.value.SetInt(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("'%s' isn't a boolean", raw)
+		}
+		receiver.value.SetBool(parsed)
+	default:
+		return fmt.Errorf("unsupported field type: %s", receiver.value.Type())
+	}
+	return nil
+}
+
+// parseInt parses an integer in the field unit, or a duration string such as 1m30s for time.Duration fields
+func (receiver field) parseInt(raw string) (int64, error) {
+	parsed, err := strconv.ParseInt(raw, 10, 64)
+	if err == nil {
+		return parsed * units[receiver.unit], nil
+	}
+	if receiver.value.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(raw)
+		if err == nil {
+			return int64(duration), nil
+		}
+		return 0, fmt.Errorf("'%s' isn't an integer (%s) or a duration", raw, receiver.unit)
+	}
+	return 0, fmt.Errorf("'%s' isn't an integer", raw)
+}
+
+// parseFlags parses the --<key> flags of the fields and the --config flag
+func parseFlags(fields []field, args []string) (map[string]string, string, error) {
+	flagSet := flag.NewFlagSet("", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	configFile := flagSet.String("config", "", "configuration file")
+	keys := make(map[string]string)
+	for _, field := range fields {
+		name := flagName(field.key)
+		keys[name] = field.key
+		flagSet.String(name, "", field.key)
+	}
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid flags: %w", err)
+	}
+	if flagSet.NArg() > 0 {
+		return nil, "", fmt.Errorf("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	values := make(map[string]string)
+	flagSet.Visit(func(visited *flag.Flag) {
+		key, ok := keys[visited.Name]
+		if ok {
+			values[key] = visited.Value.String()
+		}
+	})
+	return values, *configFile, nil
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net"
+)
+
+// ErrNotFound is returned when a document looked up by id doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// ErrTimeout is returned when a query runs longer than its max time
+var ErrTimeout = errors.New("query timed out")
+
+// server error codes that are resolved by retrying against a (new) primary
+var transientErrorCodes = map[int]bool{
+	6:     true, // HostUnreachable
+	7:     true, // HostNotFound
+	89:    true, // NetworkTimeout
+	91:    true, // ShutdownInProgress
+	189:   true, // PrimarySteppedDown
+	262:   true, // ExceededTimeLimit
+	9001:  true, // SocketException
+	10107: true, // NotWritablePrimary
+	11600: true, // InterruptedAtShutdown
+	11602: true, // InterruptedDueToReplStateChange
+	13435: true, // NotPrimaryNoSecondaryOk
+	13436: true, // NotPrimaryOrSecondary
+}
+
+// IsTransientError reports whether a store error is worth retrying, i.e. network failures, timeouts
+// and primary elections. Any other error, like a document validation failure, is considered permanent.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) || mongo.IsNetworkError(err) {
+		return true
+	}
+	var netError net.Error
+	if errors.As(err, &netError) {
+		return true
+	}
+	if errors.Is(err, mongo.ErrClientDisconnected) {
+		return true
+	}
+
+	var labeledError mongo.LabeledError
+	if errors.As(err, &labeledError) && (labeledError.HasErrorLabel("RetryableWriteError") || labeledError.HasErrorLabel("TransientTransactionError")) {
+		return true
+	}
+
+	var serverError mongo.ServerError
+	if errors.As(err, &serverError) {
+		for code := range transientErrorCodes {
+			if serverError.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+	return false
+}
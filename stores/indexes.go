@@ -0,0 +1,201 @@
+package stores
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"time"
+)
+
+// IndexKey is an indexed field, in ascending (1) or descending (-1) order
+type IndexKey struct {
+	Field string
+	Order int
+}
+
+// TextField is a field of a text index, the weight of a field scales the score of its matches
+type TextField struct {
+	Field  string
+	Weight int32
+}
+
+// IndexSpec declares an index. Indexes are identified by their keys, so existing indexes with other names are reused.
+// A positive ExpireAfter makes it a TTL index, which requires a single date key.
+// Text makes it a text index of the fields instead, without Keys. A collection has at most one text index.
+type IndexSpec struct {
+	Keys        []IndexKey
+	Unique      bool
+	ExpireAfter time.Duration
+	Text        []TextField
+}
+
+// IndexManager is implemented by stores that manage their indexes
+type IndexManager interface {
+	EnsureIndexes([]IndexSpec) error
+	IndexReport([]IndexSpec) (IndexReport, error)
+}
+
+// IndexReport compares the declared indexes of a collection with its existing ones
+type IndexReport struct {
+	Collection string
+	Missing    []string
+	Undeclared []string
+	Unused     []IndexUsage
+}
+
+type IndexUsage struct {
+	Name  string
+	Ops   int64
+	Since time.Time
+}
+
+// searched fields of the model types, the most relevant first
+var (
+	EventTextFields = []TextField{{Field: "repo_full_name", Weight: 5}, {Field: "actor_login", Weight: 5}, {Field: "type", Weight: 1}}
+	RepoTextFields  = []TextField{{Field: "name", Weight: 10}, {Field: "owner", Weight: 5}}
+	UserTextFields  = []TextField{{Field: "login", Weight: 10}, {Field: "name", Weight: 5}, {Field: "company", Weight: 1}, {Field: "location", Weight: 1}}
+)
+
+// indexes of the model types, matching the common filters and sort orders of the api, and the searched fields
+var (
+	EventIndexes = []IndexSpec{
+		{Keys: []IndexKey{{Field: "created_at", Order: -1}}},
+		{Keys: []IndexKey{{Field: "type", Order: 1}, {Field: "created_at", Order: -1}}},
+		{Keys: []IndexKey{{Field: "repo_full_name", Order: 1}, {Field: "created_at", Order: -1}}},
+		{Keys: []IndexKey{{Field: "actor_login", Order: 1}, {Field: "created_at", Order: -1}}},
+		{Text: EventTextFields},
+	}
+	RepoIndexes = []IndexSpec{
+		{Keys: []IndexKey{{Field: "last_updated_at", Order: -1}}},
+		{Keys: []IndexKey{{Field: "owner", Order: 1}, {Field: "name", Order: 1}}},
+		{Keys: []IndexKey{{Field: "stars", Order: -1}}},
+		{Keys: []IndexKey{{Field: "refreshed_at", Order: 1}}},
+		{Text: RepoTextFields},
+	}
+	UserIndexes = []IndexSpec{
+		{Keys: []IndexKey{{Field: "last_updated_at", Order: -1}}},
+		{Keys: []IndexKey{{Field: "login", Order: 1}}},
+		{Keys: []IndexKey{{Field: "refreshed_at", Order: 1}}},
+		{Keys: []IndexKey{{Field: "bot_score", Order: -1}}},
+		{Text: UserTextFields},
+	}
+	DeadLetterIndexes = []IndexSpec{
+		{Keys: []IndexKey{{Field: "failed_at", Order: 1}}},
+		{Keys: []IndexKey{{Field: "collection", Order: 1}, {Field: "failed_at", Order: 1}}},
+	}
+)
+
+// WithTTL returns specs with the single key index of field expiring after ttl. A zero ttl returns specs as is.
+func WithTTL(specs []IndexSpec, field string, ttl time.Duration) []IndexSpec {
+	if ttl <= 0 {
+		return specs
+	}
+	withTTL := make([]IndexSpec, 0, len(specs)+1)
+	found := false
+	for _, spec := range specs {
+		if len(spec.Keys) == 1 && spec.Keys[0].Field == field {
+			spec.ExpireAfter = ttl
+			found = true
+		}
+		withTTL = append(withTTL, spec)
+	}
+	if !found {
+		withTTL = append(withTTL, IndexSpec{Keys: []IndexKey{{Field: field, Order: 1}}, ExpireAfter: ttl})
+	}
+	return withTTL
+}
+
+// Name is the default mongo name of the index, e.g. type_1_created_at_-1 or name_text_owner_text
+func (receiver IndexSpec) Name() string {
+	parts := make([]string, 0, len(receiver.Keys)+len(receiver.Text))
+	for _, key := range receiver.Keys {
+		parts = append(parts, fmt.Sprintf("%s_%d", key.Field, key.Order))
+	}
+	for _, field := range receiver.Text {
+		parts = append(parts, field.Field+"_text")
+	}
+	return strings.Join(parts, "_")
+}
+
+func (receiver IndexSpec) model() mongo.IndexModel {
+	keys := bson.D{}
+	for _, key := range receiver.Keys {
+		keys = append(keys, bson.E{Key: key.Field, Value: key.Order})
+	}
+	indexOptions := options.Index().SetName(receiver.Name())
+	if len(receiver.Text) > 0 {
+		weights := bson.D{}
+		for _, field := range receiver.Text {
+			keys = append(keys, bson.E{Key: field.Field, Value: "text"})
+			weights = append(weights, bson.E{Key: field.Field, Value: field.Weight})
+		}
+		// logins and repo names are identifiers, they are neither stemmed nor filtered for stop words
+		indexOptions.SetWeights(weights).SetDefaultLanguage("none")
+	}
+	if receiver.Unique {
+		indexOptions.SetUnique(true)
+	}
+	if receiver.ExpireAfter > 0 {
+		indexOptions.SetExpireAfterSeconds(int32(receiver.ExpireAfter.Seconds()))
+	}
+	return mongo.IndexModel{Keys: keys, Options: indexOptions}
+}
+
+func (receiver IndexSpec) expireAfterSeconds() int32 {
+	return int32(receiver.ExpireAfter.Seconds())
+}
+
+// existingIndex is an index as listed by mongo
+type existingIndex struct {
+	Name               string `bson:"name"`
+	Key                bson.D `bson:"key"`
+	ExpireAfterSeconds *int32 `bson:"expireAfterSeconds,omitempty"`
+	// Weights are the fields of a text index, whose key is {_fts: "text", _ftsx: 1}
+	Weights bson.M `bson:"weights,omitempty"`
+}
+
+func (receiver existingIndex) matches(spec IndexSpec) bool {
+	if len(spec.Text) > 0 || len(receiver.Weights) > 0 {
+		return receiver.matchesText(spec)
+	}
+	if len(receiver.Key) != len(spec.Keys) {
+		return false
+	}
+	for i, key := range spec.Keys {
+		if receiver.Key[i].Key != key.Field || fmt.Sprint(receiver.Key[i].Value) != fmt.Sprint(key.Order) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesText compares the fields of text indexes, whatever their weights
+func (receiver existingIndex) matchesText(spec IndexSpec) bool {
+	if len(receiver.Weights) != len(spec.Text) || len(spec.Keys) > 0 {
+		return false
+	}
+	for _, field := range spec.Text {
+		if _, ok := receiver.Weights[field.Field]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (receiver existingIndex) expireAfterSeconds() int32 {
+	if receiver.ExpireAfterSeconds == nil {
+		return 0
+	}
+	return *receiver.ExpireAfterSeconds
+}
+
+func findIndex(existing []existingIndex, spec IndexSpec) (existingIndex, bool) {
+	for _, index := range existing {
+		if index.matches(spec) {
+			return index, true
+		}
+	}
+	return existingIndex{}, false
+}
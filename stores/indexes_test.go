@@ -0,0 +1,94 @@
+package stores
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+func TestIndexSpec_Name(t *testing.T) {
+	spec := IndexSpec{Keys: []IndexKey{{Field: "type", Order: 1}, {Field: "created_at", Order: -1}}}
+	if got := spec.Name(); got != "type_1_created_at_-1" {
+		t.Errorf("Name() = %s", got)
+	}
+}
+
+func TestExistingIndex_Matches(t *testing.T) {
+	spec := IndexSpec{Keys: []IndexKey{{Field: "type", Order: 1}, {Field: "created_at", Order: -1}}}
+	tests := []struct {
+		name  string
+		key   bson.D
+		match bool
+	}{
+		{name: "same keys as int32", key: bson.D{{Key: "type", Value: int32(1)}, {Key: "created_at", Value: int32(-1)}}, match: true},
+		{name: "same keys as float", key: bson.D{{Key: "type", Value: 1.0}, {Key: "created_at", Value: -1.0}}, match: true},
+		{name: "other order", key: bson.D{{Key: "type", Value: 1}, {Key: "created_at", Value: 1}}, match: false},
+		{name: "other key order", key: bson.D{{Key: "created_at", Value: -1}, {Key: "type", Value: 1}}, match: false},
+		{name: "prefix", key: bson.D{{Key: "type", Value: 1}}, match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := existingIndex{Name: "index", Key: tt.key}
+			if got := index.matches(spec); got != tt.match {
+				t.Errorf("matches() = %v, want %v", got, tt.match)
+			}
+		})
+	}
+}
+
+func TestWithTTL(t *testing.T) {
+	withTTL := WithTTL(DeadLetterIndexes, "failed_at", 24*time.Hour)
+	if len(withTTL) != len(DeadLetterIndexes) {
+		t.Fatalf("WithTTL() = %v, want the failed_at index to expire", withTTL)
+	}
+	if withTTL[0].ExpireAfter != 24*time.Hour || withTTL[1].ExpireAfter != 0 {
+		t.Errorf("WithTTL() = %v, want the single key index only to expire", withTTL)
+	}
+	if DeadLetterIndexes[0].ExpireAfter != 0 {
+		t.Errorf("WithTTL() modified the declared specs")
+	}
+
+	added := WithTTL(EventIndexes, "expires_at", time.Hour)
+	if len(added) != len(EventIndexes)+1 || added[len(added)-1].Name() != "expires_at_1" {
+		t.Errorf("WithTTL() = %v, want an expires_at index", added)
+	}
+	if len(WithTTL(EventIndexes, "created_at", 0)) != len(EventIndexes) {
+		t.Errorf("WithTTL() with zero ttl should return the specs as is")
+	}
+
+	model := withTTL[0].model()
+	if *model.Options.ExpireAfterSeconds != 86400 || *model.Options.Name != "failed_at_1" {
+		t.Errorf("model() options = %+v", model.Options)
+	}
+}
+
+func TestIndexSpec_text(t *testing.T) {
+	spec := IndexSpec{Text: RepoTextFields}
+	if got := spec.Name(); got != "name_text_owner_text" {
+		t.Errorf("Name() = %s", got)
+	}
+	model := spec.model()
+	if *model.Options.DefaultLanguage != "none" || len(model.Keys.(bson.D)) != 2 {
+		t.Errorf("model() = %+v, want a text index without language", model)
+	}
+
+	textKey := bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}}
+	tests := []struct {
+		name  string
+		index existingIndex
+		spec  IndexSpec
+		match bool
+	}{
+		{name: "same fields with other weights", index: existingIndex{Key: textKey, Weights: bson.M{"owner": int32(1), "name": int32(1)}}, spec: spec, match: true},
+		{name: "other fields", index: existingIndex{Key: textKey, Weights: bson.M{"name": int32(1)}}, spec: spec, match: false},
+		{name: "text index and key spec", index: existingIndex{Key: textKey, Weights: bson.M{"name": int32(1)}}, spec: IndexSpec{Keys: []IndexKey{{Field: "_fts", Order: 1}}}, match: false},
+		{name: "key index and text spec", index: existingIndex{Key: bson.D{{Key: "name", Value: 1}}}, spec: spec, match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.index.matches(tt.spec); got != tt.match {
+				t.Errorf("matches() = %v, want %v", got, tt.match)
+			}
+		})
+	}
+}
@@ -5,10 +5,13 @@
 package mock_stores
 
 import (
+	context "context"
 	stores "github-events-microservices/stores"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	bson "go.mongodb.org/mongo-driver/bson"
 )
 
 // MockReadStore is a mock of ReadStore interface.
@@ -77,6 +80,21 @@ func (mr *MockReadStoreMockRecorder) Count() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockReadStore)(nil).Count))
 }
 
+// CountWithContext mocks base method.
+func (m *MockReadStore) CountWithContext(arg0 context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountWithContext", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountWithContext indicates an expected call of CountWithContext.
+func (mr *MockReadStoreMockRecorder) CountWithContext(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountWithContext", reflect.TypeOf((*MockReadStore)(nil).CountWithContext), arg0)
+}
+
 // Get mocks base method.
 func (m *MockReadStore) Get(arg0 int64, arg1 stores.OrderBy, arg2 interface{}) error {
 	m.ctrl.T.Helper()
@@ -91,6 +109,34 @@ func (mr *MockReadStoreMockRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockReadStore)(nil).Get), arg0, arg1, arg2)
 }
 
+// GetWithContext mocks base method.
+func (m *MockReadStore) GetWithContext(arg0 context.Context, arg1 int64, arg2 stores.OrderBy, arg3 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithContext", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetWithContext indicates an expected call of GetWithContext.
+func (mr *MockReadStoreMockRecorder) GetWithContext(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithContext", reflect.TypeOf((*MockReadStore)(nil).GetWithContext), arg0, arg1, arg2, arg3)
+}
+
+// Ping mocks base method.
+func (m *MockReadStore) Ping() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockReadStoreMockRecorder) Ping() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockReadStore)(nil).Ping))
+}
+
 // MockReadWriteStore is a mock of ReadWriteStore interface.
 type MockReadWriteStore struct {
 	ctrl     *gomock.Controller
@@ -157,6 +203,35 @@ func (mr *MockReadWriteStoreMockRecorder) Count() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockReadWriteStore)(nil).Count))
 }
 
+// CountWithContext mocks base method.
+func (m *MockReadWriteStore) CountWithContext(arg0 context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountWithContext", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountWithContext indicates an expected call of CountWithContext.
+func (mr *MockReadWriteStoreMockRecorder) CountWithContext(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountWithContext", reflect.TypeOf((*MockReadWriteStore)(nil).CountWithContext), arg0)
+}
+
+// DeleteAllById mocks base method.
+func (m *MockReadWriteStore) DeleteAllById(arg0 []interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAllById", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAllById indicates an expected call of DeleteAllById.
+func (mr *MockReadWriteStoreMockRecorder) DeleteAllById(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAllById", reflect.TypeOf((*MockReadWriteStore)(nil).DeleteAllById), arg0)
+}
+
 // Get mocks base method.
 func (m *MockReadWriteStore) Get(arg0 int64, arg1 stores.OrderBy, arg2 interface{}) error {
 	m.ctrl.T.Helper()
@@ -171,6 +246,34 @@ func (mr *MockReadWriteStoreMockRecorder) Get(arg0, arg1, arg2 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockReadWriteStore)(nil).Get), arg0, arg1, arg2)
 }
 
+// GetWithContext mocks base method.
+func (m *MockReadWriteStore) GetWithContext(arg0 context.Context, arg1 int64, arg2 stores.OrderBy, arg3 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithContext", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetWithContext indicates an expected call of GetWithContext.
+func (mr *MockReadWriteStoreMockRecorder) GetWithContext(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithContext", reflect.TypeOf((*MockReadWriteStore)(nil).GetWithContext), arg0, arg1, arg2, arg3)
+}
+
+// Ping mocks base method.
+func (m *MockReadWriteStore) Ping() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockReadWriteStoreMockRecorder) Ping() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockReadWriteStore)(nil).Ping))
+}
+
 // Save mocks base method.
 func (m *MockReadWriteStore) Save(arg0 interface{}) error {
 	m.ctrl.T.Helper()
@@ -199,6 +302,20 @@ func (mr *MockReadWriteStoreMockRecorder) SaveAll(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAll", reflect.TypeOf((*MockReadWriteStore)(nil).SaveAll), arg0)
 }
 
+// SaveAllWithContext mocks base method.
+func (m *MockReadWriteStore) SaveAllWithContext(arg0 context.Context, arg1 []interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAllWithContext", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAllWithContext indicates an expected call of SaveAllWithContext.
+func (mr *MockReadWriteStoreMockRecorder) SaveAllWithContext(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAllWithContext", reflect.TypeOf((*MockReadWriteStore)(nil).SaveAllWithContext), arg0, arg1)
+}
+
 // UpdateAllById mocks base method.
 func (m *MockReadWriteStore) UpdateAllById(arg0 map[interface{}]interface{}) error {
 	m.ctrl.T.Helper()
@@ -212,3 +329,586 @@ func (mr *MockReadWriteStoreMockRecorder) UpdateAllById(arg0 interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAllById", reflect.TypeOf((*MockReadWriteStore)(nil).UpdateAllById), arg0)
 }
+
+// UpdateAllByIdWithContext mocks base method.
+func (m *MockReadWriteStore) UpdateAllByIdWithContext(arg0 context.Context, arg1 map[interface{}]interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAllByIdWithContext", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAllByIdWithContext indicates an expected call of UpdateAllByIdWithContext.
+func (mr *MockReadWriteStoreMockRecorder) UpdateAllByIdWithContext(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAllByIdWithContext", reflect.TypeOf((*MockReadWriteStore)(nil).UpdateAllByIdWithContext), arg0, arg1)
+}
+
+// MockDocumentStore is a mock of DocumentStore interface.
+type MockDocumentStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockDocumentStoreMockRecorder
+}
+
+// MockDocumentStoreMockRecorder is the mock recorder for MockDocumentStore.
+type MockDocumentStoreMockRecorder struct {
+	mock *MockDocumentStore
+}
+
+// NewMockDocumentStore creates a new mock instance.
+func NewMockDocumentStore(ctrl *gomock.Controller) *MockDocumentStore {
+	mock := &MockDocumentStore{ctrl: ctrl}
+	mock.recorder = &MockDocumentStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDocumentStore) EXPECT() *MockDocumentStoreMockRecorder {
+	return m.recorder
+}
+
+// All mocks base method.
+func (m *MockDocumentStore) All(arg0 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "All", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// All indicates an expected call of All.
+func (mr *MockDocumentStoreMockRecorder) All(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "All", reflect.TypeOf((*MockDocumentStore)(nil).All), arg0)
+}
+
+// Close mocks base method.
+func (m *MockDocumentStore) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockDocumentStoreMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockDocumentStore)(nil).Close))
+}
+
+// Count mocks base method.
+func (m *MockDocumentStore) Count() (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockDocumentStoreMockRecorder) Count() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockDocumentStore)(nil).Count))
+}
+
+// CountWithContext mocks base method.
+func (m *MockDocumentStore) CountWithContext(arg0 context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountWithContext", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountWithContext indicates an expected call of CountWithContext.
+func (mr *MockDocumentStoreMockRecorder) CountWithContext(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountWithContext", reflect.TypeOf((*MockDocumentStore)(nil).CountWithContext), arg0)
+}
+
+// DeleteAllById mocks base method.
+func (m *MockDocumentStore) DeleteAllById(arg0 []interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAllById", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAllById indicates an expected call of DeleteAllById.
+func (mr *MockDocumentStoreMockRecorder) DeleteAllById(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAllById", reflect.TypeOf((*MockDocumentStore)(nil).DeleteAllById), arg0)
+}
+
+// DeleteById mocks base method.
+func (m *MockDocumentStore) DeleteById(id interface{}) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteById", id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteById indicates an expected call of DeleteById.
+func (mr *MockDocumentStoreMockRecorder) DeleteById(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteById", reflect.TypeOf((*MockDocumentStore)(nil).DeleteById), id)
+}
+
+// FindById mocks base method.
+func (m *MockDocumentStore) FindById(id, result interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", id, result)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FindById indicates an expected call of FindById.
+func (mr *MockDocumentStoreMockRecorder) FindById(id, result interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockDocumentStore)(nil).FindById), id, result)
+}
+
+// FindByIdWithContext mocks base method.
+func (m *MockDocumentStore) FindByIdWithContext(ctx context.Context, id, result interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByIdWithContext", ctx, id, result)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FindByIdWithContext indicates an expected call of FindByIdWithContext.
+func (mr *MockDocumentStoreMockRecorder) FindByIdWithContext(ctx, id, result interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByIdWithContext", reflect.TypeOf((*MockDocumentStore)(nil).FindByIdWithContext), ctx, id, result)
+}
+
+// Get mocks base method.
+func (m *MockDocumentStore) Get(arg0 int64, arg1 stores.OrderBy, arg2 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockDocumentStoreMockRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockDocumentStore)(nil).Get), arg0, arg1, arg2)
+}
+
+// GetWithContext mocks base method.
+func (m *MockDocumentStore) GetWithContext(arg0 context.Context, arg1 int64, arg2 stores.OrderBy, arg3 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithContext", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetWithContext indicates an expected call of GetWithContext.
+func (mr *MockDocumentStoreMockRecorder) GetWithContext(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithContext", reflect.TypeOf((*MockDocumentStore)(nil).GetWithContext), arg0, arg1, arg2, arg3)
+}
+
+// Ping mocks base method.
+func (m *MockDocumentStore) Ping() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockDocumentStoreMockRecorder) Ping() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockDocumentStore)(nil).Ping))
+}
+
+// ReplaceById mocks base method.
+func (m *MockDocumentStore) ReplaceById(id, document interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceById", id, document)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceById indicates an expected call of ReplaceById.
+func (mr *MockDocumentStoreMockRecorder) ReplaceById(id, document interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceById", reflect.TypeOf((*MockDocumentStore)(nil).ReplaceById), id, document)
+}
+
+// Save mocks base method.
+func (m *MockDocumentStore) Save(arg0 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockDocumentStoreMockRecorder) Save(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockDocumentStore)(nil).Save), arg0)
+}
+
+// SaveAll mocks base method.
+func (m *MockDocumentStore) SaveAll(arg0 []interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAll", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAll indicates an expected call of SaveAll.
+func (mr *MockDocumentStoreMockRecorder) SaveAll(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAll", reflect.TypeOf((*MockDocumentStore)(nil).SaveAll), arg0)
+}
+
+// SaveAllWithContext mocks base method.
+func (m *MockDocumentStore) SaveAllWithContext(arg0 context.Context, arg1 []interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAllWithContext", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAllWithContext indicates an expected call of SaveAllWithContext.
+func (mr *MockDocumentStoreMockRecorder) SaveAllWithContext(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAllWithContext", reflect.TypeOf((*MockDocumentStore)(nil).SaveAllWithContext), arg0, arg1)
+}
+
+// UpdateAllById mocks base method.
+func (m *MockDocumentStore) UpdateAllById(arg0 map[interface{}]interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAllById", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAllById indicates an expected call of UpdateAllById.
+func (mr *MockDocumentStoreMockRecorder) UpdateAllById(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAllById", reflect.TypeOf((*MockDocumentStore)(nil).UpdateAllById), arg0)
+}
+
+// UpdateAllByIdWithContext mocks base method.
+func (m *MockDocumentStore) UpdateAllByIdWithContext(arg0 context.Context, arg1 map[interface{}]interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAllByIdWithContext", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAllByIdWithContext indicates an expected call of UpdateAllByIdWithContext.
+func (mr *MockDocumentStoreMockRecorder) UpdateAllByIdWithContext(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAllByIdWithContext", reflect.TypeOf((*MockDocumentStore)(nil).UpdateAllByIdWithContext), arg0, arg1)
+}
+
+// MockFilteredStore is a mock of FilteredStore interface.
+type MockFilteredStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockFilteredStoreMockRecorder
+}
+
+// MockFilteredStoreMockRecorder is the mock recorder for MockFilteredStore.
+type MockFilteredStoreMockRecorder struct {
+	mock *MockFilteredStore
+}
+
+// NewMockFilteredStore creates a new mock instance.
+func NewMockFilteredStore(ctrl *gomock.Controller) *MockFilteredStore {
+	mock := &MockFilteredStore{ctrl: ctrl}
+	mock.recorder = &MockFilteredStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFilteredStore) EXPECT() *MockFilteredStoreMockRecorder {
+	return m.recorder
+}
+
+// CountFiltered mocks base method.
+func (m *MockFilteredStore) CountFiltered(filter bson.D) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountFiltered", filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountFiltered indicates an expected call of CountFiltered.
+func (mr *MockFilteredStoreMockRecorder) CountFiltered(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountFiltered", reflect.TypeOf((*MockFilteredStore)(nil).CountFiltered), filter)
+}
+
+// CountFilteredWithContext mocks base method.
+func (m *MockFilteredStore) CountFilteredWithContext(ctx context.Context, filter bson.D) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountFilteredWithContext", ctx, filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountFilteredWithContext indicates an expected call of CountFilteredWithContext.
+func (mr *MockFilteredStoreMockRecorder) CountFilteredWithContext(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountFilteredWithContext", reflect.TypeOf((*MockFilteredStore)(nil).CountFilteredWithContext), ctx, filter)
+}
+
+// GetFiltered mocks base method.
+func (m *MockFilteredStore) GetFiltered(filter bson.D, limit int64, orderBy stores.OrderBy, results interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFiltered", filter, limit, orderBy, results)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetFiltered indicates an expected call of GetFiltered.
+func (mr *MockFilteredStoreMockRecorder) GetFiltered(filter, limit, orderBy, results interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFiltered", reflect.TypeOf((*MockFilteredStore)(nil).GetFiltered), filter, limit, orderBy, results)
+}
+
+// GetFilteredWithContext mocks base method.
+func (m *MockFilteredStore) GetFilteredWithContext(ctx context.Context, filter bson.D, limit int64, orderBy stores.OrderBy, results interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFilteredWithContext", ctx, filter, limit, orderBy, results)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetFilteredWithContext indicates an expected call of GetFilteredWithContext.
+func (mr *MockFilteredStoreMockRecorder) GetFilteredWithContext(ctx, filter, limit, orderBy, results interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFilteredWithContext", reflect.TypeOf((*MockFilteredStore)(nil).GetFilteredWithContext), ctx, filter, limit, orderBy, results)
+}
+
+// MockExcludingStore is a mock of ExcludingStore interface.
+type MockExcludingStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockExcludingStoreMockRecorder
+}
+
+// MockExcludingStoreMockRecorder is the mock recorder for MockExcludingStore.
+type MockExcludingStoreMockRecorder struct {
+	mock *MockExcludingStore
+}
+
+// NewMockExcludingStore creates a new mock instance.
+func NewMockExcludingStore(ctrl *gomock.Controller) *MockExcludingStore {
+	mock := &MockExcludingStore{ctrl: ctrl}
+	mock.recorder = &MockExcludingStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExcludingStore) EXPECT() *MockExcludingStoreMockRecorder {
+	return m.recorder
+}
+
+// CountExcludingWithContext mocks base method.
+func (m *MockExcludingStore) CountExcludingWithContext(ctx context.Context, exclusion stores.Exclusion) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountExcludingWithContext", ctx, exclusion)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountExcludingWithContext indicates an expected call of CountExcludingWithContext.
+func (mr *MockExcludingStoreMockRecorder) CountExcludingWithContext(ctx, exclusion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountExcludingWithContext", reflect.TypeOf((*MockExcludingStore)(nil).CountExcludingWithContext), ctx, exclusion)
+}
+
+// GetExcludingWithContext mocks base method.
+func (m *MockExcludingStore) GetExcludingWithContext(ctx context.Context, exclusion stores.Exclusion, limit int64, orderBy stores.OrderBy, results interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExcludingWithContext", ctx, exclusion, limit, orderBy, results)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetExcludingWithContext indicates an expected call of GetExcludingWithContext.
+func (mr *MockExcludingStoreMockRecorder) GetExcludingWithContext(ctx, exclusion, limit, orderBy, results interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExcludingWithContext", reflect.TypeOf((*MockExcludingStore)(nil).GetExcludingWithContext), ctx, exclusion, limit, orderBy, results)
+}
+
+// MockExpiringStore is a mock of ExpiringStore interface.
+type MockExpiringStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockExpiringStoreMockRecorder
+}
+
+// MockExpiringStoreMockRecorder is the mock recorder for MockExpiringStore.
+type MockExpiringStoreMockRecorder struct {
+	mock *MockExpiringStore
+}
+
+// NewMockExpiringStore creates a new mock instance.
+func NewMockExpiringStore(ctrl *gomock.Controller) *MockExpiringStore {
+	mock := &MockExpiringStore{ctrl: ctrl}
+	mock.recorder = &MockExpiringStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExpiringStore) EXPECT() *MockExpiringStoreMockRecorder {
+	return m.recorder
+}
+
+// CountOlderThan mocks base method.
+func (m *MockExpiringStore) CountOlderThan(field string, cutoff time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountOlderThan", field, cutoff)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountOlderThan indicates an expected call of CountOlderThan.
+func (mr *MockExpiringStoreMockRecorder) CountOlderThan(field, cutoff interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOlderThan", reflect.TypeOf((*MockExpiringStore)(nil).CountOlderThan), field, cutoff)
+}
+
+// DeleteAllById mocks base method.
+func (m *MockExpiringStore) DeleteAllById(arg0 []interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAllById", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAllById indicates an expected call of DeleteAllById.
+func (mr *MockExpiringStoreMockRecorder) DeleteAllById(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAllById", reflect.TypeOf((*MockExpiringStore)(nil).DeleteAllById), arg0)
+}
+
+// DeleteOlderThan mocks base method.
+func (m *MockExpiringStore) DeleteOlderThan(field string, cutoff time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOlderThan", field, cutoff)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteOlderThan indicates an expected call of DeleteOlderThan.
+func (mr *MockExpiringStoreMockRecorder) DeleteOlderThan(field, cutoff interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOlderThan", reflect.TypeOf((*MockExpiringStore)(nil).DeleteOlderThan), field, cutoff)
+}
+
+// ExportOlderThan mocks base method.
+func (m *MockExpiringStore) ExportOlderThan(field string, cutoff time.Time, export func(interface{}, []byte) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportOlderThan", field, cutoff, export)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportOlderThan indicates an expected call of ExportOlderThan.
+func (mr *MockExpiringStoreMockRecorder) ExportOlderThan(field, cutoff, export interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportOlderThan", reflect.TypeOf((*MockExpiringStore)(nil).ExportOlderThan), field, cutoff, export)
+}
+
+// MockSearchStore is a mock of SearchStore interface.
+type MockSearchStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockSearchStoreMockRecorder
+}
+
+// MockSearchStoreMockRecorder is the mock recorder for MockSearchStore.
+type MockSearchStoreMockRecorder struct {
+	mock *MockSearchStore
+}
+
+// NewMockSearchStore creates a new mock instance.
+func NewMockSearchStore(ctrl *gomock.Controller) *MockSearchStore {
+	mock := &MockSearchStore{ctrl: ctrl}
+	mock.recorder = &MockSearchStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSearchStore) EXPECT() *MockSearchStoreMockRecorder {
+	return m.recorder
+}
+
+// SearchPatternWithContext mocks base method.
+func (m *MockSearchStore) SearchPatternWithContext(ctx context.Context, fields []string, pattern string, limit int64, maxTime time.Duration) ([]stores.SearchHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchPatternWithContext", ctx, fields, pattern, limit, maxTime)
+	ret0, _ := ret[0].([]stores.SearchHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchPatternWithContext indicates an expected call of SearchPatternWithContext.
+func (mr *MockSearchStoreMockRecorder) SearchPatternWithContext(ctx, fields, pattern, limit, maxTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchPatternWithContext", reflect.TypeOf((*MockSearchStore)(nil).SearchPatternWithContext), ctx, fields, pattern, limit, maxTime)
+}
+
+// SearchTextWithContext mocks base method.
+func (m *MockSearchStore) SearchTextWithContext(ctx context.Context, text string, limit int64) ([]stores.SearchHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchTextWithContext", ctx, text, limit)
+	ret0, _ := ret[0].([]stores.SearchHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchTextWithContext indicates an expected call of SearchTextWithContext.
+func (mr *MockSearchStoreMockRecorder) SearchTextWithContext(ctx, text, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchTextWithContext", reflect.TypeOf((*MockSearchStore)(nil).SearchTextWithContext), ctx, text, limit)
+}
+
+// MockLinkStore is a mock of LinkStore interface.
+type MockLinkStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockLinkStoreMockRecorder
+}
+
+// MockLinkStoreMockRecorder is the mock recorder for MockLinkStore.
+type MockLinkStoreMockRecorder struct {
+	mock *MockLinkStore
+}
+
+// NewMockLinkStore creates a new mock instance.
+func NewMockLinkStore(ctrl *gomock.Controller) *MockLinkStore {
+	mock := &MockLinkStore{ctrl: ctrl}
+	mock.recorder = &MockLinkStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLinkStore) EXPECT() *MockLinkStoreMockRecorder {
+	return m.recorder
+}
+
+// LinksWithContext mocks base method.
+func (m *MockLinkStore) LinksWithContext(ctx context.Context, query stores.LinkQuery) ([]stores.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinksWithContext", ctx, query)
+	ret0, _ := ret[0].([]stores.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LinksWithContext indicates an expected call of LinksWithContext.
+func (mr *MockLinkStoreMockRecorder) LinksWithContext(ctx, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinksWithContext", reflect.TypeOf((*MockLinkStore)(nil).LinksWithContext), ctx, query)
+}
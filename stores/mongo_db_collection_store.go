@@ -7,9 +7,24 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"log/slog"
+	"strings"
+	"time"
 )
 
+const (
+	pingTimeout = 2 * time.Second
+	idIndex     = "_id_"
+	// textScoreField is the projected text score of the text search results
+	textScoreField = "_score"
+	// excludedField is the joined excluded documents of GetExcluding and CountExcluding
+	excludedField = "_excluded"
+)
+
+// DuplicatesOmittedHandler is notified with the number of duplicated items omitted by SaveAll
+var DuplicatesOmittedHandler = func(collection string, count int) {}
+
 type MongoDbCollectionStore struct {
 	database        string
 	collection      string
@@ -24,20 +39,98 @@ func (receiver MongoDbCollectionStore) All(results interface{}) error {
 }
 
 func (receiver MongoDbCollectionStore) Get(limit int64, orderBy OrderBy, results interface{}) error {
+	return receiver.GetWithContext(receiver.context, limit, orderBy, results)
+}
+
+func (receiver MongoDbCollectionStore) GetWithContext(ctx context.Context, limit int64, orderBy OrderBy, results interface{}) error {
+	return receiver.GetFilteredWithContext(ctx, bson.D{}, limit, orderBy, results)
+}
+
+func (receiver MongoDbCollectionStore) GetFiltered(filter bson.D, limit int64, orderBy OrderBy, results interface{}) error {
+	return receiver.GetFilteredWithContext(receiver.context, filter, limit, orderBy, results)
+}
+
+func (receiver MongoDbCollectionStore) GetFilteredWithContext(ctx context.Context, filter bson.D, limit int64, orderBy OrderBy, results interface{}) error {
 	findOptions := options.Find().SetLimit(limit)
 	if len(orderBy.Column) > 0 {
-		findOptions.SetSort(bson.D{{orderBy.Column, orderBy.Order}})
+		findOptions.SetSort(bson.D{{Key: orderBy.Column, Value: orderBy.Order}})
 	}
-	cursor, err := receiver.collectionStore.Find(receiver.context, bson.D{}, findOptions)
+	cursor, err := receiver.collectionStore.Find(ctx, filter, findOptions)
 	if err != nil {
 		return err
 	}
 
-	return cursor.All(receiver.context, results)
+	return cursor.All(ctx, results)
 }
 
 func (receiver MongoDbCollectionStore) Count() (int64, error) {
-	return receiver.collectionStore.CountDocuments(receiver.context, bson.D{})
+	return receiver.CountWithContext(receiver.context)
+}
+
+func (receiver MongoDbCollectionStore) CountWithContext(ctx context.Context) (int64, error) {
+	return receiver.CountFilteredWithContext(ctx, bson.D{})
+}
+
+func (receiver MongoDbCollectionStore) CountFiltered(filter bson.D) (int64, error) {
+	return receiver.CountFilteredWithContext(receiver.context, filter)
+}
+
+func (receiver MongoDbCollectionStore) CountFilteredWithContext(ctx context.Context, filter bson.D) (int64, error) {
+	return receiver.collectionStore.CountDocuments(ctx, filter)
+}
+
+// GetExcludingWithContext sorts the documents, joins their excluded documents and keeps the first ones without any
+func (receiver MongoDbCollectionStore) GetExcludingWithContext(ctx context.Context, exclusion Exclusion, limit int64, orderBy OrderBy, results interface{}) error {
+	pipeline := mongo.Pipeline{}
+	if len(orderBy.Column) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: orderBy.Column, Value: orderBy.Order}}}})
+	}
+	pipeline = append(pipeline, excluding(exclusion)...)
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$project", Value: bson.D{{Key: excludedField, Value: 0}}}})
+	cursor, err := receiver.collectionStore.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	return cursor.All(ctx, results)
+}
+
+func (receiver MongoDbCollectionStore) CountExcludingWithContext(ctx context.Context, exclusion Exclusion) (int64, error) {
+	pipeline := append(excluding(exclusion), bson.D{{Key: "$count", Value: "count"}})
+	cursor, err := receiver.collectionStore.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	var counts []struct {
+		Count int64 `bson:"count"`
+	}
+	err = cursor.All(ctx, &counts)
+	if err != nil || len(counts) == 0 {
+		return 0, err
+	}
+	return counts[0].Count, nil
+}
+
+// excluding joins at most one excluded document to every document, by an index of the foreign field if any, and keeps
+// the documents without one
+func excluding(exclusion Exclusion) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: exclusion.Collection},
+			{Key: "let", Value: bson.D{{Key: "value", Value: "$" + exclusion.LocalField}}},
+			{Key: "pipeline", Value: mongo.Pipeline{
+				{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$" + exclusion.ForeignField, "$$value"}}}}}}},
+				{{Key: "$match", Value: exclusion.Filter}},
+				{{Key: "$limit", Value: 1}},
+				{{Key: "$project", Value: bson.D{{Key: "_id", Value: 1}}}},
+			}},
+			{Key: "as", Value: excludedField},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: excludedField, Value: bson.D{{Key: "$size", Value: 0}}}}}},
+	}
 }
 
 func (receiver MongoDbCollectionStore) Save(element interface{}) error {
@@ -45,13 +138,20 @@ func (receiver MongoDbCollectionStore) Save(element interface{}) error {
 }
 
 func (receiver MongoDbCollectionStore) SaveAll(elements []interface{}) error {
-	slog.Debug("storing into mongo")
+	return receiver.SaveAllWithContext(receiver.context, elements)
+}
+
+func (receiver MongoDbCollectionStore) SaveAllWithContext(ctx context.Context, elements []interface{}) error {
+	if len(elements) == 0 {
+		return nil
+	}
+	slog.DebugContext(ctx, "storing into mongo")
 	opts := options.InsertMany().SetOrdered(false)
-	_, err := receiver.collectionStore.InsertMany(receiver.context, elements, opts)
-	return handleError(err)
+	_, err := receiver.collectionStore.InsertMany(ctx, elements, opts)
+	return handleError(receiver.collection, err)
 }
 
-func handleError(err error) error {
+func handleError(collection string, err error) error {
 	var bulkWriteException mongo.BulkWriteException
 	ok := errors.As(err, &bulkWriteException)
 	if ok == true {
@@ -61,6 +161,10 @@ func handleError(err error) error {
 				nonDuplicateErrors = append(nonDuplicateErrors, writeError)
 			}
 		}
+		duplicates := len(bulkWriteException.WriteErrors) - len(nonDuplicateErrors)
+		if duplicates > 0 {
+			DuplicatesOmittedHandler(collection, duplicates)
+		}
 		if len(nonDuplicateErrors) > 0 {
 			return mongo.BulkWriteException{WriteErrors: nonDuplicateErrors}
 		} else {
@@ -73,34 +177,311 @@ func handleError(err error) error {
 }
 
 func (receiver MongoDbCollectionStore) UpdateAllById(elements map[interface{}]interface{}) error {
+	return receiver.UpdateAllByIdWithContext(receiver.context, elements)
+}
+
+func (receiver MongoDbCollectionStore) UpdateAllByIdWithContext(ctx context.Context, elements map[interface{}]interface{}) error {
+	if len(elements) == 0 {
+		return nil
+	}
 	models := make([]mongo.WriteModel, 0)
 	for id, element := range elements {
 		models = append(models, mongo.NewUpdateOneModel().
 			SetUpsert(true).
-			SetFilter(bson.D{{"_id", id}}).
-			SetUpdate(bson.D{{"$set", element}}))
+			SetFilter(bson.D{{Key: "_id", Value: id}}).
+			SetUpdate(bson.D{{Key: "$set", Value: element}}))
 	}
 
-	_, err := receiver.collectionStore.BulkWrite(receiver.context, models, options.BulkWrite().SetOrdered(false))
+	_, err := receiver.collectionStore.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
 	return err
 }
 
-func (receiver MongoDbCollectionStore) Close() error {
-	return receiver.client.Disconnect(receiver.context)
+func (receiver MongoDbCollectionStore) DeleteAllById(ids []interface{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := receiver.collectionStore.DeleteMany(receiver.context, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+	return err
+}
+
+func (receiver MongoDbCollectionStore) FindById(id interface{}, result interface{}) error {
+	return receiver.FindByIdWithContext(receiver.context, id, result)
+}
+
+func (receiver MongoDbCollectionStore) FindByIdWithContext(ctx context.Context, id interface{}, result interface{}) error {
+	err := receiver.collectionStore.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// ReplaceById replaces a whole document, unlike UpdateAllById the fields missing from the new document are removed
+func (receiver MongoDbCollectionStore) ReplaceById(id interface{}, document interface{}) error {
+	result, err := receiver.collectionStore.ReplaceOne(receiver.context, bson.D{{Key: "_id", Value: id}}, document)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteById deletes a document and reports whether it existed
+func (receiver MongoDbCollectionStore) DeleteById(id interface{}) (bool, error) {
+	result, err := receiver.collectionStore.DeleteOne(receiver.context, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (receiver MongoDbCollectionStore) CountOlderThan(field string, cutoff time.Time) (int64, error) {
+	return receiver.collectionStore.CountDocuments(receiver.context, olderThan(field, cutoff))
+}
+
+func (receiver MongoDbCollectionStore) ExportOlderThan(field string, cutoff time.Time, export func(id interface{}, document []byte) error) error {
+	findOptions := options.Find().SetSort(bson.D{{Key: field, Value: 1}})
+	cursor, err := receiver.collectionStore.Find(receiver.context, olderThan(field, cutoff), findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(receiver.context)
+
+	for cursor.Next(receiver.context) {
+		document, err := bson.MarshalExtJSON(cursor.Current, false, false)
+		if err != nil {
+			return err
+		}
+		var id interface{}
+		err = cursor.Current.Lookup("_id").Unmarshal(&id)
+		if err != nil {
+			return err
+		}
+		err = export(id, document)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (receiver MongoDbCollectionStore) DeleteOlderThan(field string, cutoff time.Time) (int64, error) {
+	result, err := receiver.collectionStore.DeleteMany(receiver.context, olderThan(field, cutoff))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// SearchTextWithContext returns the documents matching the words of text, best text score first
+func (receiver MongoDbCollectionStore) SearchTextWithContext(ctx context.Context, text string, limit int64) ([]SearchHit, error) {
+	score := bson.D{{Key: textScoreField, Value: bson.D{{Key: "$meta", Value: "textScore"}}}}
+	findOptions := options.Find().SetProjection(score).SetSort(score).SetLimit(limit)
+	return receiver.search(ctx, bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: text}}}}, findOptions)
+}
+
+// SearchPatternWithContext returns the first documents by id with a field matching the pattern, case-insensitively.
+// The regex can't use an index, so the scan is stopped after maxTime.
+func (receiver MongoDbCollectionStore) SearchPatternWithContext(ctx context.Context, fields []string, pattern string, limit int64, maxTime time.Duration) ([]SearchHit, error) {
+	matches := bson.A{}
+	for _, field := range fields {
+		matches = append(matches, bson.D{{Key: field, Value: bson.D{{Key: "$regex", Value: pattern}, {Key: "$options", Value: "i"}}}})
+	}
+	// sorting by id keeps the same documents on every page
+	findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit).SetMaxTime(maxTime)
+	hits, err := receiver.search(ctx, bson.D{{Key: "$or", Value: matches}}, findOptions)
+	if mongo.IsTimeout(err) {
+		return nil, fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return hits, err
 }
 
-func NewMongoDbStore(url string, port string, database string, collection string) (*MongoDbCollectionStore, error) {
-	uri := fmt.Sprintf("mongodb://%s:%s", url, port)
-	slog.Info(fmt.Sprintf("connecting to mongo: %s, database: %s, collection: %s", uri, database, collection))
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+func (receiver MongoDbCollectionStore) search(ctx context.Context, filter bson.D, findOptions *options.FindOptions) ([]SearchHit, error) {
+	cursor, err := receiver.collectionStore.Find(ctx, filter, findOptions)
 	if err != nil {
 		return nil, err
 	}
+	defer cursor.Close(ctx)
+
+	hits := make([]SearchHit, 0)
+	for cursor.Next(ctx) {
+		hit := SearchHit{Document: append(bson.Raw{}, cursor.Current...)}
+		if score, ok := cursor.Current.Lookup(textScoreField).DoubleOK(); ok {
+			hit.Score = score
+		}
+		hits = append(hits, hit)
+	}
+	return hits, cursor.Err()
+}
+
+// LinksWithContext groups the matching documents by their From and To fields with an aggregation
+func (receiver MongoDbCollectionStore) LinksWithContext(ctx context.Context, query LinkQuery) ([]Link, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: query.Field, Value: bson.D{{Key: "$in", Value: query.Values}}},
+			{Key: query.TimeField, Value: bson.D{{Key: "$gte", Value: query.Since}, {Key: "$lt", Value: query.Until}}},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "from", Value: "$" + query.From}, {Key: "to", Value: "$" + query.To}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "last", Value: bson.D{{Key: "$max", Value: "$" + query.TimeField}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: query.Limit}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "from", Value: "$_id.from"},
+			{Key: "to", Value: "$_id.to"},
+			{Key: "count", Value: 1},
+			{Key: "last", Value: 1},
+		}}},
+	}
+	cursor, err := receiver.collectionStore.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	links := make([]Link, 0)
+	err = cursor.All(ctx, &links)
+	return links, err
+}
+
+func olderThan(field string, cutoff time.Time) bson.D {
+	return bson.D{{Key: field, Value: bson.D{{Key: "$lt", Value: cutoff}}}}
+}
+
+func (receiver MongoDbCollectionStore) Ping() error {
+	ctx, cancel := context.WithTimeout(receiver.context, pingTimeout)
+	defer cancel()
+	return receiver.client.Ping(ctx, readpref.Primary())
+}
+
+// EnsureIndexes creates the missing indexes and updates the expiry of the existing TTL indexes. Other indexes are kept.
+func (receiver MongoDbCollectionStore) EnsureIndexes(specs []IndexSpec) error {
+	existing, err := receiver.listIndexes()
+	if err != nil {
+		return err
+	}
+
+	var missing []mongo.IndexModel
+	var errs []error
+	for _, spec := range specs {
+		index, ok := findIndex(existing, spec)
+		if !ok {
+			missing = append(missing, spec.model())
+			continue
+		}
+		if index.expireAfterSeconds() == spec.expireAfterSeconds() {
+			continue
+		}
+		slog.Info(fmt.Sprintf("updating the expiry of index %s.%s to %s", receiver.collection, index.Name, spec.ExpireAfter))
+		if index.expireAfterSeconds() > 0 && spec.expireAfterSeconds() > 0 {
+			errs = append(errs, receiver.setExpiry(index, spec))
+			continue
+		}
+		// an index can't be safely made a TTL index or stop being one in place, expireAfterSeconds 0 expires all documents
+		_, err := receiver.collectionStore.Indexes().DropOne(receiver.context, index.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to drop index %s.%s: %w", receiver.collection, index.Name, err))
+			continue
+		}
+		missing = append(missing, spec.model())
+	}
+	if len(missing) > 0 {
+		names, err := receiver.collectionStore.Indexes().CreateMany(receiver.context, missing)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create indexes of %s: %w", receiver.collection, err))
+		} else {
+			slog.Info(fmt.Sprintf("created indexes of %s: %s", receiver.collection, strings.Join(names, ", ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IndexReport lists the missing and undeclared indexes, and the indexes that weren't used since the server started
+func (receiver MongoDbCollectionStore) IndexReport(specs []IndexSpec) (IndexReport, error) {
+	report := IndexReport{Collection: receiver.collection}
+	existing, err := receiver.listIndexes()
+	if err != nil {
+		return report, err
+	}
+	for _, spec := range specs {
+		if _, ok := findIndex(existing, spec); !ok {
+			report.Missing = append(report.Missing, spec.Name())
+		}
+	}
+	for _, index := range existing {
+		if index.Name == idIndex {
+			continue
+		}
+		declared := false
+		for _, spec := range specs {
+			declared = declared || index.matches(spec)
+		}
+		if !declared {
+			report.Undeclared = append(report.Undeclared, index.Name)
+		}
+	}
+
+	cursor, err := receiver.collectionStore.Aggregate(receiver.context, mongo.Pipeline{{{Key: "$indexStats", Value: bson.D{}}}})
+	if err != nil {
+		return report, err
+	}
+	var stats []struct {
+		Name     string `bson:"name"`
+		Accesses struct {
+			Ops   int64     `bson:"ops"`
+			Since time.Time `bson:"since"`
+		} `bson:"accesses"`
+	}
+	err = cursor.All(receiver.context, &stats)
+	if err != nil {
+		return report, err
+	}
+	for _, stat := range stats {
+		if stat.Name != idIndex && stat.Accesses.Ops == 0 {
+			report.Unused = append(report.Unused, IndexUsage{Name: stat.Name, Ops: stat.Accesses.Ops, Since: stat.Accesses.Since})
+		}
+	}
+	return report, nil
+}
+
+func (receiver MongoDbCollectionStore) listIndexes() ([]existingIndex, error) {
+	cursor, err := receiver.collectionStore.Indexes().List(receiver.context)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes of %s: %w", receiver.collection, err)
+	}
+	var existing []existingIndex
+	err = cursor.All(receiver.context, &existing)
+	return existing, err
+}
+
+func (receiver MongoDbCollectionStore) setExpiry(index existingIndex, spec IndexSpec) error {
+	command := bson.D{
+		{Key: "collMod", Value: receiver.collection},
+		{Key: "index", Value: bson.D{{Key: "name", Value: index.Name}, {Key: "expireAfterSeconds", Value: spec.expireAfterSeconds()}}},
+	}
+	err := receiver.client.Database(receiver.database).RunCommand(receiver.context, command).Err()
+	if err != nil {
+		return fmt.Errorf("failed to update the expiry of index %s.%s: %w", receiver.collection, index.Name, err)
+	}
+	return nil
+}
+
+// Close does nothing, as the client is shared by the stores and disconnected by its owner
+func (receiver MongoDbCollectionStore) Close() error {
+	return nil
+}
+
+// NewMongoDbStore creates a store of a collection, using a client created by NewMongoClient
+func NewMongoDbStore(client *mongo.Client, database string, collection string) *MongoDbCollectionStore {
+	slog.Info(fmt.Sprintf("using mongo database: %s, collection: %s", database, collection))
 	return &MongoDbCollectionStore{
 		database:        database,
 		collection:      collection,
 		client:          client,
 		collectionStore: client.Database(database).Collection(collection),
 		context:         context.Background(),
-		docKKey:         collection}, nil
+		docKKey:         collection}
 }
@@ -0,0 +1,198 @@
+package stores
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// MongoOptions configures the mongo client. Uri, when set, is used as is (including mongodb+srv:// uris) instead of Url and Port.
+// The other options override the matching uri options when set. The pool sizes and the timeouts set neither way
+// fall back to the defaults below.
+type MongoOptions struct {
+	Uri                    string        `config:"MONGO_DB_URI"`
+	Url                    string        `config:"MONGO_DB_URL" default:"localhost"`
+	Port                   string        `config:"MONGO_DB_PORT" default:"27017"`
+	Username               string        `config:"MONGO_DB_USERNAME"`
+	Password               string        `config:"MONGO_DB_PASSWORD" secret:"true"`
+	PasswordFile           string        `config:"MONGO_DB_PASSWORD_FILE"`
+	AuthSource             string        `config:"MONGO_DB_AUTH_SOURCE"`
+	ReplicaSet             string        `config:"MONGO_DB_REPLICA_SET"`
+	ReadPreference         string        `config:"MONGO_DB_READ_PREFERENCE" oneof:"primary|primaryPreferred|secondary|secondaryPreferred|nearest"`
+	Tls                    bool          `config:"MONGO_DB_TLS"`
+	TlsCaFile              string        `config:"MONGO_DB_TLS_CA_FILE"`
+	TlsCertFile            string        `config:"MONGO_DB_TLS_CERT_FILE"`
+	TlsKeyFile             string        `config:"MONGO_DB_TLS_KEY_FILE"`
+	TlsInsecure            bool          `config:"MONGO_DB_TLS_INSECURE"`
+	MinPoolSize            int           `config:"MONGO_DB_MIN_POOL_SIZE" min:"0"`
+	MaxPoolSize            int           `config:"MONGO_DB_MAX_POOL_SIZE" min:"0"`
+	ConnectTimeout         time.Duration `config:"MONGO_DB_CONNECT_TIMEOUT_SECONDS" unit:"seconds" min:"0"`
+	ServerSelectionTimeout time.Duration `config:"MONGO_DB_SERVER_SELECTION_TIMEOUT_SECONDS" unit:"seconds" min:"0"`
+}
+
+const (
+	defaultMaxPoolSize            = 100
+	defaultConnectTimeout         = 10 * time.Second
+	defaultServerSelectionTimeout = 30 * time.Second
+)
+
+// Validate reports conflicting options
+func (receiver MongoOptions) Validate() error {
+	var errs []error
+	if len(receiver.Uri) == 0 && (len(receiver.Url) == 0 || len(receiver.Port) == 0) {
+		errs = append(errs, errors.New("either MONGO_DB_URI or MONGO_DB_URL and MONGO_DB_PORT are required"))
+	}
+	if len(receiver.Password) > 0 && len(receiver.PasswordFile) > 0 {
+		errs = append(errs, errors.New("MONGO_DB_PASSWORD and MONGO_DB_PASSWORD_FILE are mutually exclusive"))
+	}
+	if (len(receiver.TlsCertFile) > 0) != (len(receiver.TlsKeyFile) > 0) {
+		errs = append(errs, errors.New("MONGO_DB_TLS_CERT_FILE and MONGO_DB_TLS_KEY_FILE must be set together"))
+	}
+	if receiver.MaxPoolSize > 0 && receiver.MinPoolSize > receiver.MaxPoolSize {
+		errs = append(errs, fmt.Errorf("MONGO_DB_MIN_POOL_SIZE (%d) must not exceed MONGO_DB_MAX_POOL_SIZE (%d)", receiver.MinPoolSize, receiver.MaxPoolSize))
+	}
+	return errors.Join(errs...)
+}
+
+// NewMongoClient connects a client, to be shared by all the stores of the process
+func NewMongoClient(mongoOptions MongoOptions) (*mongo.Client, error) {
+	clientOptions, err := mongoOptions.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	slog.Info(fmt.Sprintf("connecting to mongo: %s", mongoOptions.redactedUri()))
+	return mongo.Connect(context.Background(), clientOptions)
+}
+
+func (receiver MongoOptions) clientOptions() (*options.ClientOptions, error) {
+	clientOptions := options.Client().ApplyURI(receiver.uri())
+	err := clientOptions.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid mongo uri: %w", err)
+	}
+
+	if len(receiver.Username) > 0 {
+		password, err := receiver.password()
+		if err != nil {
+			return nil, err
+		}
+		credential := options.Credential{Username: receiver.Username, Password: password, PasswordSet: len(password) > 0}
+		if clientOptions.Auth != nil {
+			credential.AuthMechanism = clientOptions.Auth.AuthMechanism
+			credential.AuthSource = clientOptions.Auth.AuthSource
+		}
+		if len(receiver.AuthSource) > 0 {
+			credential.AuthSource = receiver.AuthSource
+		}
+		clientOptions.SetAuth(credential)
+	} else if len(receiver.AuthSource) > 0 && clientOptions.Auth != nil {
+		clientOptions.Auth.AuthSource = receiver.AuthSource
+	}
+
+	if len(receiver.ReplicaSet) > 0 {
+		clientOptions.SetReplicaSet(receiver.ReplicaSet)
+	}
+	if len(receiver.ReadPreference) > 0 {
+		mode, err := readpref.ModeFromString(receiver.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		preference, err := readpref.New(mode)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetReadPreference(preference)
+	}
+
+	if receiver.Tls || len(receiver.TlsCaFile) > 0 || len(receiver.TlsCertFile) > 0 {
+		tlsConfig, err := receiver.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+
+	if receiver.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(uint64(receiver.MinPoolSize))
+	}
+	if receiver.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(uint64(receiver.MaxPoolSize))
+	} else if clientOptions.MaxPoolSize == nil {
+		clientOptions.SetMaxPoolSize(defaultMaxPoolSize)
+	}
+	if receiver.ConnectTimeout > 0 {
+		clientOptions.SetConnectTimeout(receiver.ConnectTimeout)
+	} else if clientOptions.ConnectTimeout == nil {
+		clientOptions.SetConnectTimeout(defaultConnectTimeout)
+	}
+	if receiver.ServerSelectionTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(receiver.ServerSelectionTimeout)
+	} else if clientOptions.ServerSelectionTimeout == nil {
+		clientOptions.SetServerSelectionTimeout(defaultServerSelectionTimeout)
+	}
+	clientOptions.SetMonitor(otelmongo.NewMonitor())
+	return clientOptions, nil
+}
+
+func (receiver MongoOptions) uri() string {
+	if len(receiver.Uri) > 0 {
+		return receiver.Uri
+	}
+	return fmt.Sprintf("mongodb://%s:%s", receiver.Url, receiver.Port)
+}
+
+func (receiver MongoOptions) redactedUri() string {
+	parsed, err := url.Parse(receiver.uri())
+	if err != nil {
+		return "<invalid uri>"
+	}
+	if parsed.User != nil {
+		parsed.User = url.User(parsed.User.Username())
+	}
+	return parsed.String()
+}
+
+func (receiver MongoOptions) password() (string, error) {
+	if len(receiver.PasswordFile) == 0 {
+		return receiver.Password, nil
+	}
+	content, err := os.ReadFile(receiver.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read mongo password file: %w", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func (receiver MongoOptions) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: receiver.TlsInsecure}
+	if len(receiver.TlsCaFile) > 0 {
+		ca, err := os.ReadFile(receiver.TlsCaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mongo CA file: %w", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in mongo CA file: %s", receiver.TlsCaFile)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+	if len(receiver.TlsCertFile) > 0 {
+		certificate, err := tls.LoadX509KeyPair(receiver.TlsCertFile, receiver.TlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mongo client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
@@ -1,9 +1,20 @@
 package stores
 
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+// ReadStore reads the documents of a collection. The WithContext variants run within the context of a request or a
+// batch, the others within the context of the store.
 type ReadStore interface {
 	Get(int64, OrderBy, interface{}) error
+	GetWithContext(context.Context, int64, OrderBy, interface{}) error
 	All(interface{}) error
 	Count() (int64, error)
+	CountWithContext(context.Context) (int64, error)
+	Ping() error
 	Close() error
 }
 
@@ -11,7 +22,94 @@ type ReadWriteStore interface {
 	ReadStore
 	Save(interface{}) error
 	SaveAll([]interface{}) error
+	SaveAllWithContext(context.Context, []interface{}) error
 	UpdateAllById(map[interface{}]interface{}) error
+	UpdateAllByIdWithContext(context.Context, map[interface{}]interface{}) error
+	DeleteAllById([]interface{}) error
+}
+
+// DocumentStore reads, replaces and deletes single documents by id.
+// FindById and ReplaceById return ErrNotFound when there is no such document.
+type DocumentStore interface {
+	ReadWriteStore
+	FindById(id interface{}, result interface{}) error
+	FindByIdWithContext(ctx context.Context, id interface{}, result interface{}) error
+	ReplaceById(id interface{}, document interface{}) error
+	DeleteById(id interface{}) (bool, error)
+}
+
+// FilteredStore reads the documents matching a filter, like Get and Count read them all
+type FilteredStore interface {
+	GetFiltered(filter bson.D, limit int64, orderBy OrderBy, results interface{}) error
+	GetFilteredWithContext(ctx context.Context, filter bson.D, limit int64, orderBy OrderBy, results interface{}) error
+	CountFiltered(filter bson.D) (int64, error)
+	CountFilteredWithContext(ctx context.Context, filter bson.D) (int64, error)
+}
+
+// ExcludingStore reads the documents without a matching document in another collection of the same database, like
+// GetFiltered and CountFiltered read the documents matching a filter
+type ExcludingStore interface {
+	GetExcludingWithContext(ctx context.Context, exclusion Exclusion, limit int64, orderBy OrderBy, results interface{}) error
+	CountExcludingWithContext(ctx context.Context, exclusion Exclusion) (int64, error)
+}
+
+// Exclusion excludes the documents whose LocalField equals the ForeignField of a document of Collection matching
+// Filter, e.g. the events whose actor_login is the login of a bot among the users
+type Exclusion struct {
+	LocalField   string
+	Collection   string
+	ForeignField string
+	Filter       bson.D
+}
+
+// ExpiringStore prunes the items whose date field is older than a cutoff
+type ExpiringStore interface {
+	CountOlderThan(field string, cutoff time.Time) (int64, error)
+	// ExportOlderThan calls export with the id and the relaxed extended JSON of every expired item, oldest first
+	ExportOlderThan(field string, cutoff time.Time, export func(id interface{}, document []byte) error) error
+	DeleteOlderThan(field string, cutoff time.Time) (int64, error)
+	DeleteAllById([]interface{}) error
+}
+
+// SearchStore finds documents by text. Text searches use the text index of the collection, pattern searches match a
+// case-insensitive regular expression against fields, which may scan them all, so they fail with ErrTimeout after
+// maxTime.
+type SearchStore interface {
+	SearchTextWithContext(ctx context.Context, text string, limit int64) ([]SearchHit, error)
+	SearchPatternWithContext(ctx context.Context, fields []string, pattern string, limit int64, maxTime time.Duration) ([]SearchHit, error)
+}
+
+// SearchHit is a found document, along with its text score, 0 for pattern searches
+type SearchHit struct {
+	Score    float64
+	Document bson.Raw
+}
+
+// LinkStore links the values of two fields of the documents in a time window, e.g. the actors and the repos of events
+type LinkStore interface {
+	// Links returns the distinct links of the documents matching the query, most linked first
+	LinksWithContext(ctx context.Context, query LinkQuery) ([]Link, error)
+}
+
+// LinkQuery selects the documents whose Field is one of Values and whose TimeField is in [Since, Until), and links
+// their From and To fields
+type LinkQuery struct {
+	From      string
+	To        string
+	Field     string
+	Values    []string
+	TimeField string
+	Since     time.Time
+	Until     time.Time
+	Limit     int64
+}
+
+// Link is a distinct pair of From and To values, along with its count of documents and the time of the last one
+type Link struct {
+	From  string    `bson:"from"`
+	To    string    `bson:"to"`
+	Count int64     `bson:"count"`
+	Last  time.Time `bson:"last"`
 }
 
 type OrderBy struct {
@@ -1,5 +1,12 @@
 package stores
 
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
 // StubStore for tests
 type StubStore struct {
 	data []interface{}
@@ -23,10 +30,22 @@ func (store *StubStore) Get(limit int64, orderBy OrderBy, results interface{}) e
 	return nil
 }
 
+func (store *StubStore) GetWithContext(_ context.Context, limit int64, orderBy OrderBy, results interface{}) error {
+	return store.Get(limit, orderBy, results)
+}
+
 func (store *StubStore) Count() (int64, error) {
 	return int64(len(store.data)), nil
 }
 
+func (store *StubStore) CountWithContext(context.Context) (int64, error) {
+	return store.Count()
+}
+
+func (store *StubStore) Ping() error {
+	return nil
+}
+
 func (store *StubStore) Close() error {
 	store.data = nil
 	return nil
@@ -44,13 +63,53 @@ func (store *StubStore) SaveAll(elements []interface{}) error {
 	return nil
 }
 
+func (store *StubStore) SaveAllWithContext(_ context.Context, elements []interface{}) error {
+	return store.SaveAll(elements)
+}
+
 func (store *StubStore) UpdateAllById(elements map[interface{}]interface{}) error {
-	for _, element := range elements {
-		store.data = append(store.data, element)
+	// sort by id so that tests don't depend on map iteration order
+	ids := make([]interface{}, 0, len(elements))
+	for id := range elements {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return fmt.Sprint(ids[i]) < fmt.Sprint(ids[j])
+	})
+	for _, id := range ids {
+		store.data = append(store.data, elements[id])
 	}
 	return nil
 }
 
+func (store *StubStore) UpdateAllByIdWithContext(_ context.Context, elements map[interface{}]interface{}) error {
+	return store.UpdateAllById(elements)
+}
+
+func (store *StubStore) DeleteAllById(ids []interface{}) error {
+	remaining := make([]interface{}, 0, len(store.data))
+	for _, element := range store.data {
+		if !containsId(ids, element) {
+			remaining = append(remaining, element)
+		}
+	}
+	store.data = remaining
+	return nil
+}
+
+func containsId(ids []interface{}, element interface{}) bool {
+	value := reflect.Indirect(reflect.ValueOf(element))
+	if value.Kind() != reflect.Struct || !value.FieldByName("ID").IsValid() {
+		return false
+	}
+	for _, id := range ids {
+		if id == value.FieldByName("ID").Interface() {
+			return true
+		}
+	}
+	return false
+}
+
 func NewStubStore(data []interface{}) *StubStore {
 	return &StubStore{data: data}
 }